@@ -0,0 +1,103 @@
+// Package export implements the "export" subcommand of the provider binary, which
+// writes Terraform configuration for the resources on an existing Streamdal server.
+package export
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/streamdal/terraform-provider-streamdal/internal/provider"
+	"github.com/streamdal/terraform-provider-streamdal/streamdal"
+)
+
+// Exit codes of the export subcommand
+const (
+	ExitOK    = 0
+	ExitError = 1
+	ExitUsage = 2
+)
+
+// Run connects to the server using the same settings and environment variables as
+// the provider, and writes the generated configuration to -out or stdout
+func Run(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: terraform-provider-streamdal export [options]\n\n")
+		fmt.Fprintf(stderr, "Writes Terraform configuration and import blocks for the pipelines, notifications and audiences on a Streamdal server.\n\n")
+		fs.PrintDefaults()
+	}
+
+	timeout, _ := strconv.Atoi(envDefault("STREAMDAL_CONNECTION_TIMEOUT", "10"))
+
+	address := fs.String("address", envDefault("STREAMDAL_ADDRESS", "localhost:8082"), "address of the Streamdal server (STREAMDAL_ADDRESS)")
+	token := fs.String("token", os.Getenv("STREAMDAL_TOKEN"), "Streamdal server API token (STREAMDAL_TOKEN)")
+	fs.IntVar(&timeout, "timeout", timeout, "connection timeout in seconds (STREAMDAL_CONNECTION_TIMEOUT)")
+	out := fs.String("out", "", "file to write the configuration to, instead of stdout")
+
+	if err := fs.Parse(args); err != nil {
+		return ExitUsage
+	}
+
+	if *token == "" {
+		fmt.Fprintln(stderr, "Error: an API token is required, set -token or STREAMDAL_TOKEN")
+		return ExitUsage
+	}
+
+	client, err := streamdal.New(&streamdal.Config{Address: *address, Token: *token, Timeout: timeout})
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %s\n", err)
+		return ExitError
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
+	defer cancel()
+
+	all, err := client.GetAll(ctx)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: unable to fetch pipelines and audiences: %s\n", err)
+		return ExitError
+	}
+
+	notifications, err := client.GetNotifications(ctx)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: unable to fetch notifications: %s\n", err)
+		return ExitError
+	}
+
+	config, warnings, err := provider.GenerateConfig(all, notifications)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %s\n", err)
+		return ExitError
+	}
+
+	for _, w := range warnings {
+		fmt.Fprintf(stderr, "Warning: %s\n", w)
+	}
+
+	if *out == "" {
+		_, _ = stdout.Write(config)
+		return ExitOK
+	}
+
+	if err := os.WriteFile(*out, config, 0644); err != nil {
+		fmt.Fprintf(stderr, "Error: %s\n", err)
+		return ExitError
+	}
+
+	return ExitOK
+}
+
+func envDefault(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+
+	return def
+}
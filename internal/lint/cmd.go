@@ -0,0 +1,70 @@
+package lint
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+)
+
+// Exit codes of the lint subcommand
+const (
+	ExitOK       = 0
+	ExitFindings = 1
+	ExitUsage    = 2
+)
+
+// Run implements the "lint" subcommand of the provider binary. It exits with
+// ExitFindings if any errors were found, or with -strict, any warnings.
+func Run(version string, args []string, stdout, stderr io.Writer) int {
+	// The plugin SDK logs its internals, ie. "[DEBUG] setting computed for ...", to
+	// the standard logger when validating. Only findings belong in the output.
+	defer log.SetOutput(log.Writer())
+	log.SetOutput(io.Discard)
+
+	fs := flag.NewFlagSet("lint", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: terraform-provider-streamdal lint [options] [files or directories...]\n\n")
+		fmt.Fprintf(stderr, "Checks streamdal_pipeline resources without running Terraform or connecting to a server.\n\n")
+		fs.PrintDefaults()
+	}
+
+	format := fs.String("format", FormatHuman, "output format: human, json or sarif")
+	strict := fs.Bool("strict", false, "exit with an error status on warnings as well as errors")
+
+	if err := fs.Parse(args); err != nil {
+		return ExitUsage
+	}
+
+	files, err := Paths(fs.Args())
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %s\n", err)
+		return ExitUsage
+	}
+
+	findings := make([]Finding, 0)
+
+	for _, file := range files {
+		found, err := File(context.Background(), file)
+		if err != nil {
+			fmt.Fprintf(stderr, "Error: unable to read '%s': %s\n", file, err)
+			return ExitUsage
+		}
+
+		findings = append(findings, found...)
+	}
+
+	if err := Write(stdout, *format, version, findings); err != nil {
+		fmt.Fprintf(stderr, "Error: %s\n", err)
+		return ExitUsage
+	}
+
+	errs, warnings := Counts(findings)
+	if errs > 0 || (*strict && warnings > 0) {
+		return ExitFindings
+	}
+
+	return ExitOK
+}
@@ -0,0 +1,53 @@
+package lint
+
+import (
+	"os"
+
+	"github.com/hashicorp/hcl/v2"
+	hclcty "github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+	"github.com/zclconf/go-cty/cty/function/stdlib"
+)
+
+// evalContext supports the path.* values and the functions commonly used to build
+// pipeline attributes. References to anything else, ie. variables or other
+// resources, are treated as unknown values and skipped by the checks.
+func evalContext(moduleDir string) *hcl.EvalContext {
+	cwd, _ := os.Getwd()
+
+	return &hcl.EvalContext{
+		Variables: map[string]hclcty.Value{
+			"path": hclcty.ObjectVal(map[string]hclcty.Value{
+				"module": hclcty.StringVal(moduleDir),
+				"root":   hclcty.StringVal(moduleDir),
+				"cwd":    hclcty.StringVal(cwd),
+			}),
+		},
+		Functions: map[string]function.Function{
+			"file":       fileFunc,
+			"jsonencode": stdlib.JSONEncodeFunc,
+			"jsondecode": stdlib.JSONDecodeFunc,
+			"format":     stdlib.FormatFunc,
+			"join":       stdlib.JoinFunc,
+			"lower":      stdlib.LowerFunc,
+			"upper":      stdlib.UpperFunc,
+			"trimspace":  stdlib.TrimSpaceFunc,
+		},
+	}
+}
+
+// fileFunc reads a file relative to the working directory, like Terraform's file()
+var fileFunc = function.New(&function.Spec{
+	Params: []function.Parameter{
+		{Name: "path", Type: hclcty.String},
+	},
+	Type: function.StaticReturnType(hclcty.String),
+	Impl: func(args []hclcty.Value, _ hclcty.Type) (hclcty.Value, error) {
+		data, err := os.ReadFile(args[0].AsString())
+		if err != nil {
+			return hclcty.UnknownVal(hclcty.String), err
+		}
+
+		return hclcty.StringVal(string(data)), nil
+	},
+})
@@ -0,0 +1,402 @@
+// Package lint checks the streamdal_pipeline resources in Terraform configuration
+// files using the provider's plan-time validations, without running Terraform or
+// connecting to a Streamdal server.
+package lint
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	hclcty "github.com/zclconf/go-cty/cty"
+
+	"github.com/streamdal/terraform-provider-streamdal/internal/provider"
+)
+
+const pipelineResource = "streamdal_pipeline"
+
+// metaArguments are handled by Terraform rather than the provider
+var metaArguments = map[string]bool{
+	"count":      true,
+	"for_each":   true,
+	"depends_on": true,
+	"provider":   true,
+	"lifecycle":  true,
+	"connection": true,
+}
+
+// Severity of a finding
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// Rules of findings which don't come from the provider's checks
+const (
+	RuleSyntax    = "syntax"
+	RuleNotLinted = "not-linted"
+)
+
+// ruleDescriptions describes every rule a finding can have
+var ruleDescriptions = map[string]string{
+	RuleSyntax:    "Configuration file can't be parsed",
+	RuleNotLinted: "Resource can only be checked by Terraform",
+}
+
+func init() {
+	for rule, description := range provider.Rules {
+		ruleDescriptions[rule] = description
+	}
+}
+
+// Finding is a single problem found in a configuration file
+type Finding struct {
+	File     string `json:"file"`
+	Resource string `json:"resource,omitempty"`
+
+	// Attribute is the path of the attribute in the resource, ie. "step.0.detective.0.args"
+	Attribute string `json:"attribute,omitempty"`
+
+	// Rule identifies the check which produced the finding, ie. "detective-args"
+	Rule string `json:"rule"`
+
+	Severity string `json:"severity"`
+	Summary  string `json:"summary"`
+	Detail   string `json:"detail,omitempty"`
+
+	Range hcl.Range `json:"-"`
+}
+
+// Paths returns the .tf files to lint. Directories are searched recursively,
+// skipping hidden directories such as .terraform.
+func Paths(args []string) ([]string, error) {
+	files := make([]string, 0)
+
+	if len(args) == 0 {
+		args = []string{"."}
+	}
+
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err != nil {
+			return nil, err
+		}
+
+		if !info.IsDir() {
+			files = append(files, arg)
+			continue
+		}
+
+		err = filepath.WalkDir(arg, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+
+			if entry.IsDir() && path != arg && strings.HasPrefix(entry.Name(), ".") {
+				return filepath.SkipDir
+			}
+
+			if !entry.IsDir() && filepath.Ext(path) == ".tf" {
+				files = append(files, path)
+			}
+
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Strings(files)
+
+	return files, nil
+}
+
+// File lints the streamdal_pipeline resources in a single configuration file
+func File(ctx context.Context, path string) ([]Finding, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return Source(ctx, path, src), nil
+}
+
+// Source lints the streamdal_pipeline resources in the given configuration
+func Source(ctx context.Context, path string, src []byte) []Finding {
+	findings := make([]Finding, 0)
+
+	file, hclDiags := hclsyntax.ParseConfig(src, path, hcl.Pos{Line: 1, Column: 1})
+	for _, d := range hclDiags {
+		findings = append(findings, hclFinding(path, d))
+	}
+
+	if hclDiags.HasErrors() {
+		return findings
+	}
+
+	body, ok := file.Body.(*hclsyntax.Body)
+	if !ok {
+		return findings
+	}
+
+	evalCtx := evalContext(filepath.Dir(path))
+
+	for _, block := range body.Blocks {
+		if block.Type != "resource" || len(block.Labels) != 2 || block.Labels[0] != pipelineResource {
+			continue
+		}
+
+		address := strings.Join(block.Labels, ".")
+
+		if hasDynamicBlocks(block.Body) {
+			findings = append(findings, Finding{
+				File:     path,
+				Resource: address,
+				Rule:     RuleNotLinted,
+				Severity: SeverityWarning,
+				Summary:  "Resource was not linted",
+				Detail:   "Resources with dynamic blocks can only be checked by Terraform.",
+				Range:    block.DefRange(),
+			})
+
+			continue
+		}
+
+		raw := bodyToRaw(block.Body, evalCtx, true)
+
+		for _, d := range provider.ValidatePipeline(ctx, raw) {
+			findings = append(findings, diagFinding(path, address, block, d))
+		}
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		return findings[i].Range.Start.Line < findings[j].Range.Start.Line
+	})
+
+	return findings
+}
+
+// bodyToRaw converts a block body to the raw configuration format used by the
+// plugin SDK. Values which can't be evaluated without Terraform are unknown.
+func bodyToRaw(body *hclsyntax.Body, evalCtx *hcl.EvalContext, root bool) map[string]interface{} {
+	raw := make(map[string]interface{})
+
+	for name, attr := range body.Attributes {
+		if root && metaArguments[name] {
+			continue
+		}
+
+		val, diags := attr.Expr.Value(evalCtx)
+		if diags.HasErrors() || !val.IsWhollyKnown() {
+			raw[name] = provider.UnknownVariableValue
+			continue
+		}
+
+		if v := ctyToRaw(val); v != nil {
+			raw[name] = v
+		}
+	}
+
+	for _, block := range body.Blocks {
+		if root && metaArguments[block.Type] {
+			continue
+		}
+
+		list, _ := raw[block.Type].([]interface{})
+		raw[block.Type] = append(list, bodyToRaw(block.Body, evalCtx, false))
+	}
+
+	return raw
+}
+
+func ctyToRaw(val hclcty.Value) interface{} {
+	if val.IsNull() {
+		return nil
+	}
+
+	t := val.Type()
+
+	switch {
+	case t == hclcty.String:
+		return val.AsString()
+	case t == hclcty.Bool:
+		return val.True()
+	case t == hclcty.Number:
+		bf := val.AsBigFloat()
+		if bf.IsInt() {
+			i, _ := bf.Int64()
+			return int(i)
+		}
+
+		f, _ := bf.Float64()
+		return f
+	case t.IsListType() || t.IsTupleType() || t.IsSetType():
+		list := make([]interface{}, 0, val.LengthInt())
+		for it := val.ElementIterator(); it.Next(); {
+			_, v := it.Element()
+			list = append(list, ctyToRaw(v))
+		}
+
+		return list
+	case t.IsMapType() || t.IsObjectType():
+		m := make(map[string]interface{})
+		for it := val.ElementIterator(); it.Next(); {
+			k, v := it.Element()
+			if raw := ctyToRaw(v); raw != nil {
+				m[k.AsString()] = raw
+			}
+		}
+
+		return m
+	}
+
+	return nil
+}
+
+func hasDynamicBlocks(body *hclsyntax.Body) bool {
+	for _, block := range body.Blocks {
+		if block.Type == "dynamic" || hasDynamicBlocks(block.Body) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func hclFinding(path string, d *hcl.Diagnostic) Finding {
+	f := Finding{
+		File:     path,
+		Rule:     RuleSyntax,
+		Severity: SeverityError,
+		Summary:  d.Summary,
+		Detail:   d.Detail,
+	}
+
+	if d.Severity == hcl.DiagWarning {
+		f.Severity = SeverityWarning
+	}
+
+	if d.Subject != nil {
+		f.Range = *d.Subject
+	}
+
+	return f
+}
+
+func diagFinding(path, address string, block *hclsyntax.Block, d provider.RuleDiagnostic) Finding {
+	f := Finding{
+		File:      path,
+		Resource:  address,
+		Rule:      d.Rule,
+		Attribute: attributePath(d.AttributePath),
+		Severity:  SeverityError,
+		Summary:   d.Summary,
+		Detail:    d.Detail,
+		Range:     pathRange(block, d.AttributePath),
+	}
+
+	if d.Severity == diag.Warning {
+		f.Severity = SeverityWarning
+	}
+
+	return f
+}
+
+// pathRange finds the location in the resource block that an attribute path refers to.
+// Paths which lead into an attribute's value resolve to the whole attribute.
+func pathRange(block *hclsyntax.Block, path cty.Path) hcl.Range {
+	rng := block.DefRange()
+	body := block.Body
+
+	for i := 0; i < len(path); i++ {
+		step, ok := path[i].(cty.GetAttrStep)
+		if !ok || body == nil {
+			break
+		}
+
+		if attr, ok := body.Attributes[step.Name]; ok {
+			return attr.SrcRange
+		}
+
+		blocks := make([]*hclsyntax.Block, 0)
+		for _, b := range body.Blocks {
+			if b.Type == step.Name {
+				blocks = append(blocks, b)
+			}
+		}
+
+		if len(blocks) == 0 {
+			break
+		}
+
+		index := 0
+		if i+1 < len(path) {
+			if idx, ok := path[i+1].(cty.IndexStep); ok && idx.Key.Type() == cty.Number {
+				n, _ := idx.Key.AsBigFloat().Int64()
+				index = int(n)
+				i++
+			}
+		}
+
+		if index >= len(blocks) {
+			break
+		}
+
+		rng = blocks[index].DefRange()
+		body = blocks[index].Body
+	}
+
+	return rng
+}
+
+// attributePath renders an attribute path in dotted form, ie. "step.0.detective"
+func attributePath(p cty.Path) string {
+	parts := make([]string, 0, len(p))
+
+	for _, step := range p {
+		switch s := step.(type) {
+		case cty.GetAttrStep:
+			parts = append(parts, s.Name)
+		case cty.IndexStep:
+			if s.Key.Type() == cty.Number {
+				parts = append(parts, s.Key.AsBigFloat().Text('f', 0))
+			} else {
+				parts = append(parts, s.Key.AsString())
+			}
+		}
+	}
+
+	return strings.Join(parts, ".")
+}
+
+// Counts returns the number of errors and warnings in the findings
+func Counts(findings []Finding) (int, int) {
+	var errs, warnings int
+
+	for _, f := range findings {
+		if f.Severity == SeverityError {
+			errs++
+		} else {
+			warnings++
+		}
+	}
+
+	return errs, warnings
+}
+
+func line(f Finding) string {
+	if f.Range.Start.Line == 0 {
+		return f.File
+	}
+
+	return fmt.Sprintf("%s:%d:%d", f.File, f.Range.Start.Line, f.Range.Start.Column)
+}
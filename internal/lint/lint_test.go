@@ -0,0 +1,232 @@
+package lint
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testConfig = `
+variable "path" {}
+
+resource "streamdal_pipeline" "pii" {
+  name = "PII"
+
+  step {
+    name = "Find email"
+
+    detective {
+      type = "numeric_range"
+      path = "object.age"
+      args = ["10", "1"]
+    }
+
+    on_true  { abort = "abort_current" }
+    on_false { abort = "abort_current" }
+    on_error { abort = "abort_all" }
+  }
+
+  step {
+    name    = "Mask email"
+    dynamic = true
+
+    transform {
+      mask_value {
+        path = var.path
+      }
+    }
+  }
+}
+
+resource "streamdal_notification" "ignored" {
+  name = "Ignored"
+}
+`
+
+func TestSource(t *testing.T) {
+	findings := Source(context.Background(), "main.tf", []byte(testConfig))
+
+	expected := []struct {
+		severity  string
+		summary   string
+		attribute string
+		line      int
+	}{
+		{SeverityError, "Invalid args for 'numeric_range' detective", "step.0.detective.0.args", 13},
+		{SeverityWarning, "Unreachable steps", "step.1", 21},
+	}
+
+	if len(findings) != len(expected) {
+		t.Fatalf("expected %d findings, got %+v", len(expected), findings)
+	}
+
+	for i, e := range expected {
+		f := findings[i]
+
+		if f.Severity != e.severity || f.Summary != e.summary || f.Attribute != e.attribute || f.Range.Start.Line != e.line {
+			t.Errorf("finding %d: unexpected %+v", i, f)
+		}
+
+		if f.Resource != "streamdal_pipeline.pii" {
+			t.Errorf("finding %d: unexpected resource '%s'", i, f.Resource)
+		}
+	}
+}
+
+func TestSource_SyntaxError(t *testing.T) {
+	findings := Source(context.Background(), "main.tf", []byte(`resource "streamdal_pipeline" "x" {`))
+
+	if errs, _ := Counts(findings); errs == 0 {
+		t.Fatal("expected a syntax error")
+	}
+}
+
+func TestWrite_SARIF(t *testing.T) {
+	findings := Source(context.Background(), "main.tf", []byte(testConfig))
+
+	buf := &bytes.Buffer{}
+	if err := Write(buf, FormatSARIF, "test", findings); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	var log struct {
+		Version string `json:"version"`
+		Runs    []struct {
+			Results []struct {
+				RuleID string `json:"ruleId"`
+				Level  string `json:"level"`
+			} `json:"results"`
+		} `json:"runs"`
+	}
+
+	if err := json.Unmarshal(buf.Bytes(), &log); err != nil {
+		t.Fatalf("expected valid JSON: %s", err)
+	}
+
+	if log.Version != "2.1.0" || len(log.Runs) != 1 || len(log.Runs[0].Results) != 2 {
+		t.Fatalf("unexpected SARIF log: %s", buf.String())
+	}
+
+	if r := log.Runs[0].Results[0]; r.RuleID != "detective-args" || r.Level != "error" {
+		t.Fatalf("unexpected result: %+v", r)
+	}
+
+	if err := Write(buf, "xml", "test", findings); err == nil {
+		t.Fatal("expected an error for an unknown format")
+	}
+}
+
+func TestSource_RuleIDs(t *testing.T) {
+	// Summaries of attribute validations include the path and value, rule IDs must not
+	src := `
+resource "streamdal_pipeline" "paths" {
+  name = "Paths"
+
+  step {
+    name = "First"
+
+    detective {
+      type = "has_field"
+      path = "object..email"
+    }
+  }
+
+  step {
+    name = "Second"
+
+    detective {
+      type = "has_field"
+      path = "user..id"
+    }
+  }
+}
+`
+
+	findings := Source(context.Background(), "main.tf", []byte(src))
+	if len(findings) != 2 {
+		t.Fatalf("expected 2 findings, got %+v", findings)
+	}
+
+	for _, f := range findings {
+		if f.Rule != "invalid-attribute" {
+			t.Errorf("expected rule invalid-attribute, got '%s' for: %s", f.Rule, f.Summary)
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	if err := Write(buf, FormatSARIF, "test", findings); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	var log struct {
+		Runs []struct {
+			Tool struct {
+				Driver struct {
+					Rules []struct {
+						ID string `json:"id"`
+					} `json:"rules"`
+				} `json:"driver"`
+			} `json:"tool"`
+		} `json:"runs"`
+	}
+
+	if err := json.Unmarshal(buf.Bytes(), &log); err != nil {
+		t.Fatalf("expected valid JSON: %s", err)
+	}
+
+	if rules := log.Runs[0].Tool.Driver.Rules; len(rules) != 1 || rules[0].ID != "invalid-attribute" {
+		t.Fatalf("expected a single rule, got %+v", rules)
+	}
+}
+
+func TestRun_NoSDKLogs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "main.tf")
+
+	// Unknown lists make the plugin SDK log "[DEBUG] setting computed for ..."
+	src := `
+variable "words" {}
+
+resource "streamdal_pipeline" "words" {
+  name = "Words"
+
+  step {
+    name = "Contains"
+
+    detective {
+      type = "string_contains_any"
+      path = "object.text"
+      args = var.words
+    }
+  }
+}
+`
+
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	logs := &bytes.Buffer{}
+	log.SetOutput(logs)
+	defer log.SetOutput(os.Stderr)
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+
+	if code := Run("test", []string{path}, stdout, stderr); code != ExitOK {
+		t.Fatalf("expected exit code %d, got %d: %s%s", ExitOK, code, stdout.String(), stderr.String())
+	}
+
+	if logs.Len() != 0 || stderr.Len() != 0 {
+		t.Fatalf("expected no log output, got: %s%s", logs.String(), stderr.String())
+	}
+
+	// The logger is restored afterwards
+	log.Print("restored")
+
+	if logs.String() == "" {
+		t.Fatal("expected the log output to be restored")
+	}
+}
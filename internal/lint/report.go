@@ -0,0 +1,197 @@
+package lint
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// Output formats
+const (
+	FormatHuman = "human"
+	FormatJSON  = "json"
+	FormatSARIF = "sarif"
+)
+
+// Formats are the supported output formats
+var Formats = []string{FormatHuman, FormatJSON, FormatSARIF}
+
+// Write renders the findings in the given format
+func Write(w io.Writer, format, version string, findings []Finding) error {
+	switch format {
+	case FormatHuman:
+		return writeHuman(w, findings)
+	case FormatJSON:
+		return writeJSON(w, findings)
+	case FormatSARIF:
+		return writeSARIF(w, version, findings)
+	}
+
+	return fmt.Errorf("unknown format '%s', expected one of: %s", format, strings.Join(Formats, ", "))
+}
+
+func writeHuman(w io.Writer, findings []Finding) error {
+	for _, f := range findings {
+		fmt.Fprintf(w, "%s: %s: %s\n", line(f), f.Severity, f.Summary)
+
+		location := f.Resource
+		if f.Attribute != "" {
+			location += "." + f.Attribute
+		}
+
+		if location != "" {
+			fmt.Fprintf(w, "  on %s\n", location)
+		}
+
+		if f.Detail != "" {
+			fmt.Fprintf(w, "  %s\n", strings.ReplaceAll(f.Detail, "\n", "\n  "))
+		}
+
+		fmt.Fprintln(w)
+	}
+
+	errs, warnings := Counts(findings)
+	_, err := fmt.Fprintf(w, "%d error(s), %d warning(s)\n", errs, warnings)
+
+	return err
+}
+
+type jsonPosition struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+type jsonFinding struct {
+	Finding
+	Start *jsonPosition `json:"start,omitempty"`
+	End   *jsonPosition `json:"end,omitempty"`
+}
+
+func writeJSON(w io.Writer, findings []Finding) error {
+	out := struct {
+		Findings []jsonFinding `json:"findings"`
+		Errors   int           `json:"errors"`
+		Warnings int           `json:"warnings"`
+	}{
+		Findings: make([]jsonFinding, 0, len(findings)),
+	}
+
+	out.Errors, out.Warnings = Counts(findings)
+
+	for _, f := range findings {
+		jf := jsonFinding{Finding: f}
+
+		if f.Range.Start.Line > 0 {
+			jf.Start = &jsonPosition{Line: f.Range.Start.Line, Column: f.Range.Start.Column}
+			jf.End = &jsonPosition{Line: f.Range.End.Line, Column: f.Range.End.Column}
+		}
+
+		out.Findings = append(out.Findings, jf)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(out)
+}
+
+func writeSARIF(w io.Writer, version string, findings []Finding) error {
+	type message struct {
+		Text string `json:"text"`
+	}
+
+	type rule struct {
+		ID               string  `json:"id"`
+		ShortDescription message `json:"shortDescription"`
+	}
+
+	type region struct {
+		StartLine   int `json:"startLine"`
+		StartColumn int `json:"startColumn"`
+		EndLine     int `json:"endLine"`
+		EndColumn   int `json:"endColumn"`
+	}
+
+	type artifactLocation struct {
+		URI string `json:"uri"`
+	}
+
+	type physicalLocation struct {
+		ArtifactLocation artifactLocation `json:"artifactLocation"`
+		Region           *region          `json:"region,omitempty"`
+	}
+
+	type location struct {
+		PhysicalLocation physicalLocation `json:"physicalLocation"`
+	}
+
+	type result struct {
+		RuleID    string     `json:"ruleId"`
+		Level     string     `json:"level"`
+		Message   message    `json:"message"`
+		Locations []location `json:"locations"`
+	}
+
+	rules := make([]rule, 0)
+	seen := make(map[string]bool)
+	results := make([]result, 0, len(findings))
+
+	for _, f := range findings {
+		id := f.Rule
+		if !seen[id] {
+			seen[id] = true
+			rules = append(rules, rule{ID: id, ShortDescription: message{Text: ruleDescriptions[id]}})
+		}
+
+		text := f.Summary
+		if f.Detail != "" {
+			text += ": " + f.Detail
+		}
+
+		if f.Resource != "" {
+			text = fmt.Sprintf("%s (%s)", text, strings.TrimSuffix(f.Resource+"."+f.Attribute, "."))
+		}
+
+		loc := physicalLocation{ArtifactLocation: artifactLocation{URI: filepath.ToSlash(f.File)}}
+		if f.Range.Start.Line > 0 {
+			loc.Region = &region{
+				StartLine:   f.Range.Start.Line,
+				StartColumn: f.Range.Start.Column,
+				EndLine:     f.Range.End.Line,
+				EndColumn:   f.Range.End.Column,
+			}
+		}
+
+		results = append(results, result{
+			RuleID:    id,
+			Level:     f.Severity,
+			Message:   message{Text: text},
+			Locations: []location{{PhysicalLocation: loc}},
+		})
+	}
+
+	log := map[string]interface{}{
+		"$schema": "https://json.schemastore.org/sarif-2.1.0.json",
+		"version": "2.1.0",
+		"runs": []interface{}{
+			map[string]interface{}{
+				"tool": map[string]interface{}{
+					"driver": map[string]interface{}{
+						"name":           "terraform-provider-streamdal",
+						"version":        version,
+						"informationUri": "https://github.com/streamdal/terraform-provider-streamdal",
+						"rules":          rules,
+					},
+				},
+				"results": results,
+			},
+		},
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(log)
+}
@@ -0,0 +1,234 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+
+	"github.com/streamdal/streamdal/libs/protos/build/go/protos"
+	"github.com/streamdal/terraform-provider-streamdal/internal/schemas"
+	"github.com/streamdal/terraform-provider-streamdal/streamdal"
+	"github.com/streamdal/terraform-provider-streamdal/util"
+)
+
+const (
+	compatibilityOff    = "off"
+	compatibilityWarn   = "warn"
+	compatibilityStrict = "strict"
+)
+
+func getSchemaCompatibilityModes() schema.SchemaValidateFunc {
+	return validation.StringInSlice([]string{compatibilityOff, compatibilityWarn, compatibilityStrict}, false)
+}
+
+// diffSchemaCompatibility compares the schema_validation steps of a pipeline against
+// the schemas the server inferred from the traffic of the audiences the pipeline is
+// assigned to. In strict mode incompatibilities fail the plan, otherwise they are
+// reported in schema_compatibility_report. The check runs on every plan, since live
+// traffic changes without the pipeline changing. In warn mode it is skipped when
+// the server can't be reached.
+func diffSchemaCompatibility(ctx context.Context, d *schema.ResourceDiff, m interface{}) error {
+	client, ok := m.(*streamdal.Streamdal)
+	if !ok || client == nil {
+		return nil
+	}
+
+	mode := d.Get("schema_compatibility").(string)
+
+	// With mode off, any report left over from a previous mode is cleared
+	report := make([]interface{}, 0)
+
+	if mode != compatibilityOff {
+		audiences, err := pipelineAudiences(ctx, d, client)
+		if err != nil {
+			if mode == compatibilityWarn && serverUnreachable(err) {
+				return nil
+			}
+
+			return fmt.Errorf("unable to determine audiences for schema compatibility check: %s", err)
+		}
+
+		msgs := schemaCompatibilityReport(ctx, client, audiences, d.Get("step").([]interface{}))
+
+		if mode == compatibilityStrict && len(msgs) > 0 {
+			return fmt.Errorf("schema_validation steps are incompatible with live traffic:\n%s", strings.Join(msgs, "\n"))
+		}
+
+		for _, msg := range msgs {
+			report = append(report, msg)
+		}
+	}
+
+	if reflect.DeepEqual(report, d.Get("schema_compatibility_report").([]interface{})) {
+		return nil
+	}
+
+	return d.SetNew("schema_compatibility_report", report)
+}
+
+// pipelineAudiences returns the audiences the pipeline is assigned to, including
+// those configured in its audiences{} blocks which it isn't assigned to yet
+func pipelineAudiences(ctx context.Context, d *schema.ResourceDiff, client *streamdal.Streamdal) ([]*protos.Audience, error) {
+	audiences := make([]*protos.Audience, 0)
+
+	if d.Id() != "" {
+		assigned, err := client.GetAudiencesForPipeline(ctx, d.Id())
+		if err != nil {
+			return nil, err
+		}
+
+		audiences = append(audiences, assigned...)
+	}
+
+	if !d.NewValueKnown("audiences") {
+		return audiences, nil
+	}
+
+	configured, err := configuredPipelineAudiences(d.Get("audiences").([]interface{}))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, aud := range configured {
+		if !util.AudienceInList(aud, audiences) {
+			audiences = append(audiences, aud)
+		}
+	}
+
+	return audiences, nil
+}
+
+// schemaCompatibilityReport returns a message for each way live traffic on the given
+// audiences may fail the pipeline's schema_validation steps
+func schemaCompatibilityReport(ctx context.Context, client *streamdal.Streamdal, audiences []*protos.Audience, pipelineSteps []interface{}) []string {
+	report := make([]string, 0)
+
+	// Audiences whose schema can't be fetched are skipped, they are warned about
+	// on apply by schemaFetchWarnings instead of ending up in the report
+	observed, _ := inferredSchemas(ctx, client, audiences)
+
+	for _, step := range pipelineSteps {
+		stepMap, _ := step.(map[string]interface{})
+
+		// Only a "match" condition fails payloads which don't satisfy the schema
+		svCfg := firstBlock(stepMap["schema_validation"])
+		if svCfg == nil || svCfg["condition"] != "match" {
+			continue
+		}
+
+		jsCfg := jsonSchemaConfig(stepMap)
+		if jsCfg == nil || jsCfg["json_schema"] == unknownVariableValue || schemaSourceUnknown(jsCfg) {
+			continue
+		}
+
+		doc, err := jsonSchemaDocument(jsCfg)
+		if err != nil {
+			continue
+		}
+
+		for _, aud := range audiences {
+			audStr := util.AudienceToStr(aud)
+
+			inferred, ok := observed[audStr]
+			if !ok {
+				continue
+			}
+
+			found, err := schemas.Compare(doc, inferred)
+			if err != nil {
+				report = append(report, fmt.Sprintf("audience '%s', step '%s': %s", audStr, stepMap["name"], err))
+				continue
+			}
+
+			for _, incompat := range found {
+				report = append(report, fmt.Sprintf("audience '%s', step '%s': %s", audStr, stepMap["name"], incompat))
+			}
+		}
+	}
+
+	return report
+}
+
+// inferredSchemas fetches the schemas the server inferred for the given audiences,
+// keyed by audience string. Audiences without a schema yet are left out, a warning
+// is returned for each audience whose schema can't be fetched.
+func inferredSchemas(ctx context.Context, client *streamdal.Streamdal, audiences []*protos.Audience) (map[string][]byte, diag.Diagnostics) {
+	var diags diag.Diagnostics
+
+	observed := make(map[string][]byte)
+
+	for _, aud := range audiences {
+		audStr := util.AudienceToStr(aud)
+
+		resp, err := client.GetSchema(ctx, aud)
+		if err != nil {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Warning,
+				Summary:  "Unable to check schema compatibility",
+				Detail:   fmt.Sprintf("Unable to fetch the inferred schema for audience '%s': %s", audStr, err),
+			})
+			continue
+		}
+
+		if len(resp.GetJsonSchema()) > 0 {
+			observed[audStr] = resp.GetJsonSchema()
+		}
+	}
+
+	return observed, diags
+}
+
+// schemaFetchWarnings warns about audiences of the pipeline whose inferred schema
+// couldn't be fetched, and which the compatibility check therefore skipped
+func schemaFetchWarnings(ctx context.Context, d *schema.ResourceData, client *streamdal.Streamdal) diag.Diagnostics {
+	if d.Get("schema_compatibility").(string) == compatibilityOff || !hasSchemaValidationSteps(d.Get("step").([]interface{})) {
+		return nil
+	}
+
+	audiences, err := client.GetAudiencesForPipeline(ctx, d.Id())
+	if err != nil {
+		return diag.Diagnostics{{
+			Severity: diag.Warning,
+			Summary:  "Unable to check schema compatibility",
+			Detail:   fmt.Sprintf("Unable to determine the audiences of the pipeline: %s", err),
+		}}
+	}
+
+	_, diags := inferredSchemas(ctx, client, audiences)
+
+	return diags
+}
+
+// hasSchemaValidationSteps returns true if any of the steps is a schema_validation step
+func hasSchemaValidationSteps(pipelineSteps []interface{}) bool {
+	for _, step := range pipelineSteps {
+		stepMap, _ := step.(map[string]interface{})
+
+		if firstBlock(stepMap["schema_validation"]) != nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+// schemaCompatibilityWarnings turns the compatibility report into warnings, so
+// that it is shown when the plan is applied
+func schemaCompatibilityWarnings(d *schema.ResourceData) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	for _, msg := range interfaceToStrings(d.Get("schema_compatibility_report")) {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Warning,
+			Summary:  "Schema validation step is incompatible with live traffic",
+			Detail:   msg,
+		})
+	}
+
+	return diags
+}
@@ -0,0 +1,143 @@
+package provider
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/streamdal/streamdal/libs/protos/build/go/protos"
+)
+
+func TestSchemaCompatibilityReport(t *testing.T) {
+	aud := &protos.Audience{
+		ServiceName:   "signups",
+		ComponentName: "kafka",
+		OperationType: protos.OperationType_OPERATION_TYPE_CONSUMER,
+		OperationName: "new-users",
+	}
+
+	client := newFakeStreamdal(&fakeExternalClient{
+		schemas: map[string]*protos.Schema{
+			"signups:operation_type_consumer:new-users:kafka": {
+				JsonSchema: []byte(`{"type":"object","required":["id"],"properties":{"id":{"type":"integer"},"email":{"type":"string"}}}`),
+			},
+		},
+	})
+
+	schemaStep := func(name, condition, doc string) interface{} {
+		return map[string]interface{}{
+			"name": name,
+			"schema_validation": []interface{}{
+				map[string]interface{}{
+					"type":      "jsonschema",
+					"condition": condition,
+					"json_schema": []interface{}{
+						map[string]interface{}{"draft": "draft_07", "json_schema": doc},
+					},
+				},
+			},
+		}
+	}
+
+	pipelineSteps := []interface{}{
+		schemaStep("tightened", "match", `{"type":"object","required":["id","email"]}`),
+		schemaStep("compatible", "match", `{"type":"object","required":["id"]}`),
+		schemaStep("not matching", "not_match", `{"type":"object","required":["email"]}`),
+	}
+
+	report := schemaCompatibilityReport(context.Background(), client, []*protos.Audience{aud}, pipelineSteps)
+
+	expected := "audience 'signups:operation_type_consumer:new-users:kafka', step 'tightened': " +
+		"field 'email' is required by the schema, but is not always present in live traffic"
+
+	if len(report) != 1 || report[0] != expected {
+		t.Fatalf("unexpected report: %v", report)
+	}
+}
+
+func TestDiffSchemaCompatibility_Unreachable(t *testing.T) {
+	r := resourcePipeline()
+
+	state := &terraform.InstanceState{
+		ID:         "pipeline-id",
+		Attributes: map[string]string{"id": "pipeline-id", "name": "Unchanged"},
+	}
+
+	diff := func(mode string, client interface{}) error {
+		cfg := terraform.NewResourceConfigRaw(map[string]interface{}{
+			"name":                 "Unchanged",
+			"schema_compatibility": mode,
+			"sdk_compatibility":    compatibilityOff,
+		})
+
+		_, err := schema.InternalMap(r.Schema).Diff(context.Background(), state, cfg, func(ctx context.Context, d *schema.ResourceDiff, m interface{}) error {
+			return diffSchemaCompatibility(ctx, d, m)
+		}, client, true)
+
+		return err
+	}
+
+	fake := &fakeExternalClient{getAllErr: status.Error(codes.Unavailable, "connection refused")}
+
+	if err := diff(compatibilityWarn, newFakeStreamdal(fake)); err != nil {
+		t.Fatalf("expected the check to be skipped in warn mode, got: %s", err)
+	}
+
+	if err := diff(compatibilityStrict, newFakeStreamdal(fake)); err == nil {
+		t.Fatal("expected strict mode to fail when the server can't be reached")
+	}
+
+	fake.getAllErr = status.Error(codes.PermissionDenied, "invalid token")
+
+	if err := diff(compatibilityWarn, newFakeStreamdal(fake)); err == nil {
+		t.Fatal("expected other errors to fail the plan")
+	}
+}
+
+func TestSchemaFetchWarnings(t *testing.T) {
+	aud := testAudience("signups", "kafka", "new-users", protos.OperationType_OPERATION_TYPE_CONSUMER)
+
+	client := newFakeStreamdal(&fakeExternalClient{
+		getAll: &protos.GetAllResponse{
+			Pipelines: map[string]*protos.PipelineInfo{"pipeline-id": {Audiences: []*protos.Audience{aud}}},
+		},
+	})
+
+	pipelineSteps := []interface{}{
+		map[string]interface{}{
+			"name": "match",
+			"schema_validation": []interface{}{
+				map[string]interface{}{
+					"type":      "jsonschema",
+					"condition": "match",
+					"json_schema": []interface{}{
+						map[string]interface{}{"draft": "draft_07", "json_schema": `{"type":"object"}`},
+					},
+				},
+			},
+		},
+	}
+
+	// A schema which can't be fetched isn't part of the report
+	if report := schemaCompatibilityReport(context.Background(), client, []*protos.Audience{aud}, pipelineSteps); len(report) != 0 {
+		t.Fatalf("expected an empty report, got: %v", report)
+	}
+
+	d := schema.TestResourceDataRaw(t, resourcePipeline().Schema, map[string]interface{}{
+		"name": "Signups",
+		"step": pipelineSteps,
+	})
+	d.SetId("pipeline-id")
+
+	diags := schemaFetchWarnings(context.Background(), d, client)
+	if len(diags) != 1 || diags[0].Severity != diag.Warning ||
+		!strings.Contains(diags[0].Detail, "signups:operation_type_consumer:new-users:kafka") {
+		t.Fatalf("expected a warning for the audience, got: %v", diags)
+	}
+}
@@ -0,0 +1,187 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+
+	"github.com/streamdal/streamdal/libs/protos/build/go/protos"
+	"github.com/streamdal/terraform-provider-streamdal/streamdal"
+	"github.com/streamdal/terraform-provider-streamdal/util"
+)
+
+func dataSourceAudienceRates() *schema.Resource {
+	return &schema.Resource{
+		Description: "Throughput rates of each audience, averaged over a sampling window",
+
+		ReadContext: dataSourceAudienceRatesRead,
+		Schema: map[string]*schema.Schema{
+			"audience": {
+				Description: "Only return the rates of this audience string, ie. the ID of a streamdal_audience",
+				Type:        schema.TypeString,
+				Optional:    true,
+			},
+			"service_name": {
+				Description: "Only return the rates of audiences of this service",
+				Type:        schema.TypeString,
+				Optional:    true,
+			},
+			"window": {
+				Description:  "How long to sample the rates for, as a duration such as `10s` or `1m`",
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "10s",
+				ValidateFunc: validateDuration(),
+			},
+			"samples": {
+				Description: "Number of samples received during the window",
+				Type:        schema.TypeInt,
+				Computed:    true,
+			},
+			"rates": {
+				Description: "Averaged rates of each audience matching the filters, including audiences without traffic",
+				Type:        schema.TypeList,
+				Computed:    true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"audience": {
+							Description: "Audience string",
+							Type:        schema.TypeString,
+							Computed:    true,
+						},
+						"bytes": {
+							Description: "Average bytes per second",
+							Type:        schema.TypeFloat,
+							Computed:    true,
+						},
+						"processed": {
+							Description: "Average payloads processed per second",
+							Type:        schema.TypeFloat,
+							Computed:    true,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func dataSourceAudienceRatesRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	client := m.(*streamdal.Streamdal)
+
+	window, err := time.ParseDuration(d.Get("window").(string))
+	if err != nil {
+		return diag.Errorf("Error reading audience rates: %s", err)
+	}
+
+	// Audiences without traffic don't show up in the rates at all
+	all, err := client.GetAll(ctx)
+	if err != nil {
+		return diag.Errorf("Error reading audience rates: %s", err)
+	}
+
+	samples, err := sampleAudienceRates(ctx, client, window)
+	if err != nil {
+		return diag.Errorf("Error reading audience rates: %s", err)
+	}
+
+	averages := averageAudienceRates(samples)
+
+	for _, aud := range all.GetAudiences() {
+		if _, ok := averages[util.AudienceToStr(aud)]; !ok {
+			averages[util.AudienceToStr(aud)] = &protos.AudienceRate{}
+		}
+	}
+
+	audStr := d.Get("audience").(string)
+	serviceName := d.Get("service_name").(string)
+
+	ids := make([]string, 0, len(averages))
+	for id := range averages {
+		switch aud := util.AudienceFromStr(id); {
+		case audStr != "" && id != audStr:
+		case serviceName != "" && (aud == nil || aud.GetServiceName() != serviceName):
+		default:
+			ids = append(ids, id)
+		}
+	}
+
+	sort.Strings(ids)
+
+	rates := make([]interface{}, 0, len(ids))
+	for _, id := range ids {
+		rates = append(rates, map[string]interface{}{
+			"audience":  id,
+			"bytes":     averages[id].GetBytes(),
+			"processed": averages[id].GetProcessed(),
+		})
+	}
+
+	d.SetId(strconv.FormatInt(time.Now().UnixNano(), 10))
+	_ = d.Set("samples", len(samples))
+	_ = d.Set("rates", rates)
+
+	return diags
+}
+
+// sampleAudienceRates collects the rates sent by GetAudienceRates during the window
+func sampleAudienceRates(ctx context.Context, client *streamdal.Streamdal, window time.Duration) ([]map[string]*protos.AudienceRate, error) {
+	ctx, cancel := context.WithTimeout(ctx, window)
+	defer cancel()
+
+	stream, err := client.GetAudienceRates(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	samples := make([]map[string]*protos.AudienceRate, 0)
+
+	for {
+		resp, err := stream.Recv()
+		if err != nil {
+			if ctx.Err() == nil {
+				return nil, err
+			}
+
+			break
+		}
+
+		if resp.GetXKeepalive() {
+			continue
+		}
+
+		samples = append(samples, resp.GetRates())
+	}
+
+	if len(samples) == 0 {
+		return nil, fmt.Errorf("no rates received within %s", window)
+	}
+
+	return samples, nil
+}
+
+// averageAudienceRates averages the rates of each audience over all samples. An
+// audience missing from a sample had no traffic at the time, and counts as zero.
+func averageAudienceRates(samples []map[string]*protos.AudienceRate) map[string]*protos.AudienceRate {
+	averages := make(map[string]*protos.AudienceRate)
+
+	for _, sample := range samples {
+		for id, rate := range sample {
+			if _, ok := averages[id]; !ok {
+				averages[id] = &protos.AudienceRate{}
+			}
+
+			averages[id].Bytes += rate.GetBytes() / float64(len(samples))
+			averages[id].Processed += rate.GetProcessed() / float64(len(samples))
+		}
+	}
+
+	return averages
+}
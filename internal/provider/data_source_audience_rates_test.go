@@ -0,0 +1,69 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+
+	"github.com/streamdal/streamdal/libs/protos/build/go/protos"
+	"github.com/streamdal/terraform-provider-streamdal/util"
+)
+
+func TestDataSourceAudienceRates(t *testing.T) {
+	busy := testAudience("billing-svc", "kafka", "orders", protos.OperationType_OPERATION_TYPE_CONSUMER)
+	bursty := testAudience("billing-svc", "kafka", "invoices", protos.OperationType_OPERATION_TYPE_PRODUCER)
+	dead := testAudience("signups", "kafka", "new-users", protos.OperationType_OPERATION_TYPE_CONSUMER)
+
+	keepalive := true
+
+	fake := &fakeExternalClient{
+		getAll: &protos.GetAllResponse{Audiences: []*protos.Audience{busy, bursty, dead}},
+		rates: []*protos.GetAudienceRatesResponse{
+			{Rates: map[string]*protos.AudienceRate{
+				util.AudienceToStr(busy):   {Bytes: 1000, Processed: 10},
+				util.AudienceToStr(bursty): {Bytes: 300, Processed: 3},
+			}},
+			{XKeepalive: &keepalive},
+			{Rates: map[string]*protos.AudienceRate{
+				util.AudienceToStr(busy): {Bytes: 2000, Processed: 20},
+			}},
+		},
+	}
+
+	rates := func(filters map[string]interface{}) (int, string) {
+		filters["window"] = "20ms"
+		d := schema.TestResourceDataRaw(t, dataSourceAudienceRates().Schema, filters)
+
+		if diags := dataSourceAudienceRatesRead(context.Background(), d, newFakeStreamdal(fake)); diags.HasError() {
+			t.Fatalf("unexpected error: %v", diags)
+		}
+
+		out := make([]string, 0)
+		for _, r := range d.Get("rates").([]interface{}) {
+			rate := r.(map[string]interface{})
+			out = append(out, fmt.Sprintf("%s=%v/%v", util.AudienceFromStr(rate["audience"].(string)).GetOperationName(), rate["bytes"], rate["processed"]))
+		}
+
+		return d.Get("samples").(int), fmt.Sprint(out)
+	}
+
+	samples, got := rates(map[string]interface{}{})
+	if samples != 2 {
+		t.Errorf("expected 2 samples, got %d", samples)
+	}
+
+	// Audience strings sort by service, then operation type
+	if expected := "[orders=1500/15 invoices=150/1.5 new-users=0/0]"; got != expected {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+
+	if _, got := rates(map[string]interface{}{"service_name": "signups"}); got != "[new-users=0/0]" {
+		t.Errorf("unexpected rates for service filter: %s", got)
+	}
+
+	if _, got := rates(map[string]interface{}{"audience": util.AudienceToStr(bursty)}); got != "[invoices=150/1.5]" {
+		t.Errorf("unexpected rates for audience filter: %s", got)
+	}
+}
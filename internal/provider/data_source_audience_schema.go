@@ -0,0 +1,81 @@
+package provider
+
+import (
+	"context"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+
+	"github.com/streamdal/streamdal/libs/protos/build/go/protos"
+	"github.com/streamdal/terraform-provider-streamdal/internal/schemas"
+	"github.com/streamdal/terraform-provider-streamdal/streamdal"
+	"github.com/streamdal/terraform-provider-streamdal/util"
+)
+
+func dataSourceAudienceSchema() *schema.Resource {
+	sch := audienceSchema()
+	sch["json_schema"] = &schema.Schema{
+		Description: "JSON schema inferred from the audience's traffic, in canonical form",
+		Type:        schema.TypeString,
+		Computed:    true,
+	}
+	sch["draft"] = &schema.Schema{
+		Description: "JSON schema draft of the inferred schema, for use in a schema_validation step",
+		Type:        schema.TypeString,
+		Computed:    true,
+	}
+	sch["version"] = &schema.Schema{
+		Description: "Version of the inferred schema. Incremented by the server each time the schema changes",
+		Type:        schema.TypeInt,
+		Computed:    true,
+	}
+	sch["metadata"] = &schema.Schema{
+		Description: "Metadata attached to the schema",
+		Type:        schema.TypeMap,
+		Computed:    true,
+		Elem: &schema.Schema{
+			Type: schema.TypeString,
+		},
+	}
+
+	return &schema.Resource{
+		ReadContext:   dataSourceAudienceSchemaRead,
+		SchemaVersion: 1,
+		Schema:        sch,
+	}
+}
+
+func dataSourceAudienceSchemaRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	s := m.(*streamdal.Streamdal)
+
+	aud := &protos.Audience{
+		ServiceName:   d.Get("service_name").(string),
+		ComponentName: d.Get("component_name").(string),
+		OperationType: audienceOperationTypeFromString(d.Get("operation_type").(string)),
+		OperationName: d.Get("operation_name").(string),
+	}
+
+	resp, err := s.GetSchema(ctx, aud)
+	if err != nil {
+		return diag.Errorf("Error fetching schema for audience '%s': %s", util.AudienceToStr(aud), err)
+	}
+
+	if len(resp.GetJsonSchema()) == 0 {
+		return diag.Errorf("No schema has been inferred for audience '%s' yet", util.AudienceToStr(aud))
+	}
+
+	jsonSchema, err := schemas.Normalize(resp.GetJsonSchema())
+	if err != nil {
+		return diag.Errorf("Error reading schema for audience '%s': schema is not valid JSON: %s", util.AudienceToStr(aud), err)
+	}
+
+	d.SetId(util.AudienceToStr(aud))
+	_ = d.Set("json_schema", string(jsonSchema))
+	_ = d.Set("draft", schemaValidationJSONSchemaDraftToString(schemas.DetectDraft(jsonSchema)))
+	_ = d.Set("version", int(resp.GetXVersion()))
+	_ = d.Set("metadata", resp.GetXMetadata())
+
+	return diags
+}
@@ -0,0 +1,78 @@
+package provider
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+
+	"github.com/streamdal/streamdal/libs/protos/build/go/protos"
+)
+
+func TestDataSourceAudienceSchema(t *testing.T) {
+	fake := &fakeExternalClient{
+		schemas: map[string]*protos.Schema{
+			"signups:operation_type_consumer:new-users:kafka": {
+				JsonSchema: []byte(`{
+  "type": "object",
+  "$schema": "http://json-schema.org/draft-04/schema#"
+}`),
+				XVersion:  3,
+				XMetadata: map[string]string{"source": "inferred"},
+			},
+			"signups:operation_type_producer:new-users:kafka": {},
+		},
+	}
+
+	read := func(opType string) (*schema.ResourceData, string) {
+		d := schema.TestResourceDataRaw(t, dataSourceAudienceSchema().Schema, map[string]interface{}{
+			"service_name":   "signups",
+			"component_name": "kafka",
+			"operation_type": opType,
+			"operation_name": "new-users",
+		})
+
+		diags := dataSourceAudienceSchemaRead(context.Background(), d, newFakeStreamdal(fake))
+		if diags.HasError() {
+			return d, diags[0].Summary
+		}
+
+		return d, ""
+	}
+
+	d, errMsg := read("consumer")
+	if errMsg != "" {
+		t.Fatalf("unexpected error: %s", errMsg)
+	}
+
+	if got := d.Id(); got != "signups:operation_type_consumer:new-users:kafka" {
+		t.Errorf("unexpected ID: %s", got)
+	}
+
+	if expected := `{"$schema":"http://json-schema.org/draft-04/schema#","type":"object"}`; d.Get("json_schema") != expected {
+		t.Errorf("expected normalized schema, got: %s", d.Get("json_schema"))
+	}
+
+	if got := d.Get("draft"); got != "draft_04" {
+		t.Errorf("expected draft_04 to be detected, got: %s", got)
+	}
+
+	if got := d.Get("version"); got != 3 {
+		t.Errorf("expected version 3, got: %v", got)
+	}
+
+	if got := d.Get("metadata").(map[string]interface{}); len(got) != 1 || got["source"] != "inferred" {
+		t.Errorf("unexpected metadata: %v", got)
+	}
+
+	// A schema that hasn't been inferred yet is returned empty
+	if _, errMsg := read("producer"); !strings.Contains(errMsg, "No schema has been inferred") {
+		t.Errorf("expected an error for an empty schema, got: %q", errMsg)
+	}
+
+	fake.schemas = nil
+	if _, errMsg := read("consumer"); !strings.Contains(errMsg, "Error fetching schema") {
+		t.Errorf("expected an error for a missing audience, got: %q", errMsg)
+	}
+}
@@ -0,0 +1,119 @@
+package provider
+
+import (
+	"context"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+
+	"github.com/streamdal/terraform-provider-streamdal/internal/schemas"
+)
+
+func dataSourceInferredSchema() *schema.Resource {
+	return &schema.Resource{
+		ReadContext:   dataSourceInferredSchemaRead,
+		SchemaVersion: 1,
+		Schema: map[string]*schema.Schema{
+			"documents": {
+				Description: "Sample JSON documents to infer the schema from",
+				Type:        schema.TypeList,
+				Required:    true,
+				MinItems:    1,
+				Elem: &schema.Schema{
+					Type:         schema.TypeString,
+					ValidateFunc: validation.StringIsJSON,
+				},
+			},
+			"draft": {
+				Description:  "JSON Schema Draft of the generated schema",
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "draft_07",
+				ValidateFunc: getSchemaValidationJSONSchemaDrafts(),
+			},
+			"max_enum_values": {
+				Description:  "The most distinct values a string field may have to be reported as an enum candidate. 0 disables enum detection",
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      10,
+				ValidateFunc: validation.IntAtLeast(0),
+			},
+			"include_enums": {
+				Description: "Restrict enum candidates to their sampled values in the generated schema",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+			},
+			"json_schema": {
+				Description: "The inferred JSON schema",
+				Type:        schema.TypeString,
+				Computed:    true,
+			},
+			"enum_candidates": {
+				Description: "String fields which only had a few distinct, repeating values in the samples",
+				Type:        schema.TypeList,
+				Computed:    true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"path": {
+							Description: "Path of the field",
+							Type:        schema.TypeString,
+							Computed:    true,
+						},
+						"values": {
+							Description: "Distinct values of the field",
+							Type:        schema.TypeList,
+							Computed:    true,
+							Elem: &schema.Schema{
+								Type: schema.TypeString,
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func dataSourceInferredSchemaRead(_ context.Context, d *schema.ResourceData, _ interface{}) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	draft, err := schemaValidationJSONSchemaDraftFromString(d.Get("draft").(string))
+	if err != nil {
+		return diag.Errorf("Error inferring schema: %s", err)
+	}
+
+	docs := make([][]byte, 0)
+	for _, doc := range interfaceToStrings(d.Get("documents")) {
+		docs = append(docs, []byte(doc))
+	}
+
+	jsonSchema, candidates, err := schemas.Infer(docs, schemas.InferOptions{
+		Draft:         draft,
+		MaxEnumValues: d.Get("max_enum_values").(int),
+		IncludeEnums:  d.Get("include_enums").(bool),
+	})
+	if err != nil {
+		return diag.Errorf("Error inferring schema: %s", err)
+	}
+
+	hash, err := schemas.Hash(jsonSchema)
+	if err != nil {
+		return diag.Errorf("Error inferring schema: %s", err)
+	}
+
+	enumCandidates := make([]interface{}, 0, len(candidates))
+	for _, c := range candidates {
+		enumCandidates = append(enumCandidates, map[string]interface{}{
+			"path":   c.Path,
+			"values": c.Values,
+		})
+	}
+
+	d.SetId(hash)
+	_ = d.Set("json_schema", string(jsonSchema))
+	_ = d.Set("enum_candidates", enumCandidates)
+
+	return diags
+}
@@ -0,0 +1,170 @@
+package provider
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+
+	"github.com/streamdal/streamdal/libs/protos/build/go/protos"
+	"github.com/streamdal/terraform-provider-streamdal/streamdal"
+	"github.com/streamdal/terraform-provider-streamdal/util"
+)
+
+func dataSourceLiveClients() *schema.Resource {
+	return &schema.Resource{
+		Description: "SDK clients currently connected to the server",
+
+		ReadContext: dataSourceLiveClientsRead,
+		Schema: map[string]*schema.Schema{
+			"audience": {
+				Description: "Only return clients which announced this audience string, ie. the ID of a streamdal_audience",
+				Type:        schema.TypeString,
+				Optional:    true,
+			},
+			"service_name": {
+				Description: "Only return clients which announced an audience of this service",
+				Type:        schema.TypeString,
+				Optional:    true,
+			},
+			"operation_type": {
+				Description:  "Only return clients which announced an audience of this operation type, either `consumer` or `producer`",
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: getAudienceOperationTypes(),
+			},
+			"clients": {
+				Description: "Connected clients matching all of the filters",
+				Type:        schema.TypeList,
+				Computed:    true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"session_id": {
+							Description: "Session ID of the client",
+							Type:        schema.TypeString,
+							Computed:    true,
+						},
+						"service_name": {
+							Description: "Service name the client registered with",
+							Type:        schema.TypeString,
+							Computed:    true,
+						},
+						"node_name": {
+							Description: "Name of the server node the client is connected to",
+							Type:        schema.TypeString,
+							Computed:    true,
+						},
+						"client_type": {
+							Description: "Type of the client, either `sdk` or `shim`",
+							Type:        schema.TypeString,
+							Computed:    true,
+						},
+						"library_name": {
+							Description: "Name of the SDK library",
+							Type:        schema.TypeString,
+							Computed:    true,
+						},
+						"library_version": {
+							Description: "Version of the SDK library",
+							Type:        schema.TypeString,
+							Computed:    true,
+						},
+						"language": {
+							Description: "Language the client is written in",
+							Type:        schema.TypeString,
+							Computed:    true,
+						},
+						"os": {
+							Description: "Operating system of the client",
+							Type:        schema.TypeString,
+							Computed:    true,
+						},
+						"arch": {
+							Description: "CPU architecture of the client",
+							Type:        schema.TypeString,
+							Computed:    true,
+						},
+						"audiences": {
+							Description: "Audience strings the client announced",
+							Type:        schema.TypeList,
+							Computed:    true,
+							Elem: &schema.Schema{
+								Type: schema.TypeString,
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func dataSourceLiveClientsRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	client := m.(*streamdal.Streamdal)
+
+	var aud *protos.Audience
+	if v := d.Get("audience").(string); v != "" {
+		if aud = util.AudienceFromStr(v); aud == nil {
+			return diag.Errorf("Error reading live clients: invalid audience '%s'", v)
+		}
+	}
+
+	all, err := client.GetAll(ctx)
+	if err != nil {
+		return diag.Errorf("Error reading live clients: %s", err)
+	}
+
+	serviceName := d.Get("service_name").(string)
+	operationType := d.Get("operation_type").(string)
+
+	clients := make([]interface{}, 0)
+
+	for _, live := range all.GetLive() {
+		if !liveClientMatches(live, aud, serviceName, operationType) {
+			continue
+		}
+
+		info := live.GetClient()
+
+		clients = append(clients, map[string]interface{}{
+			"session_id":      info.GetXSessionId(),
+			"service_name":    info.GetXServiceName(),
+			"node_name":       info.GetXNodeName(),
+			"client_type":     clientTypeToString(info.GetClientType()),
+			"library_name":    info.GetLibraryName(),
+			"library_version": info.GetLibraryVersion(),
+			"language":        info.GetLanguage(),
+			"os":              info.GetOs(),
+			"arch":            info.GetArch(),
+			"audiences":       audienceStrings(live.GetAudiences()),
+		})
+	}
+
+	d.SetId(strconv.FormatInt(all.GetGeneratedAtUnixTsNsUtc(), 10))
+	_ = d.Set("clients", clients)
+
+	return diags
+}
+
+// liveClientMatches returns true if any of the audiences announced by the client
+// matches all of the filters which are set. Without filters, every client matches.
+func liveClientMatches(live *protos.LiveInfo, aud *protos.Audience, serviceName, operationType string) bool {
+	if aud == nil && serviceName == "" && operationType == "" {
+		return true
+	}
+
+	for _, a := range live.GetAudiences() {
+		switch {
+		case aud != nil && util.AudienceToStr(a) != util.AudienceToStr(aud):
+		case serviceName != "" && a.GetServiceName() != serviceName:
+		case operationType != "" && audienceOperationTypeToString(a.GetOperationType()) != operationType:
+		default:
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,68 @@
+package provider
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+
+	"github.com/streamdal/streamdal/libs/protos/build/go/protos"
+	"github.com/streamdal/terraform-provider-streamdal/util"
+)
+
+func TestDataSourceLiveClients(t *testing.T) {
+	consumer := testAudience("billing-svc", "kafka", "orders", protos.OperationType_OPERATION_TYPE_CONSUMER)
+	producer := testAudience("billing-svc", "kafka", "invoices", protos.OperationType_OPERATION_TYPE_PRODUCER)
+	other := testAudience("signups", "kafka", "new-users", protos.OperationType_OPERATION_TYPE_CONSUMER)
+
+	session := func(id string) *string { return &id }
+
+	fake := &fakeExternalClient{getAll: &protos.GetAllResponse{
+		Live: []*protos.LiveInfo{
+			{Audiences: []*protos.Audience{consumer}, Client: &protos.ClientInfo{XSessionId: session("a"), LibraryVersion: "0.1.0"}},
+			{Audiences: []*protos.Audience{producer}, Client: &protos.ClientInfo{XSessionId: session("b"), LibraryVersion: "0.2.0"}},
+			{Audiences: []*protos.Audience{other, consumer}, Client: &protos.ClientInfo{XSessionId: session("c"), ClientType: protos.ClientType_CLIENT_TYPE_SDK}},
+			{Client: &protos.ClientInfo{XSessionId: session("d")}},
+		},
+	}}
+
+	cases := map[string]struct {
+		filters  map[string]interface{}
+		expected []string
+	}{
+		"no filters":     {filters: map[string]interface{}{}, expected: []string{"a", "b", "c", "d"}},
+		"service":        {filters: map[string]interface{}{"service_name": "billing-svc"}, expected: []string{"a", "b", "c"}},
+		"live consumers": {filters: map[string]interface{}{"service_name": "billing-svc", "operation_type": "consumer"}, expected: []string{"a", "c"}},
+		"audience":       {filters: map[string]interface{}{"audience": util.AudienceToStr(other)}, expected: []string{"c"}},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			d := schema.TestResourceDataRaw(t, dataSourceLiveClients().Schema, tc.filters)
+
+			if diags := dataSourceLiveClientsRead(context.Background(), d, newFakeStreamdal(fake)); diags.HasError() {
+				t.Fatalf("unexpected error: %v", diags)
+			}
+
+			got := make([]string, 0)
+			for _, c := range d.Get("clients").([]interface{}) {
+				got = append(got, c.(map[string]interface{})["session_id"].(string))
+			}
+
+			if !sameStrings(got, tc.expected) {
+				t.Errorf("expected sessions %v, got %v", tc.expected, got)
+			}
+		})
+	}
+
+	d := schema.TestResourceDataRaw(t, dataSourceLiveClients().Schema, map[string]interface{}{"audience": util.AudienceToStr(other)})
+	_ = dataSourceLiveClientsRead(context.Background(), d, newFakeStreamdal(fake))
+
+	if got := d.Get("clients.0.client_type").(string); got != "sdk" {
+		t.Errorf("expected client_type sdk, got %s", got)
+	}
+
+	if got := d.Get("clients.0.audiences.#").(int); got != 2 {
+		t.Errorf("expected both announced audiences, got %d", got)
+	}
+}
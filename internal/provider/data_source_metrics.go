@@ -0,0 +1,205 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+
+	"github.com/streamdal/streamdal/libs/protos/build/go/protos"
+	"github.com/streamdal/terraform-provider-streamdal/streamdal"
+	"github.com/streamdal/terraform-provider-streamdal/util"
+)
+
+func dataSourceMetrics() *schema.Resource {
+	return &schema.Resource{
+		Description: "A snapshot of the server's metrics",
+
+		ReadContext: dataSourceMetricsRead,
+		Schema: map[string]*schema.Schema{
+			"name": {
+				Description: "Only return metrics with this name",
+				Type:        schema.TypeString,
+				Optional:    true,
+			},
+			"audience": {
+				Description: "Only return metrics of this audience string, ie. the ID of a streamdal_audience",
+				Type:        schema.TypeString,
+				Optional:    true,
+			},
+			"labels": {
+				Description: "Only return metrics which have all of these labels",
+				Type:        schema.TypeMap,
+				Optional:    true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"timeout": {
+				Description:  "How long to wait for the server to send the metrics, as a duration such as `10s`",
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "10s",
+				ValidateFunc: validateDuration(),
+			},
+			"metrics": {
+				Description: "Metrics matching all of the filters",
+				Type:        schema.TypeList,
+				Computed:    true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"name": {
+							Description: "Name of the metric",
+							Type:        schema.TypeString,
+							Computed:    true,
+						},
+						"labels": {
+							Description: "Labels of the metric",
+							Type:        schema.TypeMap,
+							Computed:    true,
+							Elem: &schema.Schema{
+								Type: schema.TypeString,
+							},
+						},
+						"audience": {
+							Description: "Audience string the metric belongs to, if any",
+							Type:        schema.TypeString,
+							Computed:    true,
+						},
+						"value": {
+							Description: "Value of the metric",
+							Type:        schema.TypeFloat,
+							Computed:    true,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func dataSourceMetricsRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	client := m.(*streamdal.Streamdal)
+
+	timeout, err := time.ParseDuration(d.Get("timeout").(string))
+	if err != nil {
+		return diag.Errorf("Error reading metrics: %s", err)
+	}
+
+	snapshot, err := metricsSnapshot(ctx, client, timeout)
+	if err != nil {
+		return diag.Errorf("Error reading metrics: %s", err)
+	}
+
+	name := d.Get("name").(string)
+	audStr := d.Get("audience").(string)
+	labels := d.Get("labels").(map[string]interface{})
+
+	matched := make([]*protos.Metric, 0)
+
+	for _, metric := range snapshot {
+		if metricMatches(metric, name, audStr, labels) {
+			matched = append(matched, metric)
+		}
+	}
+
+	sort.Slice(matched, func(i, j int) bool {
+		return metricSortKey(matched[i]) < metricSortKey(matched[j])
+	})
+
+	metrics := make([]interface{}, 0, len(matched))
+	for _, metric := range matched {
+		metrics = append(metrics, map[string]interface{}{
+			"name":     metric.GetName(),
+			"labels":   metric.GetLabels(),
+			"audience": metricAudience(metric),
+			"value":    metric.GetValue(),
+		})
+	}
+
+	d.SetId(strconv.FormatInt(time.Now().UnixNano(), 10))
+	_ = d.Set("metrics", metrics)
+
+	return diags
+}
+
+// metricsSnapshot returns the first set of metrics sent by GetMetrics. The server
+// sends all of its metrics at once, so a single response is a consistent snapshot.
+func metricsSnapshot(ctx context.Context, client *streamdal.Streamdal, timeout time.Duration) (map[string]*protos.Metric, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	stream, err := client.GetMetrics(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		resp, err := stream.Recv()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, fmt.Errorf("no metrics received within %s", timeout)
+			}
+
+			return nil, err
+		}
+
+		if resp.GetXKeepalive() {
+			continue
+		}
+
+		return resp.GetMetrics(), nil
+	}
+}
+
+// metricMatches returns true if the metric matches all of the filters which are set
+func metricMatches(metric *protos.Metric, name, audStr string, labels map[string]interface{}) bool {
+	if name != "" && metric.GetName() != name {
+		return false
+	}
+
+	if audStr != "" && metricAudience(metric) != audStr {
+		return false
+	}
+
+	for k, v := range labels {
+		if metric.GetLabels()[k] != v.(string) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// metricAudience returns the audience string of a metric, or "" if it has none
+func metricAudience(metric *protos.Metric) string {
+	if metric.GetAudience() == nil {
+		return ""
+	}
+
+	return util.AudienceToStr(metric.GetAudience())
+}
+
+// metricSortKey orders metrics by name, audience and labels, so that results
+// don't change between reads when the values don't
+func metricSortKey(metric *protos.Metric) string {
+	keys := make([]string, 0, len(metric.GetLabels()))
+	for k := range metric.GetLabels() {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	key := metric.GetName() + "\x00" + metricAudience(metric)
+	for _, k := range keys {
+		key += "\x00" + k + "=" + metric.GetLabels()[k]
+	}
+
+	return key
+}
@@ -0,0 +1,73 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+
+	"github.com/streamdal/streamdal/libs/protos/build/go/protos"
+	"github.com/streamdal/terraform-provider-streamdal/util"
+)
+
+func TestDataSourceMetrics(t *testing.T) {
+	aud := testAudience("billing-svc", "kafka", "orders", protos.OperationType_OPERATION_TYPE_CONSUMER)
+	other := testAudience("signups", "kafka", "new-users", protos.OperationType_OPERATION_TYPE_CONSUMER)
+
+	keepalive := true
+
+	fake := &fakeExternalClient{metrics: []*protos.GetMetricsResponse{
+		{XKeepalive: &keepalive},
+		{Metrics: map[string]*protos.Metric{
+			"a": {Name: "counter_error", Audience: aud, Labels: map[string]string{"pipeline_id": "pii"}, Value: 3},
+			"b": {Name: "counter_error", Audience: aud, Labels: map[string]string{"pipeline_id": "other"}, Value: 1},
+			"c": {Name: "counter_error", Audience: other, Labels: map[string]string{"pipeline_id": "pii"}, Value: 7},
+			"d": {Name: "counter_processed", Audience: aud, Labels: map[string]string{"pipeline_id": "pii"}, Value: 100},
+		}},
+		{Metrics: map[string]*protos.Metric{
+			"a": {Name: "counter_error", Audience: aud, Labels: map[string]string{"pipeline_id": "pii"}, Value: 4},
+		}},
+	}}
+
+	cases := map[string]struct {
+		filters  map[string]interface{}
+		expected string
+	}{
+		"all":      {filters: map[string]interface{}{}, expected: "[1 3 7 100]"},
+		"name":     {filters: map[string]interface{}{"name": "counter_error"}, expected: "[1 3 7]"},
+		"audience": {filters: map[string]interface{}{"name": "counter_error", "audience": util.AudienceToStr(aud)}, expected: "[1 3]"},
+		"labels":   {filters: map[string]interface{}{"labels": map[string]interface{}{"pipeline_id": "pii"}}, expected: "[3 7 100]"},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			d := schema.TestResourceDataRaw(t, dataSourceMetrics().Schema, tc.filters)
+
+			if diags := dataSourceMetricsRead(context.Background(), d, newFakeStreamdal(fake)); diags.HasError() {
+				t.Fatalf("unexpected error: %v", diags)
+			}
+
+			values := make([]float64, 0)
+			for _, metric := range d.Get("metrics").([]interface{}) {
+				values = append(values, metric.(map[string]interface{})["value"].(float64))
+			}
+
+			if got := fmt.Sprint(values); got != tc.expected {
+				t.Errorf("expected %s, got %s", tc.expected, got)
+			}
+
+			if got := d.Get("metrics.0.labels.pipeline_id").(string); got == "" {
+				t.Error("expected labels to be set")
+			}
+		})
+	}
+
+	t.Run("timeout", func(t *testing.T) {
+		d := schema.TestResourceDataRaw(t, dataSourceMetrics().Schema, map[string]interface{}{"timeout": "20ms"})
+
+		if diags := dataSourceMetricsRead(context.Background(), d, newFakeStreamdal(&fakeExternalClient{})); !diags.HasError() {
+			t.Fatal("expected an error when no metrics are received")
+		}
+	})
+}
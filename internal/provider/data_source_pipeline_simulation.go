@@ -0,0 +1,275 @@
+package provider
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/streamdal/streamdal/libs/protos/build/go/protos"
+	"github.com/streamdal/terraform-provider-streamdal/internal/simulate"
+	"github.com/streamdal/terraform-provider-streamdal/streamdal"
+)
+
+func dataSourcePipelineSimulation() *schema.Resource {
+	return &schema.Resource{
+		ReadContext:   dataSourcePipelineSimulationRead,
+		SchemaVersion: 1,
+		Schema: map[string]*schema.Schema{
+			"pipeline_id": {
+				Description:  "ID of an existing pipeline to simulate",
+				Type:         schema.TypeString,
+				Optional:     true,
+				ExactlyOneOf: []string{"pipeline_id", "step"},
+			},
+			"step": {
+				Description: "Steps of an unsaved pipeline to simulate",
+				Type:        schema.TypeList,
+				Optional:    true,
+				ConfigMode:  schema.SchemaConfigModeBlock,
+				Elem:        stepSchema(),
+			},
+			"wasm_modules": {
+				Description: "Paths of local Wasm modules to run for step{} blocks, keyed by step type. Step types " +
+					"without a module use the module of an existing pipeline with a step of the same type",
+				Type:     schema.TypeMap,
+				Optional: true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"payloads": {
+				Description: "Sample payloads to run through the pipeline",
+				Type:        schema.TypeList,
+				Required:    true,
+				MinItems:    1,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"results": {
+				Description: "Result of running each payload through the pipeline, in the same order as payloads",
+				Type:        schema.TypeList,
+				Computed:    true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"input": {
+							Description: "The sample payload",
+							Type:        schema.TypeString,
+							Computed:    true,
+						},
+						"output": {
+							Description: "The payload after all steps ran",
+							Type:        schema.TypeString,
+							Computed:    true,
+						},
+						"aborted": {
+							Description: "Whether a step condition aborted the pipeline",
+							Type:        schema.TypeBool,
+							Computed:    true,
+						},
+						"step": {
+							Description: "Result of each step",
+							Type:        schema.TypeList,
+							Computed:    true,
+							Elem: &schema.Resource{
+								Schema: map[string]*schema.Schema{
+									"name": {
+										Description: "Step name",
+										Type:        schema.TypeString,
+										Computed:    true,
+									},
+									"result": {
+										Description: "Result of the step: true, false, error or skipped",
+										Type:        schema.TypeString,
+										Computed:    true,
+									},
+									"message": {
+										Description: "Message returned by the step's Wasm module",
+										Type:        schema.TypeString,
+										Computed:    true,
+									},
+									"abort": {
+										Description: "Abort condition of the matching on_true/on_false/on_error block",
+										Type:        schema.TypeString,
+										Computed:    true,
+									},
+									"notify": {
+										Description: "Whether the matching condition sends a notification",
+										Type:        schema.TypeBool,
+										Computed:    true,
+									},
+									"metadata": {
+										Description: "Metadata of the matching condition",
+										Type:        schema.TypeMap,
+										Computed:    true,
+										Elem: &schema.Schema{
+											Type: schema.TypeString,
+										},
+									},
+									"output": {
+										Description: "The payload after the step ran",
+										Type:        schema.TypeString,
+										Computed:    true,
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func dataSourcePipelineSimulationRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	s := m.(*streamdal.Streamdal)
+
+	var pipelineSteps []*protos.PipelineStep
+
+	if pipelineID, ok := d.GetOk("pipeline_id"); ok {
+		resp, err := s.GetPipeline(ctx, &protos.GetPipelineRequest{PipelineId: pipelineID.(string)})
+		if err != nil {
+			return diag.Errorf("Error fetching pipeline '%s': %s", pipelineID, err)
+		}
+
+		pipelineSteps = resp.GetPipeline().GetSteps()
+	} else {
+		pipelineSteps, diags = buildPipelineSteps(d.Get("step").([]interface{}))
+		if diags.HasError() {
+			return diags
+		}
+
+		if err := loadStepModules(ctx, s, pipelineSteps, interfaceMapToStringMap(d.Get("wasm_modules"))); err != nil {
+			return diag.Errorf("Error loading Wasm modules: %s", err)
+		}
+	}
+
+	sim, err := simulate.New(ctx, pipelineSteps)
+	if err != nil {
+		return diag.Errorf("Error loading pipeline for simulation: %s", err)
+	}
+	defer sim.Close(ctx)
+
+	results := make([]interface{}, 0)
+	hash := sha256.New()
+
+	for _, payload := range interfaceToStrings(d.Get("payloads")) {
+		res := sim.Run(ctx, pipelineSteps, []byte(payload))
+		results = append(results, flattenSimulationResult(res))
+
+		hash.Write(res.Input)
+		hash.Write(res.Output)
+	}
+
+	for _, step := range pipelineSteps {
+		hash.Write(step.GetXWasmBytes())
+	}
+
+	d.SetId(hex.EncodeToString(hash.Sum(nil)))
+	_ = d.Set("results", results)
+
+	return diags
+}
+
+// loadStepModules sets the Wasm module of unsaved steps, either from a local file or
+// from an existing pipeline with a step of the same type
+func loadStepModules(ctx context.Context, s *streamdal.Streamdal, pipelineSteps []*protos.PipelineStep, paths map[string]string) error {
+	var existing []*protos.Pipeline
+
+	for _, step := range pipelineSteps {
+		stepType := simulate.StepType(step)
+
+		if path, ok := paths[stepType]; ok {
+			wasm, err := os.ReadFile(path)
+			if err != nil {
+				return fmt.Errorf("unable to read Wasm module for '%s' steps: %s", stepType, err)
+			}
+
+			step.XWasmBytes = wasm
+			continue
+		}
+
+		if existing == nil {
+			pipelines, err := s.GetPipelines(ctx)
+			if err != nil {
+				return fmt.Errorf("unable to fetch pipelines: %s", err)
+			}
+
+			existing = pipelines
+		}
+
+		source, err := findStepModule(ctx, s, existing, stepType)
+		if err != nil {
+			return err
+		}
+
+		if source == nil {
+			return fmt.Errorf("no Wasm module found for step '%s': set wasm_modules[\"%s\"] to a local module", step.GetName(), stepType)
+		}
+
+		step.XWasmBytes = source.GetXWasmBytes()
+		step.XWasmFunction = source.XWasmFunction
+	}
+
+	return nil
+}
+
+// findStepModule returns a step of the given type with a Wasm module from one of the pipelines
+func findStepModule(ctx context.Context, s *streamdal.Streamdal, pipelines []*protos.Pipeline, stepType string) (*protos.PipelineStep, error) {
+	for _, p := range pipelines {
+		for _, step := range p.GetSteps() {
+			if simulate.StepType(step) != stepType {
+				continue
+			}
+
+			if len(step.GetXWasmBytes()) > 0 {
+				return step, nil
+			}
+
+			// Listing pipelines does not necessarily include the modules
+			resp, err := s.GetPipeline(ctx, &protos.GetPipelineRequest{PipelineId: p.GetId()})
+			if err != nil {
+				return nil, fmt.Errorf("unable to fetch pipeline '%s': %s", p.GetId(), err)
+			}
+
+			for _, full := range resp.GetPipeline().GetSteps() {
+				if simulate.StepType(full) == stepType && len(full.GetXWasmBytes()) > 0 {
+					return proto.Clone(full).(*protos.PipelineStep), nil
+				}
+			}
+		}
+	}
+
+	return nil, nil
+}
+
+func flattenSimulationResult(res *simulate.Result) map[string]interface{} {
+	stepResults := make([]interface{}, 0, len(res.Steps))
+
+	for _, sr := range res.Steps {
+		stepResults = append(stepResults, map[string]interface{}{
+			"name":     sr.Name,
+			"result":   sr.Result,
+			"message":  sr.Message,
+			"abort":    abortConditionToString(sr.Abort),
+			"notify":   sr.Notify,
+			"metadata": sr.Metadata,
+			"output":   string(sr.Output),
+		})
+	}
+
+	return map[string]interface{}{
+		"input":   string(res.Input),
+		"output":  string(res.Output),
+		"aborted": res.Aborted,
+		"step":    stepResults,
+	}
+}
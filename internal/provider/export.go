@@ -0,0 +1,449 @@
+package provider
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/streamdal/streamdal/libs/protos/build/go/protos"
+	"github.com/streamdal/terraform-provider-streamdal/internal/simulate"
+	"github.com/streamdal/terraform-provider-streamdal/util"
+)
+
+// sensitiveAttributes are notification attributes which are exported as references
+// to sensitive variables, instead of writing secrets into the configuration
+var sensitiveAttributes = map[string]bool{
+	"bot_token":             true,
+	"token":                 true,
+	"password":              true,
+	"ses_access_key":        true,
+	"ses_secret_access_key": true,
+}
+
+var invalidNameChars = regexp.MustCompile(`[^a-z0-9_]+`)
+
+// exporter generates the configuration for the server's resources, keeping track
+// of the Terraform addresses given to each of them
+type exporter struct {
+	file *hclwrite.File
+
+	// variables holds the declarations of the sensitive variables, which are written first
+	variables *hclwrite.File
+
+	// names are the resource names in use, per resource type
+	names map[string]map[string]bool
+
+	// addresses maps IDs to the Terraform addresses of the exported resources
+	pipelines     map[string]string
+	notifications map[string]string
+
+	warnings []string
+}
+
+// GenerateConfig writes the configuration for all pipelines, notifications and
+// audiences on the server, along with import blocks to bring them under management.
+// Secrets are replaced with references to sensitive variables. Warnings describe
+// resources which could not be exported.
+func GenerateConfig(all *protos.GetAllResponse, notifications map[string]*protos.NotificationConfig) ([]byte, []string, error) {
+	e := &exporter{
+		file:          hclwrite.NewEmptyFile(),
+		variables:     hclwrite.NewEmptyFile(),
+		names:         make(map[string]map[string]bool),
+		pipelines:     make(map[string]string),
+		notifications: make(map[string]string),
+	}
+
+	for _, id := range sortedIDs(notifications, func(n *protos.NotificationConfig) string { return n.GetName() }) {
+		if err := e.notification(id, notifications[id]); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	pipelines := make(map[string]*protos.Pipeline)
+	for id, info := range all.GetPipelines() {
+		if info.GetPipeline() != nil {
+			pipelines[id] = info.GetPipeline()
+		}
+	}
+
+	for _, id := range sortedIDs(pipelines, func(p *protos.Pipeline) string { return p.GetName() }) {
+		if err := e.pipeline(id, pipelines[id]); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	audiences := append([]*protos.Audience{}, all.GetAudiences()...)
+	sort.Slice(audiences, func(i, j int) bool {
+		return util.AudienceToStr(audiences[i]) < util.AudienceToStr(audiences[j])
+	})
+
+	for _, aud := range audiences {
+		if err := e.audience(aud, audiencePipelineConfigs(all, aud)); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	config := append(e.variables.Bytes(), e.file.Bytes()...)
+
+	return append(bytes.TrimRight(config, "\n"), '\n'), e.warnings, nil
+}
+
+func (e *exporter) notification(id string, n *protos.NotificationConfig) error {
+	name := e.resourceName("streamdal_notification", n.GetName(), "notification")
+	address := "streamdal_notification." + name
+
+	e.importBlock(address, id)
+
+	block := e.file.Body().AppendNewBlock("resource", []string{"streamdal_notification", name})
+	if err := e.writeBody(block.Body(), resourceNotification().Schema, flattenNotification(n), name); err != nil {
+		return fmt.Errorf("unable to export notification '%s': %s", n.GetName(), err)
+	}
+
+	e.file.Body().AppendNewline()
+	e.notifications[id] = address
+
+	return nil
+}
+
+func (e *exporter) pipeline(id string, p *protos.Pipeline) error {
+	for _, step := range p.GetSteps() {
+		t := simulate.StepType(step)
+		if _, ok := stepSchema().Schema[t]; !ok {
+			e.warnings = append(e.warnings, fmt.Sprintf("pipeline '%s' (%s) was not exported: step '%s' has type '%s', "+
+				"which is not supported by the provider", p.GetName(), id, step.GetName(), t))
+			return nil
+		}
+	}
+
+	name := e.resourceName("streamdal_pipeline", p.GetName(), "pipeline")
+	address := "streamdal_pipeline." + name
+
+	e.importBlock(address, id)
+
+	values := map[string]interface{}{
+		"name": p.GetName(),
+		"step": flattenPipelineSteps(p.GetSteps()),
+	}
+
+	block := e.file.Body().AppendNewBlock("resource", []string{"streamdal_pipeline", name})
+	if err := e.writeBody(block.Body(), resourcePipeline().Schema, values, name); err != nil {
+		return fmt.Errorf("unable to export pipeline '%s': %s", p.GetName(), err)
+	}
+
+	e.file.Body().AppendNewline()
+	e.pipelines[id] = address
+
+	return nil
+}
+
+func (e *exporter) audience(aud *protos.Audience, configs []*protos.PipelineConfig) error {
+	id := util.AudienceToStr(aud)
+	name := e.resourceName("streamdal_audience",
+		strings.Join([]string{aud.GetServiceName(), aud.GetComponentName(), aud.GetOperationName()}, "_"), "audience")
+
+	e.importBlock("streamdal_audience."+name, id)
+
+	values := map[string]interface{}{
+		"service_name":   aud.GetServiceName(),
+		"component_name": aud.GetComponentName(),
+		"operation_name": aud.GetOperationName(),
+		"operation_type": audienceOperationTypeToString(aud.GetOperationType()),
+	}
+
+	// Pipeline blocks are only needed to keep pipelines paused
+	paused := false
+	for _, cfg := range configs {
+		paused = paused || cfg.GetPaused()
+	}
+
+	if paused {
+		values["pipeline"] = flattenPipelineConfigs(configs)
+	} else {
+		ids := make([]string, 0, len(configs))
+		for _, cfg := range configs {
+			ids = append(ids, cfg.GetId())
+		}
+
+		values["pipeline_ids"] = ids
+	}
+
+	block := e.file.Body().AppendNewBlock("resource", []string{"streamdal_audience", name})
+	if err := e.writeBody(block.Body(), resourceAudience().Schema, values, name); err != nil {
+		return fmt.Errorf("unable to export audience '%s': %s", id, err)
+	}
+
+	e.file.Body().AppendNewline()
+
+	return nil
+}
+
+func (e *exporter) importBlock(address, id string) {
+	block := e.file.Body().AppendNewBlock("import", nil)
+	block.Body().SetAttributeTraversal("to", traversal(address))
+	block.Body().SetAttributeValue("id", cty.StringVal(id))
+	e.file.Body().AppendNewline()
+}
+
+// writeBody writes the attributes and blocks of a resource, leaving out computed
+// attributes and values which are the same as the schema's default
+func (e *exporter) writeBody(body *hclwrite.Body, sch map[string]*schema.Schema, values map[string]interface{}, resourceName string) error {
+	attrs, blocks := make([]string, 0), make([]string, 0)
+
+	for k, s := range sch {
+		if s.Computed && !s.Optional && !s.Required {
+			continue
+		}
+
+		if _, ok := s.Elem.(*schema.Resource); ok {
+			blocks = append(blocks, k)
+		} else {
+			attrs = append(attrs, k)
+		}
+	}
+
+	sort.Slice(attrs, func(i, j int) bool { return attributeOrder(attrs[i]) < attributeOrder(attrs[j]) })
+	sort.Slice(blocks, func(i, j int) bool { return attributeOrder(blocks[i]) < attributeOrder(blocks[j]) })
+
+	written := false
+
+	for _, k := range attrs {
+		v, ok := values[k]
+		if !ok || v == nil || isDefault(sch[k], v) {
+			continue
+		}
+
+		written = true
+
+		switch {
+		case sensitiveAttributes[k] && sch[k].Type == schema.TypeString:
+			body.SetAttributeTraversal(k, traversal("var."+e.sensitiveVariable(resourceName+"_"+k)))
+		case k == "pipeline_ids":
+			body.SetAttributeRaw(k, e.references(v, e.pipelines))
+		case k == "notification_config_ids":
+			body.SetAttributeRaw(k, e.references(v, e.notifications))
+		case k == "id":
+			// Only an audience's pipeline blocks have a configurable ID
+			body.SetAttributeRaw(k, e.reference(v.(string), e.pipelines))
+		default:
+			val, err := toCtyValue(v)
+			if err != nil {
+				return fmt.Errorf("attribute '%s': %s", k, err)
+			}
+
+			body.SetAttributeValue(k, val)
+		}
+	}
+
+	for _, k := range blocks {
+		items, _ := values[k].([]interface{})
+
+		for _, item := range items {
+			itemMap, _ := item.(map[string]interface{})
+
+			// Separate blocks from the attributes and each other
+			if written {
+				body.AppendNewline()
+			}
+
+			written = true
+
+			block := body.AppendNewBlock(k, nil)
+			if err := e.writeBody(block.Body(), sch[k].Elem.(*schema.Resource).Schema, itemMap, resourceName); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// references writes a list of IDs as references to the exported resources with
+// those IDs. IDs of resources which weren't exported are written as is.
+func (e *exporter) references(v interface{}, addresses map[string]string) hclwrite.Tokens {
+	elems := make([]hclwrite.Tokens, 0)
+
+	for _, id := range toStrings(v) {
+		elems = append(elems, e.reference(id, addresses))
+	}
+
+	return hclwrite.TokensForTuple(elems)
+}
+
+func (e *exporter) reference(id string, addresses map[string]string) hclwrite.Tokens {
+	if address, ok := addresses[id]; ok {
+		return hclwrite.TokensForTraversal(traversal(address + ".id"))
+	}
+
+	return hclwrite.TokensForValue(cty.StringVal(id))
+}
+
+func (e *exporter) sensitiveVariable(name string) string {
+	name = e.resourceName("variable", name, "secret")
+
+	block := e.variables.Body().AppendNewBlock("variable", []string{name})
+	block.Body().SetAttributeTraversal("type", traversal("string"))
+	block.Body().SetAttributeValue("sensitive", cty.True)
+	e.variables.Body().AppendNewline()
+
+	return name
+}
+
+// resourceName turns a name into a unique Terraform identifier for the resource type
+func (e *exporter) resourceName(resourceType, name, fallback string) string {
+	name = strings.Trim(invalidNameChars.ReplaceAllString(strings.ToLower(name), "_"), "_")
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		name = fallback + "_" + name
+		name = strings.TrimSuffix(name, "_")
+	}
+
+	if e.names[resourceType] == nil {
+		e.names[resourceType] = make(map[string]bool)
+	}
+
+	unique := name
+	for i := 2; e.names[resourceType][unique]; i++ {
+		unique = fmt.Sprintf("%s_%d", name, i)
+	}
+
+	e.names[resourceType][unique] = true
+
+	return unique
+}
+
+// attributeOrder puts the name first and conditions last, keeping everything else
+// in alphabetical order
+func attributeOrder(k string) string {
+	switch {
+	case k == "name":
+		return "0"
+	case strings.HasPrefix(k, "on_"):
+		return "2" + k
+	}
+
+	return "1" + k
+}
+
+func isDefault(s *schema.Schema, v interface{}) bool {
+	if s.Default != nil {
+		return reflect.DeepEqual(s.Default, v) || fmt.Sprint(s.Default) == fmt.Sprint(v)
+	}
+
+	if s.Required {
+		return false
+	}
+
+	switch val := v.(type) {
+	case string:
+		return val == ""
+	case bool:
+		return !val
+	case int:
+		return val == 0
+	case []string:
+		return len(val) == 0
+	case []interface{}:
+		return len(val) == 0
+	case map[string]string:
+		return len(val) == 0
+	case map[string]interface{}:
+		return len(val) == 0
+	}
+
+	return false
+}
+
+func toCtyValue(v interface{}) (cty.Value, error) {
+	switch val := v.(type) {
+	case string:
+		return cty.StringVal(val), nil
+	case bool:
+		return cty.BoolVal(val), nil
+	case int:
+		return cty.NumberIntVal(int64(val)), nil
+	case int32:
+		return cty.NumberIntVal(int64(val)), nil
+	case int64:
+		return cty.NumberIntVal(val), nil
+	case []string, []interface{}:
+		strs := toStrings(val)
+		if len(strs) == 0 {
+			return cty.ListValEmpty(cty.String), nil
+		}
+
+		elems := make([]cty.Value, 0, len(strs))
+		for _, s := range strs {
+			elems = append(elems, cty.StringVal(s))
+		}
+
+		return cty.ListVal(elems), nil
+	case map[string]string:
+		if len(val) == 0 {
+			return cty.MapValEmpty(cty.String), nil
+		}
+
+		elems := make(map[string]cty.Value)
+		for k, s := range val {
+			elems[k] = cty.StringVal(s)
+		}
+
+		return cty.MapVal(elems), nil
+	case map[string]interface{}:
+		m := make(map[string]string)
+		for k, s := range val {
+			m[k] = fmt.Sprint(s)
+		}
+
+		return toCtyValue(m)
+	}
+
+	return cty.NilVal, fmt.Errorf("unsupported value type %T", v)
+}
+
+func toStrings(v interface{}) []string {
+	switch val := v.(type) {
+	case []string:
+		return val
+	case []interface{}:
+		return interfaceToStrings(val)
+	}
+
+	return []string{}
+}
+
+func traversal(address string) hcl.Traversal {
+	parts := strings.Split(address, ".")
+
+	t := hcl.Traversal{hcl.TraverseRoot{Name: parts[0]}}
+	for _, p := range parts[1:] {
+		t = append(t, hcl.TraverseAttr{Name: p})
+	}
+
+	return t
+}
+
+// sortedIDs returns the keys of the map ordered by the name of each value, then ID
+func sortedIDs[T any](m map[string]T, name func(T) string) []string {
+	ids := make([]string, 0, len(m))
+	for id := range m {
+		ids = append(ids, id)
+	}
+
+	sort.Slice(ids, func(i, j int) bool {
+		ni, nj := name(m[ids[i]]), name(m[ids[j]])
+		if ni != nj {
+			return ni < nj
+		}
+
+		return ids[i] < ids[j]
+	})
+
+	return ids
+}
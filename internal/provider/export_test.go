@@ -0,0 +1,98 @@
+package provider
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/streamdal/streamdal/libs/protos/build/go/protos"
+	"github.com/streamdal/streamdal/libs/protos/build/go/protos/steps"
+)
+
+func TestGenerateConfig(t *testing.T) {
+	aud := &protos.Audience{
+		ServiceName:   "signups",
+		ComponentName: "kafka",
+		OperationType: protos.OperationType_OPERATION_TYPE_CONSUMER,
+		OperationName: "new-users",
+	}
+
+	all := &protos.GetAllResponse{
+		Audiences: []*protos.Audience{aud},
+		Pipelines: map[string]*protos.PipelineInfo{
+			"p1": {
+				Audiences: []*protos.Audience{aud},
+				Pipeline: &protos.Pipeline{
+					Id:   "p1",
+					Name: "Find PII",
+					Steps: []*protos.PipelineStep{{
+						Name: "Email",
+						Step: &protos.PipelineStep_Detective{Detective: &steps.DetectiveStep{
+							Path: proto.String("object.email"),
+							Type: steps.DetectiveType_DETECTIVE_TYPE_PII_EMAIL,
+						}},
+						OnTrue: &protos.PipelineStepConditions{
+							Notification: &protos.PipelineStepNotification{
+								NotificationConfigIds: []string{"n1"},
+							},
+						},
+					}},
+				},
+			},
+			"p2": {
+				Pipeline: &protos.Pipeline{
+					Id:    "p2",
+					Name:  "KV lookup",
+					Steps: []*protos.PipelineStep{{Name: "Lookup", Step: &protos.PipelineStep_Kv{Kv: &steps.KVStep{}}}},
+				},
+			},
+		},
+	}
+
+	notifications := map[string]*protos.NotificationConfig{
+		"n1": {
+			Id:   proto.String("n1"),
+			Name: "Alerts",
+			Type: protos.NotificationType_NOTIFICATION_TYPE_SLACK,
+			Config: &protos.NotificationConfig_Slack{
+				Slack: &protos.NotificationSlack{Channel: "#alerts", BotToken: "xoxb-secret"},
+			},
+		},
+	}
+
+	config, warnings, err := GenerateConfig(all, notifications)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if _, diags := hclsyntax.ParseConfig(config, "export.tf", hcl.Pos{Line: 1, Column: 1}); diags.HasErrors() {
+		t.Fatalf("generated configuration is invalid: %s\n%s", diags, config)
+	}
+
+	out := string(config)
+
+	for _, expected := range []string{
+		`variable "alerts_bot_token" {`,
+		`bot_token = var.alerts_bot_token`,
+		`to = streamdal_notification.alerts`,
+		`resource "streamdal_pipeline" "find_pii" {`,
+		`notification_config_ids = [streamdal_notification.alerts.id]`,
+		`pipeline_ids   = [streamdal_pipeline.find_pii.id]`,
+		`id = "signups:operation_type_consumer:new-users:kafka"`,
+	} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("expected configuration to contain '%s', got:\n%s", expected, out)
+		}
+	}
+
+	if strings.Contains(out, "xoxb-secret") {
+		t.Error("expected secrets to be left out of the configuration")
+	}
+
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "KV lookup") {
+		t.Errorf("expected a warning for the pipeline with a kv step, got %v", warnings)
+	}
+}
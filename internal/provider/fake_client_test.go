@@ -0,0 +1,273 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/streamdal/streamdal/libs/protos/build/go/protos"
+	"github.com/streamdal/terraform-provider-streamdal/streamdal"
+	"github.com/streamdal/terraform-provider-streamdal/util"
+)
+
+// fakeExternalClient is an in-memory stand-in for the server's External API.
+// Methods which are not overridden panic through the nil embedded interface.
+type fakeExternalClient struct {
+	protos.ExternalClient
+
+	lock sync.Mutex
+
+	// getAll is returned by GetAll, which fails with getAllErr if set
+	getAll    *protos.GetAllResponse
+	getAllErr error
+
+	// stream is sent by GetAllStream, which then blocks until the context is done
+	stream []*protos.GetAllResponse
+
+	// metrics are sent by GetMetrics, which then blocks until the context is done
+	metrics []*protos.GetMetricsResponse
+
+	// rates are sent by GetAudienceRates, which then blocks until the context is done
+	rates []*protos.GetAudienceRatesResponse
+
+	// metricsAfterChange and ratesAfterChange, if set, are sent instead of metrics
+	// and rates once SetPipelines has been called, to simulate the effect of a change
+	metricsAfterChange []*protos.GetMetricsResponse
+	ratesAfterChange   []*protos.GetAudienceRatesResponse
+
+	// schemas contains inferred schemas, keyed by audience string
+	schemas map[string]*protos.Schema
+
+	// notifications are returned by GetNotifications, which fails with
+	// notificationsErr if set
+	notifications    map[string]*protos.NotificationConfig
+	notificationsErr error
+
+	// pipelines are returned by GetPipelines and replaced by UpdatePipeline
+	pipelines []*protos.Pipeline
+
+	// updatedPipelines records the IDs of pipelines passed to UpdatePipeline
+	updatedPipelines []string
+
+	// detached records DetachNotification requests
+	detached []*protos.DetachNotificationRequest
+
+	// deletedNotifications records the IDs of deleted notification configs
+	deletedNotifications []string
+
+	// deletedPipelines records the IDs of deleted pipelines
+	deletedPipelines []string
+
+	// setPipelines records SetPipelines requests, which are also applied to the
+	// configs returned by GetAll
+	setPipelines []*protos.SetPipelinesRequest
+}
+
+func newFakeStreamdal(fake *fakeExternalClient) *streamdal.Streamdal {
+	return &streamdal.Streamdal{Client: fake}
+}
+
+func (f *fakeExternalClient) GetAll(_ context.Context, _ *protos.GetAllRequest, _ ...grpc.CallOption) (*protos.GetAllResponse, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.getAllErr != nil {
+		return nil, f.getAllErr
+	}
+
+	if f.getAll == nil {
+		return &protos.GetAllResponse{}, nil
+	}
+
+	return proto.Clone(f.getAll).(*protos.GetAllResponse), nil
+}
+
+func (f *fakeExternalClient) GetAllStream(ctx context.Context, _ *protos.GetAllRequest, _ ...grpc.CallOption) (protos.External_GetAllStreamClient, error) {
+	return &fakeStream[*protos.GetAllResponse]{ctx: ctx, responses: f.stream}, nil
+}
+
+func (f *fakeExternalClient) GetMetrics(ctx context.Context, _ *protos.GetMetricsRequest, _ ...grpc.CallOption) (protos.External_GetMetricsClient, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.metricsAfterChange != nil && len(f.setPipelines) > 0 {
+		return &fakeStream[*protos.GetMetricsResponse]{ctx: ctx, responses: f.metricsAfterChange}, nil
+	}
+
+	return &fakeStream[*protos.GetMetricsResponse]{ctx: ctx, responses: f.metrics}, nil
+}
+
+func (f *fakeExternalClient) GetAudienceRates(ctx context.Context, _ *protos.GetAudienceRatesRequest, _ ...grpc.CallOption) (protos.External_GetAudienceRatesClient, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.ratesAfterChange != nil && len(f.setPipelines) > 0 {
+		return &fakeStream[*protos.GetAudienceRatesResponse]{ctx: ctx, responses: f.ratesAfterChange}, nil
+	}
+
+	return &fakeStream[*protos.GetAudienceRatesResponse]{ctx: ctx, responses: f.rates}, nil
+}
+
+// fakeStream sends its responses in order, then blocks until the context is done
+type fakeStream[T any] struct {
+	grpc.ClientStream
+
+	ctx       context.Context
+	responses []T
+}
+
+func (s *fakeStream[T]) Recv() (T, error) {
+	if len(s.responses) == 0 {
+		<-s.ctx.Done()
+
+		var zero T
+		return zero, s.ctx.Err()
+	}
+
+	resp := s.responses[0]
+	s.responses = s.responses[1:]
+
+	return resp, nil
+}
+
+func (f *fakeExternalClient) GetSchema(_ context.Context, req *protos.GetSchemaRequest, _ ...grpc.CallOption) (*protos.GetSchemaResponse, error) {
+	s, ok := f.schemas[util.AudienceToStr(req.GetAudience())]
+	if !ok {
+		return nil, errors.New("schema not found")
+	}
+
+	return &protos.GetSchemaResponse{Schema: s}, nil
+}
+
+func (f *fakeExternalClient) SetPipelines(_ context.Context, req *protos.SetPipelinesRequest, _ ...grpc.CallOption) (*protos.StandardResponse, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.setPipelines = append(f.setPipelines, req)
+
+	if f.getAll != nil {
+		if f.getAll.Configs == nil {
+			f.getAll.Configs = make(map[string]*protos.PipelineConfigs)
+		}
+
+		key := util.AudienceToStr(req.GetAudience())
+
+		// Pause state of pipelines which stay assigned is kept
+		paused := make(map[string]bool)
+		for _, cfg := range f.getAll.Configs[key].GetConfigs() {
+			paused[cfg.GetId()] = cfg.GetPaused()
+		}
+
+		cfgs := &protos.PipelineConfigs{}
+		for _, id := range req.GetPipelineIds() {
+			cfgs.Configs = append(cfgs.Configs, &protos.PipelineConfig{Id: id, Paused: paused[id]})
+		}
+
+		f.getAll.Configs[key] = cfgs
+	}
+
+	return &protos.StandardResponse{Code: protos.ResponseCode_RESPONSE_CODE_OK}, nil
+}
+
+func (f *fakeExternalClient) PausePipeline(_ context.Context, req *protos.PausePipelineRequest, _ ...grpc.CallOption) (*protos.StandardResponse, error) {
+	return f.setPaused(req.GetAudience(), req.GetPipelineId(), true)
+}
+
+func (f *fakeExternalClient) ResumePipeline(_ context.Context, req *protos.ResumePipelineRequest, _ ...grpc.CallOption) (*protos.StandardResponse, error) {
+	return f.setPaused(req.GetAudience(), req.GetPipelineId(), false)
+}
+
+func (f *fakeExternalClient) setPaused(aud *protos.Audience, pipelineID string, paused bool) (*protos.StandardResponse, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	for _, cfg := range f.getAll.GetConfigs()[util.AudienceToStr(aud)].GetConfigs() {
+		if cfg.GetId() == pipelineID {
+			cfg.Paused = paused
+			return &protos.StandardResponse{Code: protos.ResponseCode_RESPONSE_CODE_OK}, nil
+		}
+	}
+
+	return nil, errors.New("pipeline not assigned to audience")
+}
+
+func (f *fakeExternalClient) CreateAudience(_ context.Context, req *protos.CreateAudienceRequest, _ ...grpc.CallOption) (*protos.StandardResponse, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.getAll == nil {
+		f.getAll = &protos.GetAllResponse{}
+	}
+
+	if !util.AudienceInList(req.GetAudience(), f.getAll.Audiences) {
+		f.getAll.Audiences = append(f.getAll.Audiences, req.GetAudience())
+	}
+
+	return &protos.StandardResponse{Code: protos.ResponseCode_RESPONSE_CODE_OK}, nil
+}
+
+func (f *fakeExternalClient) DeletePipeline(_ context.Context, req *protos.DeletePipelineRequest, _ ...grpc.CallOption) (*protos.StandardResponse, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.deletedPipelines = append(f.deletedPipelines, req.GetPipelineId())
+
+	return &protos.StandardResponse{Code: protos.ResponseCode_RESPONSE_CODE_OK}, nil
+}
+
+func (f *fakeExternalClient) GetPipelines(_ context.Context, _ *protos.GetPipelinesRequest, _ ...grpc.CallOption) (*protos.GetPipelinesResponse, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	pipelines := make([]*protos.Pipeline, 0, len(f.pipelines))
+	for _, p := range f.pipelines {
+		pipelines = append(pipelines, proto.Clone(p).(*protos.Pipeline))
+	}
+
+	return &protos.GetPipelinesResponse{Pipelines: pipelines}, nil
+}
+
+func (f *fakeExternalClient) GetNotifications(_ context.Context, _ *protos.GetNotificationsRequest, _ ...grpc.CallOption) (*protos.GetNotificationsResponse, error) {
+	if f.notificationsErr != nil {
+		return nil, f.notificationsErr
+	}
+
+	return &protos.GetNotificationsResponse{Notifications: f.notifications}, nil
+}
+
+func (f *fakeExternalClient) UpdatePipeline(_ context.Context, req *protos.UpdatePipelineRequest, _ ...grpc.CallOption) (*protos.StandardResponse, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.updatedPipelines = append(f.updatedPipelines, req.GetPipeline().GetId())
+
+	for i, p := range f.pipelines {
+		if p.GetId() == req.GetPipeline().GetId() {
+			f.pipelines[i] = req.GetPipeline()
+			return &protos.StandardResponse{Code: protos.ResponseCode_RESPONSE_CODE_OK}, nil
+		}
+	}
+
+	return nil, errors.New("pipeline not found")
+}
+
+func (f *fakeExternalClient) DetachNotification(_ context.Context, req *protos.DetachNotificationRequest, _ ...grpc.CallOption) (*protos.StandardResponse, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.detached = append(f.detached, req)
+
+	return &protos.StandardResponse{Code: protos.ResponseCode_RESPONSE_CODE_OK}, nil
+}
+
+func (f *fakeExternalClient) DeleteNotification(_ context.Context, req *protos.DeleteNotificationRequest, _ ...grpc.CallOption) (*protos.StandardResponse, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.deletedNotifications = append(f.deletedNotifications, req.GetNotificationId())
+
+	return &protos.StandardResponse{Code: protos.ResponseCode_RESPONSE_CODE_OK}, nil
+}
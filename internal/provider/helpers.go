@@ -2,14 +2,20 @@ package provider
 
 import (
 	"errors"
+	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
 	"github.com/streamdal/streamdal/libs/protos/build/go/protos"
 	"github.com/streamdal/streamdal/libs/protos/build/go/protos/shared"
 	"github.com/streamdal/streamdal/libs/protos/build/go/protos/steps"
+	"github.com/streamdal/terraform-provider-streamdal/internal/schemas"
+	"github.com/streamdal/terraform-provider-streamdal/util"
 )
 
 const consumerStr = "consumer"
@@ -31,6 +37,13 @@ func interfaceToStrings(value interface{}) []string {
 	return strs
 }
 
+// interfaceToString converts an interface{} value to a string
+// Nil or non-string values, such as fields of an empty block, result in an empty string
+func interfaceToString(value interface{}) string {
+	s, _ := value.(string)
+	return s
+}
+
 func interfaceMapToStringMap(value interface{}) map[string]string {
 	m := make(map[string]string)
 
@@ -45,6 +58,29 @@ func interfaceMapToStringMap(value interface{}) map[string]string {
 	return m
 }
 
+// validateJSONPath returns a validation function which checks the syntax of a JSON path
+// using the same path grammar as the Streamdal Wasm modules. Empty paths are allowed.
+func validateJSONPath() schema.SchemaValidateFunc {
+	return func(i interface{}, k string) ([]string, []error) {
+		v, ok := i.(string)
+		if !ok {
+			return nil, []error{fmt.Errorf("expected type of %s to be string", k)}
+		}
+
+		if err := util.ValidatePath(v); err != nil {
+			return nil, []error{fmt.Errorf("%s: %s", k, err)}
+		}
+
+		return nil, nil
+	}
+}
+
+// suppressEquivalentJSON suppresses diffs between two JSON documents which only differ
+// in formatting or the order of object keys
+func suppressEquivalentJSON(_, old, new string, _ *schema.ResourceData) bool {
+	return schemas.Equal([]byte(old), []byte(new))
+}
+
 var stepTypes = []string{"detective", "transform", "http_request", "valid_json", "schema_validation", "kv"}
 
 func getStepType(d map[string]interface{}) string {
@@ -74,31 +110,50 @@ func detectiveTypeFromString(s string) (steps.DetectiveType, error) {
 	return 0, errors.New("invalid detective type")
 }
 
-func getTransformType(d map[string]interface{}) string {
+func detectiveTypeToString(t steps.DetectiveType) string {
+	return strings.ToLower(strings.Replace(t.String(), "DETECTIVE_TYPE_", "", -1))
+}
+
+// transformBlocks contains the names of the blocks that can be specified under a
+// transform{} block, in the order they are checked. The names don't always line up
+// with the TransformType enum, see transformTypeFromBlock()
+var transformBlocks = []string{"replace_value", "delete_field", "obfuscate", "mask_value", "truncate", "extract"}
+
+// getTransformTypes returns the names of all transform blocks that are set in the given transform config
+func getTransformTypes(d map[string]interface{}) []string {
+	found := make([]string, 0)
+
 	if d == nil {
-		return ""
+		return found
 	}
 
-	for _, st := range getTransformTypes() {
-		if opts, ok := d[st].([]interface{}); ok && len(opts) > 0 {
-			return st
+	for _, tb := range transformBlocks {
+		if opts, ok := d[tb].([]interface{}); ok && len(opts) > 0 {
+			found = append(found, tb)
 		}
 	}
 
-	return ""
+	return found
 }
 
-func transformTypeFromString(s string) (steps.TransformType, error) {
-	for id, v := range steps.TransformType_name {
-		v = strings.Replace(v, "TRANSFORM_TYPE_", "", -1)
-		v = strings.ToLower(v)
-		if s == v {
-			return steps.TransformType(id), nil
-		}
+// transformTypeFromBlock converts the name of a block under transform{} to a transform type enum
+func transformTypeFromBlock(s string) (steps.TransformType, error) {
+	switch s {
+	case "replace_value":
+		return steps.TransformType_TRANSFORM_TYPE_REPLACE_VALUE, nil
+	case "delete_field":
+		return steps.TransformType_TRANSFORM_TYPE_DELETE_FIELD, nil
+	case "obfuscate":
+		return steps.TransformType_TRANSFORM_TYPE_OBFUSCATE_VALUE, nil
+	case "mask_value":
+		return steps.TransformType_TRANSFORM_TYPE_MASK_VALUE, nil
+	case "truncate":
+		return steps.TransformType_TRANSFORM_TYPE_TRUNCATE_VALUE, nil
+	case "extract":
+		return steps.TransformType_TRANSFORM_TYPE_EXTRACT, nil
 	}
 
 	return 0, errors.New("invalid transform type")
-
 }
 
 // getDetectiveTypes returns all detective type enums as a slice of strings
@@ -114,19 +169,6 @@ func getDetectiveTypes() schema.SchemaValidateFunc {
 	return validation.StringInSlice(t, true)
 }
 
-// getDetectiveTypes returns all transform type enums as a slice of strings
-func getTransformTypes() []string {
-	t := make([]string, 0)
-
-	for _, v := range steps.TransformType_name {
-		v = strings.Replace(v, "TRANSFORM_TYPE_", "", -1)
-		v = strings.ToLower(v)
-		t = append(t, v)
-	}
-
-	return t
-}
-
 func getTransformTruncateTypes() schema.SchemaValidateFunc {
 	t := make([]string, 0)
 
@@ -151,6 +193,10 @@ func transformTruncateTypeFromString(s string) (steps.TransformTruncateType, err
 	return 0, errors.New("invalid transform truncate type")
 }
 
+func transformTruncateTypeToString(t steps.TransformTruncateType) string {
+	return strings.ToLower(strings.Replace(t.String(), "TRANSFORM_TRUNCATE_TYPE_", "", -1))
+}
+
 func getAbortConditions() schema.SchemaValidateFunc {
 	t := make([]string, 0)
 
@@ -176,6 +222,10 @@ func abortConditionFromString(s string) (protos.AbortCondition, error) {
 
 }
 
+func abortConditionToString(c protos.AbortCondition) string {
+	return strings.ToLower(strings.Replace(c.String(), "ABORT_CONDITION_", "", -1))
+}
+
 func getNotificationPayloadTypes() schema.SchemaValidateFunc {
 	t := make([]string, 0)
 
@@ -200,6 +250,10 @@ func notificationPayloadTypeFromString(s string) (protos.PipelineStepNotificatio
 	return 0, errors.New("invalid notification payload type")
 }
 
+func notificationPayloadTypeToString(t protos.PipelineStepNotification_PayloadType) string {
+	return strings.ToLower(strings.Replace(t.String(), "PAYLOAD_TYPE_", "", -1))
+}
+
 func getHttpMethods() schema.SchemaValidateFunc {
 	t := make([]string, 0)
 
@@ -224,6 +278,10 @@ func httpMethodFromString(s string) (steps.HttpRequestMethod, error) {
 	return 0, errors.New("invalid http method")
 }
 
+func httpMethodToString(m steps.HttpRequestMethod) string {
+	return strings.ToLower(strings.Replace(m.String(), "HTTP_REQUEST_METHOD_", "", -1))
+}
+
 func getSchemaValidationTypes() schema.SchemaValidateFunc {
 	t := make([]string, 0)
 
@@ -248,6 +306,10 @@ func schemaValidationTypeFromString(s string) (steps.SchemaValidationType, error
 	return 0, errors.New("invalid schema validation type")
 }
 
+func schemaValidationTypeToString(t steps.SchemaValidationType) string {
+	return strings.ToLower(strings.Replace(t.String(), "SCHEMA_VALIDATION_TYPE_", "", -1))
+}
+
 func getSchemaValidationConditions() schema.SchemaValidateFunc {
 	t := make([]string, 0)
 
@@ -273,11 +335,15 @@ func schemaValidationConditionFromString(s string) (steps.SchemaValidationCondit
 
 }
 
+func schemaValidationConditionToString(c steps.SchemaValidationCondition) string {
+	return strings.ToLower(strings.Replace(c.String(), "SCHEMA_VALIDATION_CONDITION_", "", -1))
+}
+
 func getSchemaValidationJSONSchemaDrafts() schema.SchemaValidateFunc {
 	t := make([]string, 0)
 
 	for _, v := range steps.JSONSchemaDraft_name {
-		v = strings.Replace(v, "JSON_SCHEMA_", "", -1)
+		v = strings.Replace(v, "JSONSCHEMA_", "", -1)
 		v = strings.ToLower(v)
 		t = append(t, v)
 	}
@@ -287,7 +353,7 @@ func getSchemaValidationJSONSchemaDrafts() schema.SchemaValidateFunc {
 
 func schemaValidationJSONSchemaDraftFromString(s string) (steps.JSONSchemaDraft, error) {
 	for id, v := range steps.JSONSchemaDraft_name {
-		v = strings.Replace(v, "JSON_SCHEMA_", "", -1)
+		v = strings.Replace(v, "JSONSCHEMA_", "", -1)
 		v = strings.ToLower(v)
 		if s == v {
 			return steps.JSONSchemaDraft(id), nil
@@ -297,6 +363,10 @@ func schemaValidationJSONSchemaDraftFromString(s string) (steps.JSONSchemaDraft,
 	return 0, errors.New("invalid schema validation JSON schema draft")
 }
 
+func schemaValidationJSONSchemaDraftToString(d steps.JSONSchemaDraft) string {
+	return strings.ToLower(strings.Replace(d.String(), "JSONSCHEMA_", "", -1))
+}
+
 func getKvTypes() schema.SchemaValidateFunc {
 	t := make([]string, 0)
 
@@ -369,6 +439,10 @@ func notificationConfigTypeFromString(s string) (protos.NotificationType, error)
 	return 0, errors.New("invalid notification type")
 }
 
+func notificationConfigTypeToString(t protos.NotificationType) string {
+	return strings.ToLower(strings.Replace(t.String(), "NOTIFICATION_TYPE_", "", -1))
+}
+
 func getPagerDutyUrgencyTypes() schema.SchemaValidateFunc {
 	t := make([]string, 0)
 
@@ -393,6 +467,10 @@ func pagerDutyUrgencyTypeFromString(s string) (protos.NotificationPagerDuty_Urge
 	return 0, errors.New("invalid pagerduty urgency type")
 }
 
+func pagerDutyUrgencyTypeToString(t protos.NotificationPagerDuty_Urgency) string {
+	return strings.ToLower(strings.Replace(t.String(), "URGENCY_", "", -1))
+}
+
 func getEmailTypes() schema.SchemaValidateFunc {
 	t := make([]string, 0)
 
@@ -417,6 +495,10 @@ func emailTypeFromString(s string) (protos.NotificationEmail_Type, error) {
 	return 0, errors.New("invalid email type")
 }
 
+func emailTypeToString(t protos.NotificationEmail_Type) string {
+	return strings.ToLower(strings.Replace(t.String(), "TYPE_", "", -1))
+}
+
 func getAudienceOperationTypes() schema.SchemaValidateFunc {
 	return validation.StringInSlice([]string{consumerStr, producerStr}, false)
 }
@@ -437,3 +519,79 @@ func audienceOperationTypeToString(t protos.OperationType) string {
 		return producerStr
 	}
 }
+
+// stringInSlice reports whether s is in list
+func stringInSlice(s string, list []string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+// removeString returns list without any occurrences of s
+func removeString(list []string, s string) []string {
+	out := make([]string, 0, len(list))
+	for _, v := range list {
+		if v != s {
+			out = append(out, v)
+		}
+	}
+
+	return out
+}
+
+// audiencePipelineConfigs returns the pipelines assigned to the audience, in the
+// order they run. Servers which don't return configs per audience have no pause
+// state, so their assignments are reported as active.
+func audiencePipelineConfigs(all *protos.GetAllResponse, aud *protos.Audience) []*protos.PipelineConfig {
+	if cfgs, ok := all.GetConfigs()[util.AudienceToStr(aud)]; ok {
+		return cfgs.GetConfigs()
+	}
+
+	ids := make([]string, 0)
+
+	for id, info := range all.GetPipelines() {
+		for _, a := range info.GetAudiences() {
+			if util.AudienceEquals(aud, a) {
+				ids = append(ids, id)
+			}
+		}
+	}
+
+	sort.Strings(ids)
+
+	cfgs := make([]*protos.PipelineConfig, 0, len(ids))
+	for _, id := range ids {
+		cfgs = append(cfgs, &protos.PipelineConfig{Id: id})
+	}
+
+	return cfgs
+}
+
+// audiencePipelineIDs returns the IDs of the pipelines assigned to the audience,
+// in the order they run
+func audiencePipelineIDs(all *protos.GetAllResponse, aud *protos.Audience) []string {
+	ids := make([]string, 0)
+
+	for _, cfg := range audiencePipelineConfigs(all, aud) {
+		ids = append(ids, cfg.GetId())
+	}
+
+	return ids
+}
+
+// clientTypeToString converts a client type to its lower case name, ie. "sdk" or "shim"
+func clientTypeToString(t protos.ClientType) string {
+	return strings.ToLower(strings.TrimPrefix(t.String(), "CLIENT_TYPE_"))
+}
+
+// serverUnreachable reports whether err means the server couldn't be reached, as
+// opposed to the server rejecting the request. Plan-time checks which only warn
+// are skipped in that case rather than failing the plan.
+func serverUnreachable(err error) bool {
+	code := status.Code(err)
+	return code == codes.Unavailable || code == codes.DeadlineExceeded
+}
@@ -0,0 +1,94 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+// UnknownVariableValue marks values in a raw configuration which are not known
+// until apply, ie. references to variables or other resources
+const UnknownVariableValue = unknownVariableValue
+
+// Lint rules, one for each plan-time check of the streamdal_pipeline resource
+const (
+	RuleInvalidAttribute  = "invalid-attribute"
+	RuleInvalidConfig     = "invalid-configuration"
+	RuleStepType          = "step-type"
+	RuleDetectiveArgs     = "detective-args"
+	RuleMissingPath       = "missing-path"
+	RuleJSONSchema        = "json-schema"
+	RuleNotificationPaths = "notification-paths"
+	RuleDynamicStep       = "dynamic-step"
+	RuleUnreachableSteps  = "unreachable-steps"
+)
+
+// Rules describes each lint rule
+var Rules = map[string]string{
+	RuleInvalidAttribute:  "Attribute value is invalid, or a required attribute is missing",
+	RuleInvalidConfig:     "Configuration can't be read",
+	RuleStepType:          "Step must have exactly one step type",
+	RuleDetectiveArgs:     "Detective args don't match the detective type",
+	RuleMissingPath:       "Step needs a path but has none",
+	RuleJSONSchema:        "JSON schema of a schema_validation step is invalid",
+	RuleNotificationPaths: "Notification selects paths but has none",
+	RuleDynamicStep:       "Dynamic step has no results to operate on",
+	RuleUnreachableSteps:  "Steps after a step which always aborts never run",
+}
+
+// RuleDiagnostic is a diagnostic along with the lint rule of the check that produced it
+type RuleDiagnostic struct {
+	Rule string
+	diag.Diagnostic
+}
+
+// ValidatePipeline runs the plan-time validations of the streamdal_pipeline resource
+// against a raw resource configuration, without Terraform or a connection to a server.
+// Nested blocks are lists of maps, as in terraform.NewResourceConfigRaw().
+func ValidatePipeline(ctx context.Context, raw map[string]interface{}) []RuleDiagnostic {
+	found := make([]RuleDiagnostic, 0)
+
+	r := resourcePipeline()
+	cfg := terraform.NewResourceConfigRaw(raw)
+
+	diags := r.Validate(cfg)
+	for _, d := range diags {
+		found = append(found, RuleDiagnostic{Rule: RuleInvalidAttribute, Diagnostic: d})
+	}
+
+	if diags.HasError() {
+		return found
+	}
+
+	sm := schema.InternalMap(r.Schema)
+
+	instanceDiff, err := sm.Diff(ctx, nil, cfg, nil, nil, true)
+	if err != nil {
+		return append(found, configError(err))
+	}
+
+	d, err := sm.Data(nil, instanceDiff)
+	if err != nil {
+		return append(found, configError(err))
+	}
+
+	unknown := func(key string) bool {
+		attr, ok := instanceDiff.Attributes[key+".#"]
+		return ok && attr.NewComputed
+	}
+
+	return append(found, checkPipelineSteps(markUnknownLists(d.Get("step").([]interface{}), unknown))...)
+}
+
+func configError(err error) RuleDiagnostic {
+	return RuleDiagnostic{
+		Rule: RuleInvalidConfig,
+		Diagnostic: diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  fmt.Sprintf("Error reading pipeline configuration: %s", err),
+		},
+	}
+}
@@ -0,0 +1,38 @@
+package provider
+
+import "sync"
+
+// mutexKV hands out a mutex per key, ie. per audience, so that resources touching
+// the same server-side list don't interleave their read-modify-write cycles
+type mutexKV struct {
+	lock  sync.Mutex
+	store map[string]*sync.Mutex
+}
+
+// audienceLocks serializes pipeline assignment changes per audience within a run
+var audienceLocks = newMutexKV()
+
+func newMutexKV() *mutexKV {
+	return &mutexKV{store: make(map[string]*sync.Mutex)}
+}
+
+func (m *mutexKV) Lock(key string) {
+	m.get(key).Lock()
+}
+
+func (m *mutexKV) Unlock(key string) {
+	m.get(key).Unlock()
+}
+
+func (m *mutexKV) get(key string) *sync.Mutex {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	mu, ok := m.store[key]
+	if !ok {
+		mu = &sync.Mutex{}
+		m.store[key] = mu
+	}
+
+	return mu
+}
@@ -0,0 +1,314 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+
+	"github.com/streamdal/streamdal/libs/protos/build/go/protos"
+	"github.com/streamdal/terraform-provider-streamdal/streamdal"
+	"github.com/streamdal/terraform-provider-streamdal/util"
+)
+
+// pipelineAudienceSchema returns the schema of an audiences{} block on a pipeline,
+// which refers to an audience either by its ID or by its fields
+func pipelineAudienceSchema() *schema.Resource {
+	return &schema.Resource{
+		Schema: map[string]*schema.Schema{
+			"id": {
+				Description: "Audience string, ie. the ID of a streamdal_audience. Conflicts with the other attributes",
+				Type:        schema.TypeString,
+				Optional:    true,
+			},
+			"service_name": {
+				Description: "The name of the service",
+				Type:        schema.TypeString,
+				Optional:    true,
+			},
+			"component_name": {
+				Description: "The name of the component",
+				Type:        schema.TypeString,
+				Optional:    true,
+			},
+			"operation_name": {
+				Description: "The name of the operation",
+				Type:        schema.TypeString,
+				Optional:    true,
+			},
+			"operation_type": {
+				Description:  "The type of the operation, either `consumer` or `producer`",
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: getAudienceOperationTypes(),
+			},
+		},
+	}
+}
+
+// configuredPipelineAudiences converts the audiences{} blocks of a pipeline
+func configuredPipelineAudiences(blocks []interface{}) ([]*protos.Audience, error) {
+	audiences := make([]*protos.Audience, 0, len(blocks))
+
+	for i, block := range blocks {
+		cfg, _ := block.(map[string]interface{})
+
+		aud, err := pipelineAudience(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("audiences.%d: %s", i, err)
+		}
+
+		audiences = append(audiences, aud)
+	}
+
+	return audiences, nil
+}
+
+func pipelineAudience(cfg map[string]interface{}) (*protos.Audience, error) {
+	id, _ := cfg["id"].(string)
+	fields := []string{"service_name", "component_name", "operation_name", "operation_type"}
+
+	set := 0
+	for _, k := range fields {
+		if v, _ := cfg[k].(string); v != "" {
+			set++
+		}
+	}
+
+	switch {
+	case id != "" && set > 0:
+		return nil, fmt.Errorf("id conflicts with the audience fields")
+	case id != "":
+		aud := util.AudienceFromStr(id)
+		if aud == nil {
+			return nil, fmt.Errorf("invalid audience id '%s'", id)
+		}
+
+		return aud, nil
+	case set < len(fields):
+		return nil, fmt.Errorf("either id or all of service_name, component_name, operation_name and operation_type must be set")
+	}
+
+	return &protos.Audience{
+		ServiceName:   cfg["service_name"].(string),
+		ComponentName: cfg["component_name"].(string),
+		OperationType: audienceOperationTypeFromString(cfg["operation_type"].(string)),
+		OperationName: cfg["operation_name"].(string),
+	}, nil
+}
+
+// diffPipelineAudiences validates the audiences{} blocks and records them as claims
+// on the audiences, see assignmentClaims
+func diffPipelineAudiences(d *schema.ResourceDiff) error {
+	if !d.NewValueKnown("audiences") {
+		return nil
+	}
+
+	audiences, err := configuredPipelineAudiences(d.Get("audiences").([]interface{}))
+	if err != nil {
+		return err
+	}
+
+	// The name is known before the pipeline is created, unlike its ID
+	owner := fmt.Sprintf("the audiences block of pipeline '%s'", d.Get("name").(string))
+
+	return claims.assignments(owner, audienceStrings(audiences))
+}
+
+// assignPipelineAudiences merges the pipeline into the lists of the audiences it
+// is configured for, and removes it from audiences which are no longer configured
+func assignPipelineAudiences(ctx context.Context, d *schema.ResourceData, client *streamdal.Streamdal) diag.Diagnostics {
+	oldBlocks, newBlocks := d.GetChange("audiences")
+
+	previous, err := configuredPipelineAudiences(oldBlocks.([]interface{}))
+	if err != nil {
+		previous = []*protos.Audience{}
+	}
+
+	configured, err := configuredPipelineAudiences(newBlocks.([]interface{}))
+	if err != nil {
+		return diag.Errorf("Error assigning pipeline: %s", err)
+	}
+
+	keep := make(map[string]bool)
+	for _, aud := range configured {
+		keep[util.AudienceToStr(aud)] = true
+	}
+
+	for _, aud := range previous {
+		if keep[util.AudienceToStr(aud)] {
+			continue
+		}
+
+		if err := unassignPipeline(ctx, client, aud, d.Id()); err != nil {
+			return diag.Errorf("Error removing pipeline from audience '%s': %s", util.AudienceToStr(aud), err)
+		}
+	}
+
+	for _, aud := range configured {
+		err := updateAudiencePipelines(ctx, client, aud, func(ids []string) []string {
+			if stringInSlice(d.Id(), ids) {
+				return ids
+			}
+
+			return append(ids, d.Id())
+		})
+		if err != nil {
+			return diag.Errorf("Error assigning pipeline to audience '%s': %s", util.AudienceToStr(aud), err)
+		}
+	}
+
+	return nil
+}
+
+// readPipelineAudiences drops configured audiences the pipeline is no longer
+// assigned to, so that the next plan assigns it again
+func readPipelineAudiences(ctx context.Context, d *schema.ResourceData, client *streamdal.Streamdal) diag.Diagnostics {
+	blocks := d.Get("audiences").([]interface{})
+	if len(blocks) == 0 {
+		return nil
+	}
+
+	all, err := client.GetAll(ctx)
+	if err != nil {
+		return diag.Errorf("Error reading pipeline audiences: %s", err)
+	}
+
+	assigned := make([]interface{}, 0, len(blocks))
+
+	for _, block := range blocks {
+		aud, err := pipelineAudience(block.(map[string]interface{}))
+		if err != nil {
+			continue
+		}
+
+		if stringInSlice(d.Id(), audiencePipelineIDs(all, aud)) {
+			assigned = append(assigned, block)
+		}
+	}
+
+	_ = d.Set("audiences", assigned)
+
+	return nil
+}
+
+// assignedAudiences returns every audience the pipeline is assigned to
+func assignedAudiences(all *protos.GetAllResponse, pipelineID string) []*protos.Audience {
+	assigned := make([]*protos.Audience, 0)
+
+	for _, aud := range all.GetAudiences() {
+		if stringInSlice(pipelineID, audiencePipelineIDs(all, aud)) {
+			assigned = append(assigned, aud)
+		}
+	}
+
+	for _, aud := range all.GetPipelines()[pipelineID].GetAudiences() {
+		if !util.AudienceInList(aud, assigned) {
+			assigned = append(assigned, aud)
+		}
+	}
+
+	return assigned
+}
+
+// runningAudiences returns the audiences among assigned which have connected
+// clients and on which the pipeline isn't paused
+func runningAudiences(all *protos.GetAllResponse, pipelineID string, assigned []*protos.Audience) []*protos.Audience {
+	running := make([]*protos.Audience, 0)
+
+	for _, aud := range assigned {
+		paused := false
+		for _, cfg := range audiencePipelineConfigs(all, aud) {
+			if cfg.GetId() == pipelineID {
+				paused = cfg.GetPaused()
+			}
+		}
+
+		if !paused && hasLiveClients(all, aud) {
+			running = append(running, aud)
+		}
+	}
+
+	return running
+}
+
+// assignmentClaims records which audiences are managed authoritatively through
+// streamdal_audience, and which resources add single pipelines to audiences: the
+// audiences{} blocks of pipelines, streamdal_audience_pipeline and
+// streamdal_pipeline_assignment. Managing the same audience both ways makes the
+// resources undo each other's changes on every apply. Claims are recorded during
+// the plan, so only conflicts between resources planned together are found.
+type assignmentClaims struct {
+	lock sync.Mutex
+
+	// authoritative contains audiences whose full pipeline list is configured
+	authoritative map[string]bool
+
+	// owners contains the audiences claimed by each resource adding pipelines,
+	// keyed by a description of the resource
+	owners map[string][]string
+}
+
+var claims = newAssignmentClaims()
+
+func newAssignmentClaims() *assignmentClaims {
+	return &assignmentClaims{
+		authoritative: make(map[string]bool),
+		owners:        make(map[string][]string),
+	}
+}
+
+// audience claims an audience's full pipeline list when managed is true, or
+// releases the claim otherwise
+func (c *assignmentClaims) audience(audStr string, managed bool) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if !managed {
+		delete(c.authoritative, audStr)
+		return nil
+	}
+
+	c.authoritative[audStr] = true
+
+	owners := make([]string, 0)
+	for owner, audStrs := range c.owners {
+		if stringInSlice(audStr, audStrs) {
+			owners = append(owners, owner)
+		}
+	}
+
+	if len(owners) > 0 {
+		sort.Strings(owners)
+		return claimConflict(audStr, owners[0])
+	}
+
+	return nil
+}
+
+// assignments claims the audiences a resource adds a pipeline to, replacing the
+// audiences it claimed before. owner describes the resource, ie. "the audiences
+// block of pipeline 'find pii'", and must not change between plans.
+func (c *assignmentClaims) assignments(owner string, audStrs []string) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.owners[owner] = audStrs
+
+	for _, audStr := range audStrs {
+		if c.authoritative[audStr] {
+			return claimConflict(audStr, owner)
+		}
+	}
+
+	return nil
+}
+
+func claimConflict(audStr, owner string) error {
+	return fmt.Errorf("audience '%s' is assigned pipelines by both a streamdal_audience resource and %s; "+
+		"each would remove the other's assignments. Manage the audience's assignments in one place",
+		audStr, owner)
+}
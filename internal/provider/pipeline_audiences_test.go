@@ -0,0 +1,310 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+
+	"github.com/streamdal/streamdal/libs/protos/build/go/protos"
+	"github.com/streamdal/terraform-provider-streamdal/util"
+)
+
+func TestPipelineAudience(t *testing.T) {
+	cases := map[string]struct {
+		cfg      map[string]interface{}
+		expected string
+		err      string
+	}{
+		"id": {
+			cfg:      map[string]interface{}{"id": "signups:operation_type_consumer:new-users:kafka"},
+			expected: "signups:operation_type_consumer:new-users:kafka",
+		},
+		"fields": {
+			cfg: map[string]interface{}{
+				"service_name":   "signups",
+				"component_name": "kafka",
+				"operation_name": "new-users",
+				"operation_type": "consumer",
+			},
+			expected: "signups:operation_type_consumer:new-users:kafka",
+		},
+		"both": {
+			cfg: map[string]interface{}{"id": "signups:operation_type_consumer:new-users:kafka", "service_name": "signups"},
+			err: "conflicts",
+		},
+		"missing fields": {
+			cfg: map[string]interface{}{"service_name": "signups"},
+			err: "must be set",
+		},
+		"invalid id": {
+			cfg: map[string]interface{}{"id": "signups"},
+			err: "invalid audience id",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			aud, err := pipelineAudience(tc.cfg)
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("expected error containing '%s', got: %v", tc.err, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := util.AudienceToStr(aud); got != tc.expected {
+				t.Errorf("expected %s, got %s", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestAssignmentClaims(t *testing.T) {
+	c := newAssignmentClaims()
+
+	if err := c.assignments("find-pii", []string{"a"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Claiming again, ie. on the next plan, isn't a conflict
+	if err := c.assignments("find-pii", []string{"a"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.audience("b", true); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.audience("a", true); err == nil || !strings.Contains(err.Error(), "find-pii") {
+		t.Errorf("expected conflict for pipeline claimed first, got: %v", err)
+	}
+
+	if err := c.assignments("mask-emails", []string{"b"}); err == nil || !strings.Contains(err.Error(), "'b'") {
+		t.Errorf("expected conflict for audience claimed first, got: %v", err)
+	}
+
+	// Claims which are no longer made are released
+	if err := c.audience("b", false); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.assignments("mask-emails", []string{"b"}); err != nil {
+		t.Errorf("expected released audience claim not to conflict, got: %v", err)
+	}
+
+	if err := c.assignments("find-pii", []string{"c"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.audience("a", true); err != nil {
+		t.Errorf("expected released pipeline claim not to conflict, got: %v", err)
+	}
+}
+
+func TestAssignmentClaims_Resources(t *testing.T) {
+	aud := testAudience("claims", "kafka", "orders", protos.OperationType_OPERATION_TYPE_CONSUMER)
+	audStr := util.AudienceToStr(aud)
+
+	claims = newAssignmentClaims()
+	defer func() { claims = newAssignmentClaims() }()
+
+	audienceCfg := map[string]interface{}{
+		"service_name":   "claims",
+		"component_name": "kafka",
+		"operation_type": "consumer",
+		"operation_name": "orders",
+	}
+
+	managed := map[string]interface{}{"pipeline_ids": []interface{}{"p1"}}
+	for k, v := range audienceCfg {
+		managed[k] = v
+	}
+
+	if _, err := resourceAudience().Diff(context.Background(), nil, terraform.NewResourceConfigRaw(managed), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	binding := map[string]interface{}{"pipeline_id": "p2"}
+	for k, v := range audienceCfg {
+		binding[k] = v
+	}
+
+	_, err := resourceAudiencePipeline().Diff(context.Background(), nil, terraform.NewResourceConfigRaw(binding), nil)
+	if err == nil || !strings.Contains(err.Error(), "streamdal_audience_pipeline assigning pipeline 'p2'") {
+		t.Errorf("expected a conflict with streamdal_audience_pipeline, got: %v", err)
+	}
+
+	client := newFakeStreamdal(&fakeExternalClient{getAll: &protos.GetAllResponse{Audiences: []*protos.Audience{aud}}})
+
+	_, err = resourcePipelineAssignment().Diff(context.Background(), nil, terraform.NewResourceConfigRaw(map[string]interface{}{
+		"pipeline_id": "p3",
+		"selector":    []interface{}{map[string]interface{}{"service_name": "claims"}},
+	}), client)
+	if err == nil || !strings.Contains(err.Error(), "streamdal_pipeline_assignment of pipeline 'p3'") {
+		t.Errorf("expected a conflict with streamdal_pipeline_assignment, got: %v", err)
+	}
+
+	// Once the audience stops managing its assignments, they no longer conflict
+	if _, err := resourceAudience().Diff(context.Background(), nil, terraform.NewResourceConfigRaw(audienceCfg), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := resourceAudiencePipeline().Diff(context.Background(), nil, terraform.NewResourceConfigRaw(binding), nil); err != nil {
+		t.Errorf("unexpected conflict for %s: %s", audStr, err)
+	}
+}
+
+func TestAssignPipelineAudiences(t *testing.T) {
+	kept := testAudience("signups", "kafka", "new-users", protos.OperationType_OPERATION_TYPE_CONSUMER)
+	removed := testAudience("billing", "kafka", "orders", protos.OperationType_OPERATION_TYPE_CONSUMER)
+	added := testAudience("billing", "kafka", "invoices", protos.OperationType_OPERATION_TYPE_PRODUCER)
+
+	fake := &fakeExternalClient{getAll: &protos.GetAllResponse{
+		Audiences: []*protos.Audience{kept, removed, added},
+		Configs: map[string]*protos.PipelineConfigs{
+			util.AudienceToStr(kept):    {Configs: []*protos.PipelineConfig{{Id: "other"}, {Id: "pii"}}},
+			util.AudienceToStr(removed): {Configs: []*protos.PipelineConfig{{Id: "pii"}, {Id: "other"}}},
+		},
+	}}
+	client := newFakeStreamdal(fake)
+	ctx := context.Background()
+
+	r := resourcePipeline()
+	state := &terraform.InstanceState{
+		ID: "pii",
+		Attributes: map[string]string{
+			"id":                         "pii",
+			"name":                       "find pii",
+			"schema_compatibility":       compatibilityOff,
+			"audiences.#":                "2",
+			"audiences.0.id":             util.AudienceToStr(kept),
+			"audiences.1.service_name":   "billing",
+			"audiences.1.component_name": "kafka",
+			"audiences.1.operation_name": "orders",
+			"audiences.1.operation_type": "consumer",
+		},
+	}
+
+	cfg := terraform.NewResourceConfigRaw(map[string]interface{}{
+		"name":                 "find pii",
+		"schema_compatibility": compatibilityOff,
+		"audiences": []interface{}{
+			map[string]interface{}{"id": util.AudienceToStr(kept)},
+			map[string]interface{}{"id": util.AudienceToStr(added)},
+		},
+	})
+
+	diff, err := schema.InternalMap(r.Schema).Diff(ctx, state, cfg, nil, nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := schema.InternalMap(r.Schema).Data(state, diff)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if diags := assignPipelineAudiences(ctx, d, client); diags.HasError() {
+		t.Fatalf("unexpected error: %v", diags)
+	}
+
+	all, _ := fake.GetAll(ctx, nil)
+
+	expected := map[*protos.Audience]string{
+		kept:    "[other pii]",
+		removed: "[other]",
+		added:   "[pii]",
+	}
+
+	for aud, ids := range expected {
+		if got := fmt.Sprint(audiencePipelineIDs(all, aud)); got != ids {
+			t.Errorf("audience '%s': expected %s, got %s", util.AudienceToStr(aud), ids, got)
+		}
+	}
+
+	// Assignments removed outside of Terraform are dropped from state
+	_, _ = fake.SetPipelines(ctx, &protos.SetPipelinesRequest{Audience: added, PipelineIds: []string{}})
+
+	if diags := readPipelineAudiences(ctx, d, client); diags.HasError() {
+		t.Fatalf("unexpected error: %v", diags)
+	}
+
+	if n := len(d.Get("audiences").([]interface{})); n != 1 {
+		t.Errorf("expected 1 remaining audience, got %d", n)
+	}
+}
+
+func TestResourcePipelineDelete(t *testing.T) {
+	live := testAudience("signups", "kafka", "new-users", protos.OperationType_OPERATION_TYPE_CONSUMER)
+	paused := testAudience("billing", "kafka", "orders", protos.OperationType_OPERATION_TYPE_CONSUMER)
+
+	server := func() *protos.GetAllResponse {
+		return &protos.GetAllResponse{
+			Audiences: []*protos.Audience{live, paused},
+			Configs: map[string]*protos.PipelineConfigs{
+				util.AudienceToStr(live):   {Configs: []*protos.PipelineConfig{{Id: "pii"}, {Id: "other"}}},
+				util.AudienceToStr(paused): {Configs: []*protos.PipelineConfig{{Id: "pii", Paused: true}}},
+			},
+			Live: []*protos.LiveInfo{
+				{Audiences: []*protos.Audience{live, paused}, Client: &protos.ClientInfo{LibraryName: "go-sdk"}},
+			},
+		}
+	}
+
+	pipelineData := func(protected bool) *schema.ResourceData {
+		d := schema.TestResourceDataRaw(t, resourcePipeline().Schema, map[string]interface{}{
+			"name":                "find pii",
+			"deletion_protection": protected,
+		})
+		d.SetId("pii")
+
+		return d
+	}
+
+	t.Run("protected", func(t *testing.T) {
+		fake := &fakeExternalClient{getAll: server()}
+
+		diags := resourcePipelineDelete(context.Background(), pipelineData(true), newFakeStreamdal(fake))
+		if !diags.HasError() {
+			t.Fatal("expected deletion to be refused")
+		}
+
+		if !strings.Contains(diags[0].Detail, util.AudienceToStr(live)) || strings.Contains(diags[0].Detail, util.AudienceToStr(paused)) {
+			t.Errorf("expected only the running audience to be listed, got: %s", diags[0].Detail)
+		}
+
+		if len(fake.setPipelines) != 0 || len(fake.deletedPipelines) != 0 {
+			t.Error("expected no changes to be made")
+		}
+	})
+
+	t.Run("detaches", func(t *testing.T) {
+		fake := &fakeExternalClient{getAll: server()}
+
+		if diags := resourcePipelineDelete(context.Background(), pipelineData(false), newFakeStreamdal(fake)); diags.HasError() {
+			t.Fatalf("unexpected error: %v", diags)
+		}
+
+		all, _ := fake.GetAll(context.Background(), nil)
+		if got := assignedAudiences(all, "pii"); len(got) != 0 {
+			t.Errorf("expected pipeline to be detached, still assigned to: %v", audienceStrings(got))
+		}
+
+		if got := fmt.Sprint(audiencePipelineIDs(all, live)); got != "[other]" {
+			t.Errorf("expected other assignments to be kept, got: %s", got)
+		}
+
+		if fmt.Sprint(fake.deletedPipelines) != "[pii]" {
+			t.Errorf("expected pipeline to be deleted, got: %v", fake.deletedPipelines)
+		}
+	})
+}
@@ -0,0 +1,154 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+
+	"github.com/streamdal/streamdal/libs/protos/build/go/protos"
+	"github.com/streamdal/terraform-provider-streamdal/streamdal"
+	"github.com/streamdal/terraform-provider-streamdal/util"
+)
+
+const (
+	propagationFail = "fail"
+	propagationWarn = "warn"
+)
+
+// waitForPropagationSchema returns the schema of the wait_for_propagation block
+// shared by pipelines and audiences
+func waitForPropagationSchema() *schema.Schema {
+	return &schema.Schema{
+		Description: "Wait after apply until the affected audiences have live SDK clients to deliver the change to",
+		Type:        schema.TypeList,
+		Optional:    true,
+		MaxItems:    1,
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"timeout": {
+					Description:  "How long to wait, as a duration such as `30s` or `5m`",
+					Type:         schema.TypeString,
+					Optional:     true,
+					Default:      "2m",
+					ValidateFunc: validateDuration(),
+				},
+				"on_timeout": {
+					Description:  "What to do when audiences still have no live clients after the timeout, either `fail` or `warn`",
+					Type:         schema.TypeString,
+					Optional:     true,
+					Default:      propagationWarn,
+					ValidateFunc: validation.StringInSlice([]string{propagationFail, propagationWarn}, false),
+				},
+			},
+		},
+	}
+}
+
+// validateDuration returns a validation function which checks that a string can be
+// parsed by time.ParseDuration
+func validateDuration() schema.SchemaValidateFunc {
+	return func(i interface{}, k string) ([]string, []error) {
+		v, ok := i.(string)
+		if !ok {
+			return nil, []error{fmt.Errorf("expected type of %s to be string", k)}
+		}
+
+		if d, err := time.ParseDuration(v); err != nil || d <= 0 {
+			return nil, []error{fmt.Errorf("%s: expected a positive duration such as '30s' or '5m', got '%s'", k, v)}
+		}
+
+		return nil, nil
+	}
+}
+
+// waitForPropagation watches GetAllStream until every audience returned by affected
+// has at least one live client, if wait_for_propagation is configured. affected is
+// called with each update, since assignments may still be changing.
+func waitForPropagation(ctx context.Context, d *schema.ResourceData, client *streamdal.Streamdal,
+	affected func(*protos.GetAllResponse) []*protos.Audience) diag.Diagnostics {
+	cfg := firstBlock(d.Get("wait_for_propagation"))
+	if cfg == nil {
+		return nil
+	}
+
+	timeout, err := time.ParseDuration(cfg["timeout"].(string))
+	if err != nil {
+		return diag.Errorf("Error waiting for propagation: %s", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	stream, err := client.GetAllStream(ctx)
+	if err != nil {
+		return diag.Errorf("Error waiting for propagation: %s", err)
+	}
+
+	var waiting []*protos.Audience
+
+	for {
+		resp, err := stream.Recv()
+		if err != nil {
+			if ctx.Err() == nil {
+				return diag.Errorf("Error waiting for propagation: %s", err)
+			}
+
+			break
+		}
+
+		if resp.GetXKeepalive() {
+			continue
+		}
+
+		waiting = audiencesWithoutClients(resp, affected(resp))
+		if len(waiting) == 0 {
+			return nil
+		}
+	}
+
+	severity := diag.Warning
+	if cfg["on_timeout"].(string) == propagationFail {
+		severity = diag.Error
+	}
+
+	detail := fmt.Sprintf("The server sent no state within %s", timeout)
+	if waiting != nil {
+		detail = fmt.Sprintf("No SDK clients connected to these audiences within %s, so the change will only "+
+			"take effect once they connect: %s", timeout, strings.Join(audienceStrings(waiting), ", "))
+	}
+
+	return diag.Diagnostics{{
+		Severity: severity,
+		Summary:  "Change has not reached any live clients",
+		Detail:   detail,
+	}}
+}
+
+// audiencesWithoutClients returns the audiences which no live client has announced
+func audiencesWithoutClients(all *protos.GetAllResponse, audiences []*protos.Audience) []*protos.Audience {
+	waiting := make([]*protos.Audience, 0)
+
+	for _, aud := range audiences {
+		if !hasLiveClients(all, aud) {
+			waiting = append(waiting, aud)
+		}
+	}
+
+	return waiting
+}
+
+// hasLiveClients returns true if a connected client has announced the audience
+func hasLiveClients(all *protos.GetAllResponse, aud *protos.Audience) bool {
+	for _, live := range all.GetLive() {
+		if util.AudienceInList(aud, live.GetAudiences()) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,77 @@
+package provider
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+
+	"github.com/streamdal/streamdal/libs/protos/build/go/protos"
+	"github.com/streamdal/terraform-provider-streamdal/util"
+)
+
+func TestWaitForPropagation(t *testing.T) {
+	connected := testAudience("signups", "kafka", "new-users", protos.OperationType_OPERATION_TYPE_CONSUMER)
+	idle := testAudience("billing", "kafka", "orders", protos.OperationType_OPERATION_TYPE_CONSUMER)
+
+	keepalive := true
+	withClients := func(audiences ...*protos.Audience) *protos.GetAllResponse {
+		return &protos.GetAllResponse{
+			Audiences: []*protos.Audience{connected, idle},
+			Configs: map[string]*protos.PipelineConfigs{
+				util.AudienceToStr(connected): {Configs: []*protos.PipelineConfig{{Id: "pii"}}},
+				util.AudienceToStr(idle):      {Configs: []*protos.PipelineConfig{{Id: "pii"}}},
+			},
+			Live: []*protos.LiveInfo{{Audiences: audiences}},
+		}
+	}
+
+	pipelineData := func(onTimeout string) *schema.ResourceData {
+		d := schema.TestResourceDataRaw(t, resourcePipeline().Schema, map[string]interface{}{
+			"name": "find pii",
+			"wait_for_propagation": []interface{}{
+				map[string]interface{}{"timeout": "50ms", "on_timeout": onTimeout},
+			},
+		})
+		d.SetId("pii")
+
+		return d
+	}
+
+	t.Run("clients connect", func(t *testing.T) {
+		fake := &fakeExternalClient{stream: []*protos.GetAllResponse{
+			withClients(connected),
+			{XKeepalive: &keepalive},
+			withClients(connected, idle),
+		}}
+
+		if diags := waitForPipelinePropagation(context.Background(), pipelineData(propagationFail), newFakeStreamdal(fake)); len(diags) != 0 {
+			t.Fatalf("unexpected diagnostics: %v", diags)
+		}
+	})
+
+	for onTimeout, severity := range map[string]diag.Severity{propagationWarn: diag.Warning, propagationFail: diag.Error} {
+		t.Run("timeout "+onTimeout, func(t *testing.T) {
+			fake := &fakeExternalClient{stream: []*protos.GetAllResponse{withClients(connected)}}
+
+			diags := waitForPipelinePropagation(context.Background(), pipelineData(onTimeout), newFakeStreamdal(fake))
+			if len(diags) != 1 || diags[0].Severity != severity {
+				t.Fatalf("expected a single diagnostic with severity %v, got: %v", severity, diags)
+			}
+
+			if !strings.Contains(diags[0].Detail, util.AudienceToStr(idle)) || strings.Contains(diags[0].Detail, util.AudienceToStr(connected)) {
+				t.Errorf("expected only the audience without clients to be listed, got: %s", diags[0].Detail)
+			}
+		})
+	}
+
+	t.Run("not configured", func(t *testing.T) {
+		d := schema.TestResourceDataRaw(t, resourcePipeline().Schema, map[string]interface{}{"name": "find pii"})
+
+		if diags := waitForPipelinePropagation(context.Background(), d, newFakeStreamdal(&fakeExternalClient{})); len(diags) != 0 {
+			t.Fatalf("unexpected diagnostics: %v", diags)
+		}
+	})
+}
@@ -29,9 +29,9 @@ func New(version, apiToken string) func() *schema.Provider {
 		p := &schema.Provider{
 			Schema: map[string]*schema.Schema{
 				"token": {
-					Description: "Streamdal Server API token",
+					Description: "Streamdal Server API token. Only needed by resources and data sources which talk to the server",
 					Type:        schema.TypeString,
-					Required:    true,
+					Optional:    true,
 					DefaultFunc: schema.EnvDefaultFunc("STREAMDAL_TOKEN", apiToken),
 				},
 				"address": {
@@ -48,14 +48,22 @@ func New(version, apiToken string) func() *schema.Provider {
 				},
 			},
 			ResourcesMap: map[string]*schema.Resource{
-				"streamdal_pipeline":     resourcePipeline(),
-				"streamdal_notification": resourceNotification(),
-				"streamdal_audience":     resourceAudience(),
+				"streamdal_pipeline":            resourcePipeline(),
+				"streamdal_notification":        resourceNotification(),
+				"streamdal_audience":            resourceAudience(),
+				"streamdal_audience_pipeline":   resourceAudiencePipeline(),
+				"streamdal_pipeline_assignment": resourcePipelineAssignment(),
 			},
 			DataSourcesMap: map[string]*schema.Resource{
-				"streamdal_pipeline":     dataSourcePipeline(),
-				"streamdal_notification": dataSourceNotification(),
-				"streamdal_audience":     dataSourceAudience(),
+				"streamdal_pipeline":            dataSourcePipeline(),
+				"streamdal_notification":        dataSourceNotification(),
+				"streamdal_audience":            dataSourceAudience(),
+				"streamdal_audience_schema":     dataSourceAudienceSchema(),
+				"streamdal_inferred_schema":     dataSourceInferredSchema(),
+				"streamdal_pipeline_simulation": dataSourcePipelineSimulation(),
+				"streamdal_live_clients":        dataSourceLiveClients(),
+				"streamdal_metrics":             dataSourceMetrics(),
+				"streamdal_audience_rates":      dataSourceAudienceRates(),
 			},
 		}
 
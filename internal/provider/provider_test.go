@@ -1,9 +1,14 @@
 package provider
 
 import (
+	"context"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+
+	"github.com/streamdal/terraform-provider-streamdal/streamdal"
 )
 
 // providerFactories are used to instantiate a provider during acceptance testing.
@@ -33,6 +38,29 @@ func TestProvider(t *testing.T) {
 	}
 }
 
+func TestProviderConfigure_Offline(t *testing.T) {
+	t.Setenv("STREAMDAL_TOKEN", "")
+
+	p := New("dev", "")()
+
+	// Nothing listens on port 1, configuring must not need the server or a token
+	diags := p.Configure(context.Background(), terraform.NewResourceConfigRaw(map[string]interface{}{
+		"address":            "127.0.0.1:1",
+		"connection_timeout": 1,
+	}))
+	if diags.HasError() {
+		t.Fatalf("unexpected error: %v", diags)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := p.Meta().(*streamdal.Streamdal).GetAll(ctx)
+	if err == nil || !serverUnreachable(err) {
+		t.Fatalf("expected the first request to fail as unreachable, got: %v", err)
+	}
+}
+
 func testAccPreCheck(t *testing.T) {
 	// You can add code here to run prior to any test case execution, for example assertions
 	// about the appropriate environment variables being set are common to see in a pre-check
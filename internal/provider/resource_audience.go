@@ -2,7 +2,12 @@ package provider
 
 import (
 	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
 
+	"github.com/hashicorp/go-cty/cty"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 
@@ -14,12 +19,35 @@ import (
 func resourceAudience() *schema.Resource {
 	sch := audienceSchema()
 	sch["pipeline_ids"] = &schema.Schema{
-		Type:     schema.TypeList,
-		Optional: true,
+		Type:          schema.TypeList,
+		Optional:      true,
+		ConflictsWith: []string{"pipeline"},
 		Elem: &schema.Schema{
 			Type: schema.TypeString,
 		},
 	}
+	sch["pipeline"] = &schema.Schema{
+		Description:   "Pipelines to assign to the audience, in the order they run",
+		Type:          schema.TypeList,
+		Optional:      true,
+		ConflictsWith: []string{"pipeline_ids"},
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"id": {
+					Description: "ID of the pipeline",
+					Type:        schema.TypeString,
+					Required:    true,
+				},
+				"paused": {
+					Description: "Pause the pipeline for this audience only",
+					Type:        schema.TypeBool,
+					Optional:    true,
+					Default:     false,
+				},
+			},
+		},
+	}
+	sch["wait_for_propagation"] = waitForPropagationSchema()
 
 	return &schema.Resource{
 		CreateContext: resourceAudienceCreate,
@@ -27,15 +55,65 @@ func resourceAudience() *schema.Resource {
 		UpdateContext: resourceAudienceUpdate,
 		DeleteContext: resourceAudienceDelete,
 
+		CustomizeDiff: resourceAudienceCustomizeDiff,
+
+		Importer: &schema.ResourceImporter{
+			StateContext: resourceAudienceImport,
+		},
+
 		Schema: sch,
 	}
 }
 
-func resourceAudienceCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-	var diags diag.Diagnostics
+// resourceAudienceCustomizeDiff claims the audience's pipeline assignments when
+// they are managed by this resource, see assignmentClaims
+func resourceAudienceCustomizeDiff(_ context.Context, d *schema.ResourceDiff, _ interface{}) error {
+	return claims.audience(audienceDiffID(d), assignmentsManaged(d.GetRawConfig(), d))
+}
+
+// assignmentsManaged reports whether the audience's pipeline assignments are managed
+// by the resource, which is when pipeline_ids or pipeline blocks are set in raw, the
+// raw config or state. An empty list manages an audience without pipelines, while
+// leaving both out leaves the assignments to streamdal_audience_pipeline, the
+// audiences blocks of pipelines or the Streamdal UI. Without a raw value, ie. for
+// resource data built by hand, non-empty lists count as set.
+func assignmentsManaged(raw cty.Value, d interface{ Get(string) interface{} }) bool {
+	if raw.IsNull() || !raw.IsKnown() {
+		return len(d.Get("pipeline_ids").([]interface{})) > 0 || len(d.Get("pipeline").([]interface{})) > 0
+	}
+
+	return !raw.GetAttr("pipeline_ids").IsNull() || !raw.GetAttr("pipeline").IsNull()
+}
+
+// audienceDiffID returns the audience string of the audience being planned
+func audienceDiffID(d *schema.ResourceDiff) string {
+	if d.Id() != "" {
+		return d.Id()
+	}
+
+	return util.AudienceToStr(&protos.Audience{
+		ServiceName:   d.Get("service_name").(string),
+		ComponentName: d.Get("component_name").(string),
+		OperationType: audienceOperationTypeFromString(d.Get("operation_type").(string)),
+		OperationName: d.Get("operation_name").(string),
+	})
+}
 
+func resourceAudienceCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
 	client := m.(*streamdal.Streamdal)
 
+	diags := createAudience(ctx, d, client)
+	if diags.HasError() {
+		return diags
+	}
+
+	return append(diags, waitForAudiencePropagation(ctx, d, client)...)
+}
+
+// createAudience creates the audience and assigns its pipelines while holding the audience lock
+func createAudience(ctx context.Context, d *schema.ResourceData, client *streamdal.Streamdal) diag.Diagnostics {
+	var diags diag.Diagnostics
+
 	aud := &protos.Audience{
 		ServiceName:   d.Get("service_name").(string),
 		ComponentName: d.Get("component_name").(string),
@@ -43,67 +121,182 @@ func resourceAudienceCreate(ctx context.Context, d *schema.ResourceData, m inter
 		OperationName: d.Get("operation_name").(string),
 	}
 
+	audStr := util.AudienceToStr(aud)
+
+	// Assignments which aren't managed are left to other resources
+	if !assignmentsManaged(d.GetRawConfig(), d) {
+		if _, err := client.CreateAudience(ctx, &protos.CreateAudienceRequest{Audience: aud}); err != nil {
+			return diag.FromErr(err)
+		}
+
+		d.SetId(audStr)
+
+		return diags
+	}
+
+	audienceLocks.Lock(audStr)
+	defer audienceLocks.Unlock(audStr)
+
+	pipelineIDs, paused := configuredAudiencePipelines(d)
+
+	// The audience may already exist, ie. because a client announced it. Refuse to
+	// replace assignments made elsewhere, the same way an update does.
+	all, err := client.GetAll(ctx)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	if util.AudienceInList(aud, all.GetAudiences()) {
+		current := audiencePipelineIDs(all, aud)
+
+		if len(current) > 0 && !slices.Equal(current, pipelineIDs) {
+			return diag.Diagnostics{{
+				Severity: diag.Error,
+				Summary:  "Audience already has pipeline assignments",
+				Detail: fmt.Sprintf("Audience '%s' already exists with pipelines assigned: %s\n\n"+
+					"Creating it would overwrite them. Import it with `terraform import` to manage its existing assignments.",
+					audStr, formatIDs(current)),
+				AttributePath: cty.GetAttrPath("pipeline_ids"),
+			}}
+		}
+	}
+
 	if _, err := client.CreateAudience(ctx, &protos.CreateAudienceRequest{Audience: aud}); err != nil {
 		return diag.FromErr(err)
 	}
 
-	d.SetId(util.AudienceToStr(aud))
+	d.SetId(audStr)
 
 	// Assign pipelines
-	pipelineIDs := interfaceToStrings(d.Get("pipeline_ids"))
 	if _, err := client.SetPipelines(ctx, aud, pipelineIDs); err != nil {
 		return diag.FromErr(err)
 	}
 
-	return diags
+	return append(diags, syncPausedPipelines(ctx, client, aud, paused)...)
 }
 
-// TODO: both GetAudience() and GetPipelinesForAudience() call GetAll(). Let's see if we can combine them
 func resourceAudienceRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
 	var diags diag.Diagnostics
 
 	client := m.(*streamdal.Streamdal)
 
-	aud, err := client.GetAudience(ctx, d.Id())
-	if err != nil {
-		return diag.FromErr(err)
+	aud := util.AudienceFromStr(d.Id())
+	if aud == nil {
+		return diag.Errorf("Error reading audience: invalid audience id '%s'", d.Id())
 	}
 
-	// Get pipeline assignments. These come from a GetAll() call
-	pipelineIDs, err := client.GetPipelinesForAudience(ctx, aud)
+	// Audience and pipeline assignments both come from a single GetAll() call
+	all, err := client.GetAll(ctx)
 	if err != nil {
 		return diag.FromErr(err)
 	}
 
+	if !util.AudienceInList(aud, all.GetAudiences()) {
+		return diag.Errorf("Error reading audience: audience not found")
+	}
+
 	_ = d.Set("service_name", aud.ServiceName)
 	_ = d.Set("component_name", aud.ComponentName)
 	_ = d.Set("operation_name", aud.OperationName)
 	_ = d.Set("operation_type", audienceOperationTypeToString(aud.OperationType))
-	_ = d.Set("pipeline_ids", pipelineIDs)
+
+	// Assignments which aren't managed by this resource are made by others, reading
+	// them into state would make the next plan remove them
+	if assignmentsManaged(d.GetRawState(), d) {
+		readAudiencePipelines(d, all, aud)
+	}
 
 	return diags
 }
 
-func resourceAudienceUpdate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-	var diags diag.Diagnostics
+// resourceAudienceImport reads the audience's assignments into state, so that an
+// imported audience manages them
+func resourceAudienceImport(ctx context.Context, d *schema.ResourceData, m interface{}) ([]*schema.ResourceData, error) {
+	client := m.(*streamdal.Streamdal)
+
+	aud := util.AudienceFromStr(d.Id())
+	if aud == nil {
+		return nil, fmt.Errorf("invalid audience id '%s'", d.Id())
+	}
+
+	all, err := client.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	readAudiencePipelines(d, all, aud)
+
+	return []*schema.ResourceData{d}, nil
+}
+
+// readAudiencePipelines sets the audience's assignments in state
+func readAudiencePipelines(d *schema.ResourceData, all *protos.GetAllResponse, aud *protos.Audience) {
+	configs := audiencePipelineConfigs(all, aud)
+
+	// Only one of pipeline_ids and pipeline blocks is configured. Imported audiences
+	// use pipeline blocks when pause state needs to be kept.
+	if usePipelineBlocks(d, configs) {
+		_ = d.Set("pipeline", flattenPipelineConfigs(configs))
+		_ = d.Set("pipeline_ids", nil)
+	} else {
+		_ = d.Set("pipeline_ids", audiencePipelineIDs(all, aud))
+		_ = d.Set("pipeline", nil)
+	}
+}
 
+func resourceAudienceUpdate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
 	client := m.(*streamdal.Streamdal)
 
+	diags := updateAudience(ctx, d, client)
+	if diags.HasError() {
+		return diags
+	}
+
+	return append(diags, waitForAudiencePropagation(ctx, d, client)...)
+}
+
+// updateAudience replaces the audience pipeline assignments while holding the audience lock
+func updateAudience(ctx context.Context, d *schema.ResourceData, client *streamdal.Streamdal) diag.Diagnostics {
+	var diags diag.Diagnostics
+
 	// Verify audience exists, otherwise error out.
 	// Audiences only support updates to pipeline assignments, not the actual audience data itself.
-	aud, err := client.GetAudience(ctx, d.Id())
+	aud := util.AudienceFromStr(d.Id())
+	if aud == nil {
+		return diag.Errorf("Error updating audience: invalid audience id '%s'", d.Id())
+	}
+
+	// Assignments which are no longer managed are left as they are
+	if !assignmentsManaged(d.GetRawConfig(), d) {
+		return diags
+	}
+
+	audienceLocks.Lock(d.Id())
+	defer audienceLocks.Unlock(d.Id())
+
+	all, err := client.GetAll(ctx)
 	if err != nil {
 		return diag.FromErr(err)
 	}
 
+	if !util.AudienceInList(aud, all.GetAudiences()) {
+		return diag.Errorf("Error updating audience: audience not found")
+	}
+
+	// SetPipelines replaces the whole assignment list, so refuse to overwrite
+	// assignments made by someone else since the last refresh
+	if moreDiags := checkAudienceConflict(d, audiencePipelineIDs(all, aud)); moreDiags.HasError() {
+		return moreDiags
+	}
+
 	// Update pipeline assignments
-	pipelineIDs := interfaceToStrings(d.Get("pipeline_ids"))
+	pipelineIDs, paused := configuredAudiencePipelines(d)
 
 	if _, err := client.SetPipelines(ctx, aud, pipelineIDs); err != nil {
 		return diag.FromErr(err)
 	}
 
-	return diags
+	return append(diags, syncPausedPipelines(ctx, client, aud, paused)...)
 }
 
 func resourceAudienceDelete(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
@@ -118,3 +311,181 @@ func resourceAudienceDelete(ctx context.Context, d *schema.ResourceData, m inter
 
 	return diags
 }
+
+// waitForAudiencePropagation waits for live clients on the audience when it has
+// pipelines assigned, see waitForPropagation. This runs after the audience lock is
+// released, so other resources can update the audience in the meantime.
+func waitForAudiencePropagation(ctx context.Context, d *schema.ResourceData, client *streamdal.Streamdal) diag.Diagnostics {
+	aud := util.AudienceFromStr(d.Id())
+
+	if pipelineIDs, _ := configuredAudiencePipelines(d); aud == nil || len(pipelineIDs) == 0 {
+		return nil
+	}
+
+	return waitForPropagation(ctx, d, client, func(_ *protos.GetAllResponse) []*protos.Audience {
+		return []*protos.Audience{aud}
+	})
+}
+
+// configuredAudiencePipelines returns the configured pipeline assignments. When they
+// are configured with pipeline blocks, the pause state of each pipeline is returned
+// as well; pipeline_ids leaves pause state alone.
+func configuredAudiencePipelines(d *schema.ResourceData) ([]string, map[string]bool) {
+	blocks := d.Get("pipeline").([]interface{})
+	if len(blocks) == 0 {
+		return interfaceToStrings(d.Get("pipeline_ids")), nil
+	}
+
+	ids := make([]string, 0, len(blocks))
+	paused := make(map[string]bool)
+
+	for _, block := range blocks {
+		cfg, ok := block.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		id := cfg["id"].(string)
+		ids = append(ids, id)
+		paused[id] = cfg["paused"].(bool)
+	}
+
+	return ids, paused
+}
+
+// usePipelineBlocks decides whether assignments are read into pipeline blocks
+// rather than pipeline_ids
+func usePipelineBlocks(d *schema.ResourceData, configs []*protos.PipelineConfig) bool {
+	if len(d.Get("pipeline").([]interface{})) > 0 {
+		return true
+	}
+
+	if len(d.Get("pipeline_ids").([]interface{})) > 0 {
+		return false
+	}
+
+	for _, cfg := range configs {
+		if cfg.GetPaused() {
+			return true
+		}
+	}
+
+	return false
+}
+
+func flattenPipelineConfigs(configs []*protos.PipelineConfig) []interface{} {
+	out := make([]interface{}, 0, len(configs))
+
+	for _, cfg := range configs {
+		out = append(out, map[string]interface{}{
+			"id":     cfg.GetId(),
+			"paused": cfg.GetPaused(),
+		})
+	}
+
+	return out
+}
+
+// syncPausedPipelines pauses or resumes the audience's pipelines to match the
+// configured pause state. Newly assigned pipelines start out active.
+func syncPausedPipelines(ctx context.Context, client *streamdal.Streamdal, aud *protos.Audience, paused map[string]bool) diag.Diagnostics {
+	if paused == nil {
+		return nil
+	}
+
+	all, err := client.GetAll(ctx)
+	if err != nil {
+		return diag.Errorf("Error reading audience pipeline assignments: %s", err)
+	}
+
+	for _, cfg := range audiencePipelineConfigs(all, aud) {
+		want := paused[cfg.GetId()]
+		if cfg.GetPaused() == want {
+			continue
+		}
+
+		if want {
+			if _, err := client.PausePipeline(ctx, aud, cfg.GetId()); err != nil {
+				return diag.Errorf("Error pausing pipeline '%s': %s", cfg.GetId(), err)
+			}
+		} else {
+			if _, err := client.ResumePipeline(ctx, aud, cfg.GetId()); err != nil {
+				return diag.Errorf("Error resuming pipeline '%s': %s", cfg.GetId(), err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// observedAudiencePipelines returns the pipeline assignments in the prior state.
+// Read stores the server's assignments there on every refresh, so these are the
+// assignments last seen on the server.
+func observedAudiencePipelines(d *schema.ResourceData) []string {
+	oldBlocks, _ := d.GetChange("pipeline")
+
+	if blocks, _ := oldBlocks.([]interface{}); len(blocks) > 0 {
+		ids := make([]string, 0, len(blocks))
+
+		for _, block := range blocks {
+			if cfg, ok := block.(map[string]interface{}); ok {
+				ids = append(ids, cfg["id"].(string))
+			}
+		}
+
+		return ids
+	}
+
+	oldIDs, _ := d.GetChange("pipeline_ids")
+
+	return interfaceToStrings(oldIDs)
+}
+
+// checkAudienceConflict compares the audience's current pipeline assignments with
+// those observed during the last refresh
+func checkAudienceConflict(d *schema.ResourceData, current []string) diag.Diagnostics {
+	observed := observedAudiencePipelines(d)
+
+	// Order matters, it is the order in which the pipelines run
+	if slices.Equal(observed, current) {
+		return nil
+	}
+
+	return diag.Diagnostics{{
+		Severity: diag.Error,
+		Summary:  "Audience pipeline assignments changed outside of Terraform",
+		Detail: fmt.Sprintf("The pipelines assigned to audience '%s' changed since they were last read.\n\n"+
+			"Expected: %s\nFound:    %s\n\n"+
+			"Applying would overwrite these changes. Run `terraform apply` again to refresh and plan against the current assignments.",
+			d.Id(), formatIDs(observed), formatIDs(current)),
+		AttributePath: cty.GetAttrPath("pipeline_ids"),
+	}}
+}
+
+// sameStrings reports whether a and b contain the same strings, ignoring order
+func sameStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	a = append([]string{}, a...)
+	b = append([]string{}, b...)
+	sort.Strings(a)
+	sort.Strings(b)
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func formatIDs(ids []string) string {
+	if len(ids) == 0 {
+		return "(none)"
+	}
+
+	return strings.Join(ids, ", ")
+}
@@ -0,0 +1,228 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+
+	"github.com/streamdal/streamdal/libs/protos/build/go/protos"
+	"github.com/streamdal/terraform-provider-streamdal/streamdal"
+	"github.com/streamdal/terraform-provider-streamdal/util"
+)
+
+// assignmentAttempts is how many times a merged assignment list is written before
+// giving up on a concurrent writer, ie. another workspace or the UI
+const assignmentAttempts = 3
+
+func resourceAudiencePipeline() *schema.Resource {
+	sch := audienceSchema()
+	for _, s := range sch {
+		s.ForceNew = true
+	}
+
+	sch["pipeline_id"] = &schema.Schema{
+		Description: "ID of the pipeline to assign to the audience",
+		Type:        schema.TypeString,
+		Required:    true,
+		ForceNew:    true,
+	}
+
+	return &schema.Resource{
+		Description: "Assigns a single pipeline to an audience, leaving any other assignments in place",
+
+		CreateContext: resourceAudiencePipelineCreate,
+		ReadContext:   resourceAudiencePipelineRead,
+		DeleteContext: resourceAudiencePipelineDelete,
+
+		CustomizeDiff: resourceAudiencePipelineCustomizeDiff,
+
+		Importer: &schema.ResourceImporter{
+			StateContext: resourceAudiencePipelineImport,
+		},
+
+		Schema: sch,
+	}
+}
+
+// resourceAudiencePipelineCustomizeDiff claims the assignment of the pipeline to the
+// audience, see assignmentClaims
+func resourceAudiencePipelineCustomizeDiff(_ context.Context, d *schema.ResourceDiff, _ interface{}) error {
+	for _, k := range []string{"service_name", "component_name", "operation_type", "operation_name"} {
+		if !d.NewValueKnown(k) {
+			return nil
+		}
+	}
+
+	audStr := util.AudienceToStr(&protos.Audience{
+		ServiceName:   d.Get("service_name").(string),
+		ComponentName: d.Get("component_name").(string),
+		OperationType: audienceOperationTypeFromString(d.Get("operation_type").(string)),
+		OperationName: d.Get("operation_name").(string),
+	})
+
+	// The audience is part of the owner, since pipeline IDs aren't known before the
+	// pipeline is created
+	owner := fmt.Sprintf("the streamdal_audience_pipeline assigning pipeline '%s' to it", d.Get("pipeline_id").(string))
+	if !d.NewValueKnown("pipeline_id") {
+		owner = "a streamdal_audience_pipeline"
+	}
+
+	return claims.assignments(audStr+": "+owner, []string{audStr})
+}
+
+// audiencePipelineID joins the audience string and pipeline ID into a resource ID
+func audiencePipelineID(aud *protos.Audience, pipelineID string) string {
+	return util.AudienceToStr(aud) + "/" + pipelineID
+}
+
+// parseAudiencePipelineID splits a resource ID created by audiencePipelineID
+func parseAudiencePipelineID(id string) (*protos.Audience, string, error) {
+	idx := strings.LastIndex(id, "/")
+	if idx < 1 || idx == len(id)-1 {
+		return nil, "", fmt.Errorf("invalid ID '%s', expected '<audience>/<pipeline_id>'", id)
+	}
+
+	aud := util.AudienceFromStr(id[:idx])
+	if aud == nil {
+		return nil, "", fmt.Errorf("invalid audience '%s'", id[:idx])
+	}
+
+	return aud, id[idx+1:], nil
+}
+
+func resourceAudiencePipelineCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	client := m.(*streamdal.Streamdal)
+
+	aud := &protos.Audience{
+		ServiceName:   d.Get("service_name").(string),
+		ComponentName: d.Get("component_name").(string),
+		OperationType: audienceOperationTypeFromString(d.Get("operation_type").(string)),
+		OperationName: d.Get("operation_name").(string),
+	}
+	pipelineID := d.Get("pipeline_id").(string)
+
+	err := updateAudiencePipelines(ctx, client, aud, func(ids []string) []string {
+		if stringInSlice(pipelineID, ids) {
+			return ids
+		}
+
+		return append(ids, pipelineID)
+	})
+	if err != nil {
+		return diag.Errorf("Error assigning pipeline: %s", err)
+	}
+
+	d.SetId(audiencePipelineID(aud, pipelineID))
+
+	return diags
+}
+
+func resourceAudiencePipelineRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	client := m.(*streamdal.Streamdal)
+
+	aud, pipelineID, err := parseAudiencePipelineID(d.Id())
+	if err != nil {
+		return diag.Errorf("Error reading pipeline assignment: %s", err)
+	}
+
+	all, err := client.GetAll(ctx)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	// The assignment was removed outside of Terraform, plan to recreate it
+	if !stringInSlice(pipelineID, audiencePipelineIDs(all, aud)) {
+		d.SetId("")
+		return diags
+	}
+
+	_ = d.Set("service_name", aud.ServiceName)
+	_ = d.Set("component_name", aud.ComponentName)
+	_ = d.Set("operation_name", aud.OperationName)
+	_ = d.Set("operation_type", audienceOperationTypeToString(aud.OperationType))
+	_ = d.Set("pipeline_id", pipelineID)
+
+	return diags
+}
+
+func resourceAudiencePipelineDelete(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	client := m.(*streamdal.Streamdal)
+
+	aud, pipelineID, err := parseAudiencePipelineID(d.Id())
+	if err != nil {
+		return diag.Errorf("Error removing pipeline assignment: %s", err)
+	}
+
+	if err := unassignPipeline(ctx, client, aud, pipelineID); err != nil {
+		return diag.Errorf("Error removing pipeline assignment: %s", err)
+	}
+
+	return diags
+}
+
+func resourceAudiencePipelineImport(ctx context.Context, d *schema.ResourceData, m interface{}) ([]*schema.ResourceData, error) {
+	if _, _, err := parseAudiencePipelineID(d.Id()); err != nil {
+		return nil, err
+	}
+
+	diags := resourceAudiencePipelineRead(ctx, d, m)
+	if diags.HasError() {
+		return nil, fmt.Errorf("unable to read pipeline assignment: %s", diags[0].Summary)
+	}
+
+	if d.Id() == "" {
+		return nil, fmt.Errorf("pipeline is not assigned to the audience")
+	}
+
+	return []*schema.ResourceData{d}, nil
+}
+
+// updateAudiencePipelines applies merge to the audience's current pipeline list and
+// writes the result with SetPipelines. Changes from the same run are serialized
+// per audience; changes made elsewhere between the read and the write are caught
+// by reading the list back, and the merge is retried on top of them.
+func updateAudiencePipelines(ctx context.Context, client *streamdal.Streamdal, aud *protos.Audience, merge func([]string) []string) error {
+	key := util.AudienceToStr(aud)
+
+	audienceLocks.Lock(key)
+	defer audienceLocks.Unlock(key)
+
+	for i := 0; i < assignmentAttempts; i++ {
+		all, err := client.GetAll(ctx)
+		if err != nil {
+			return err
+		}
+
+		current := audiencePipelineIDs(all, aud)
+		merged := merge(append([]string{}, current...))
+
+		if sameStrings(current, merged) {
+			return nil
+		}
+
+		if _, err := client.SetPipelines(ctx, aud, merged); err != nil {
+			return err
+		}
+
+		all, err = client.GetAll(ctx)
+		if err != nil {
+			return err
+		}
+
+		current = audiencePipelineIDs(all, aud)
+		if sameStrings(current, merge(append([]string{}, current...))) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("pipeline assignments for audience '%s' kept changing concurrently, gave up after %d attempts", key, assignmentAttempts)
+}
@@ -0,0 +1,139 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+
+	"github.com/streamdal/streamdal/libs/protos/build/go/protos"
+	"github.com/streamdal/terraform-provider-streamdal/util"
+)
+
+func bindingTestServer(ids ...string) *protos.GetAllResponse {
+	aud := &protos.Audience{
+		ServiceName:   "signups",
+		ComponentName: "kafka",
+		OperationType: protos.OperationType_OPERATION_TYPE_CONSUMER,
+		OperationName: "new-users",
+	}
+
+	cfgs := &protos.PipelineConfigs{}
+	for _, id := range ids {
+		cfgs.Configs = append(cfgs.Configs, &protos.PipelineConfig{Id: id})
+	}
+
+	return &protos.GetAllResponse{
+		Audiences: []*protos.Audience{aud},
+		Configs:   map[string]*protos.PipelineConfigs{util.AudienceToStr(aud): cfgs},
+	}
+}
+
+func bindingTestData(t *testing.T, pipelineID string) *schema.ResourceData {
+	return schema.TestResourceDataRaw(t, resourceAudiencePipeline().Schema, map[string]interface{}{
+		"service_name":   "signups",
+		"component_name": "kafka",
+		"operation_type": "consumer",
+		"operation_name": "new-users",
+		"pipeline_id":    pipelineID,
+	})
+}
+
+func serverPipelineIDs(fake *fakeExternalClient) []string {
+	all, _ := fake.GetAll(context.Background(), nil)
+	ids := audiencePipelineIDs(all, all.GetAudiences()[0])
+	sort.Strings(ids)
+
+	return ids
+}
+
+func TestResourceAudiencePipeline_CreateDelete(t *testing.T) {
+	fake := &fakeExternalClient{getAll: bindingTestServer("platform")}
+	client := newFakeStreamdal(fake)
+
+	d := bindingTestData(t, "product")
+	if diags := resourceAudiencePipelineCreate(context.Background(), d, client); diags.HasError() {
+		t.Fatalf("unexpected error: %v", diags)
+	}
+
+	if d.Id() != "signups:operation_type_consumer:new-users:kafka/product" {
+		t.Errorf("unexpected ID: %s", d.Id())
+	}
+
+	if got := serverPipelineIDs(fake); fmt.Sprint(got) != "[platform product]" {
+		t.Errorf("expected existing assignment to be kept, got: %v", got)
+	}
+
+	// Creating an existing binding doesn't call SetPipelines
+	calls := len(fake.setPipelines)
+	if diags := resourceAudiencePipelineCreate(context.Background(), bindingTestData(t, "product"), client); diags.HasError() {
+		t.Fatalf("unexpected error: %v", diags)
+	}
+
+	if len(fake.setPipelines) != calls {
+		t.Error("expected no SetPipelines call for an existing assignment")
+	}
+
+	if diags := resourceAudiencePipelineDelete(context.Background(), d, client); diags.HasError() {
+		t.Fatalf("unexpected error: %v", diags)
+	}
+
+	if got := serverPipelineIDs(fake); fmt.Sprint(got) != "[platform]" {
+		t.Errorf("expected only the binding to be removed, got: %v", got)
+	}
+
+	// Removed outside of Terraform
+	if diags := resourceAudiencePipelineRead(context.Background(), d, client); diags.HasError() {
+		t.Fatalf("unexpected error: %v", diags)
+	}
+
+	if d.Id() != "" {
+		t.Error("expected missing assignment to be removed from state")
+	}
+}
+
+func TestResourceAudiencePipeline_Concurrent(t *testing.T) {
+	fake := &fakeExternalClient{getAll: bindingTestServer()}
+	client := newFakeStreamdal(fake)
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 10; i++ {
+		d := bindingTestData(t, fmt.Sprintf("p%d", i))
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			if diags := resourceAudiencePipelineCreate(context.Background(), d, client); diags.HasError() {
+				t.Errorf("unexpected error: %v", diags)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if got := serverPipelineIDs(fake); len(got) != 10 {
+		t.Errorf("expected 10 assignments, got: %v", got)
+	}
+}
+
+func TestParseAudiencePipelineID(t *testing.T) {
+	aud, id, err := parseAudiencePipelineID("signups:operation_type_consumer:new-users:kafka/abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if id != "abc" || aud.GetServiceName() != "signups" {
+		t.Errorf("unexpected result: %v %s", aud, id)
+	}
+
+	for _, bad := range []string{"abc", "/abc", "signups:operation_type_consumer:new-users:kafka/", "nope/abc"} {
+		if _, _, err := parseAudiencePipelineID(bad); err == nil {
+			t.Errorf("expected error for '%s'", bad)
+		}
+	}
+}
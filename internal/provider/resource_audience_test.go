@@ -0,0 +1,345 @@
+package provider
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+
+	"github.com/streamdal/streamdal/libs/protos/build/go/protos"
+	"github.com/streamdal/terraform-provider-streamdal/util"
+)
+
+func TestResourceAudienceUpdate_Conflict(t *testing.T) {
+	aud := &protos.Audience{
+		ServiceName:   "signups",
+		ComponentName: "kafka",
+		OperationType: protos.OperationType_OPERATION_TYPE_CONSUMER,
+		OperationName: "new-users",
+	}
+
+	all := func(ids ...string) *protos.GetAllResponse {
+		cfgs := make([]*protos.PipelineConfig, 0)
+		for _, id := range ids {
+			cfgs = append(cfgs, &protos.PipelineConfig{Id: id})
+		}
+
+		return &protos.GetAllResponse{
+			Audiences:              []*protos.Audience{aud},
+			Configs:                map[string]*protos.PipelineConfigs{util.AudienceToStr(aud): {Configs: cfgs}},
+			GeneratedAtUnixTsNsUtc: 1700000000000000000,
+		}
+	}
+
+	// The prior state holds p1 and p2, as read during the last refresh
+	cases := map[string]struct {
+		server  *protos.GetAllResponse
+		wantErr bool
+	}{
+		"unchanged": {
+			server: all("p1", "p2"),
+		},
+		"reordered": {
+			server:  all("p2", "p1"),
+			wantErr: true,
+		},
+		"changed": {
+			server:  all("p1", "p2", "p3"),
+			wantErr: true,
+		},
+		"removed": {
+			server:  all("p1"),
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			fake := &fakeExternalClient{getAll: tc.server}
+
+			r := resourceAudience()
+			d := r.Data(&terraform.InstanceState{
+				ID: util.AudienceToStr(aud),
+				Attributes: map[string]string{
+					"id":             util.AudienceToStr(aud),
+					"service_name":   "signups",
+					"component_name": "kafka",
+					"operation_type": "consumer",
+					"operation_name": "new-users",
+					"pipeline_ids.#": "2",
+					"pipeline_ids.0": "p1",
+					"pipeline_ids.1": "p2",
+				},
+			})
+
+			if err := d.Set("pipeline_ids", []string{"p1"}); err != nil {
+				t.Fatal(err)
+			}
+
+			diags := resourceAudienceUpdate(context.Background(), d, newFakeStreamdal(fake))
+
+			if diags.HasError() != tc.wantErr {
+				t.Fatalf("expected error: %v, got: %v", tc.wantErr, diags)
+			}
+
+			if tc.wantErr {
+				if len(fake.setPipelines) != 0 {
+					t.Error("expected SetPipelines not to be called")
+				}
+
+				if diags[0].Summary != "Audience pipeline assignments changed outside of Terraform" {
+					t.Errorf("unexpected summary: %s", diags[0].Summary)
+				}
+
+				return
+			}
+
+			if len(fake.setPipelines) != 1 {
+				t.Fatalf("expected one SetPipelines call, got %d", len(fake.setPipelines))
+			}
+
+			if got := fake.setPipelines[0].PipelineIds; len(got) != 1 || got[0] != "p1" {
+				t.Errorf("unexpected pipeline IDs: %v", got)
+			}
+		})
+	}
+}
+
+func TestResourceAudience_PipelineBlocks(t *testing.T) {
+	aud := &protos.Audience{
+		ServiceName:   "signups",
+		ComponentName: "kafka",
+		OperationType: protos.OperationType_OPERATION_TYPE_CONSUMER,
+		OperationName: "new-users",
+	}
+
+	fake := &fakeExternalClient{getAll: &protos.GetAllResponse{
+		Audiences: []*protos.Audience{aud},
+		Configs: map[string]*protos.PipelineConfigs{
+			util.AudienceToStr(aud): {Configs: []*protos.PipelineConfig{{Id: "p1", Paused: true}, {Id: "p2"}, {Id: "p3"}}},
+		},
+	}}
+	client := newFakeStreamdal(fake)
+
+	d := schema.TestResourceDataRaw(t, resourceAudience().Schema, map[string]interface{}{
+		"service_name":   "signups",
+		"component_name": "kafka",
+		"operation_type": "consumer",
+		"operation_name": "new-users",
+		"pipeline": []interface{}{
+			map[string]interface{}{"id": "p1", "paused": false},
+			map[string]interface{}{"id": "p2", "paused": true},
+			map[string]interface{}{"id": "p3", "paused": true},
+		},
+	})
+
+	if diags := resourceAudienceCreate(context.Background(), d, client); diags.HasError() {
+		t.Fatalf("unexpected error: %v", diags)
+	}
+
+	all, _ := fake.GetAll(context.Background(), nil)
+
+	got := make(map[string]bool)
+	for _, cfg := range audiencePipelineConfigs(all, aud) {
+		got[cfg.GetId()] = cfg.GetPaused()
+	}
+
+	want := map[string]bool{"p1": false, "p2": true, "p3": true}
+	if len(got) != len(want) {
+		t.Fatalf("unexpected assignments: %v", got)
+	}
+
+	for id, paused := range want {
+		if got[id] != paused {
+			t.Errorf("expected pipeline '%s' paused=%v, got %v", id, paused, got[id])
+		}
+	}
+
+	if diags := resourceAudienceRead(context.Background(), d, client); diags.HasError() {
+		t.Fatalf("unexpected error: %v", diags)
+	}
+
+	if n := d.Get("pipeline.#").(int); n != 3 {
+		t.Fatalf("expected 3 pipeline blocks, got %d", n)
+	}
+
+	if !d.Get("pipeline.2.paused").(bool) || len(d.Get("pipeline_ids").([]interface{})) != 0 {
+		t.Errorf("unexpected state: %v %v", d.Get("pipeline"), d.Get("pipeline_ids"))
+	}
+
+	// Imported audiences only switch to pipeline blocks to keep pause state
+	imported := resourceAudience().Data(&terraform.InstanceState{ID: util.AudienceToStr(aud)})
+	if _, err := resourceAudienceImport(context.Background(), imported, client); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if diags := resourceAudienceRead(context.Background(), imported, client); diags.HasError() {
+		t.Fatalf("unexpected error: %v", diags)
+	}
+
+	if n := imported.Get("pipeline.#").(int); n != 3 {
+		t.Errorf("expected imported audience to use pipeline blocks, got %d", n)
+	}
+}
+
+func TestResourceAudienceCreate_Existing(t *testing.T) {
+	aud := testAudience("signups", "kafka", "new-users", protos.OperationType_OPERATION_TYPE_CONSUMER)
+
+	create := func(assigned ...string) (*fakeExternalClient, diag.Diagnostics) {
+		cfgs := make([]*protos.PipelineConfig, 0)
+		for _, id := range assigned {
+			cfgs = append(cfgs, &protos.PipelineConfig{Id: id})
+		}
+
+		fake := &fakeExternalClient{getAll: &protos.GetAllResponse{
+			Audiences: []*protos.Audience{aud},
+			Configs:   map[string]*protos.PipelineConfigs{util.AudienceToStr(aud): {Configs: cfgs}},
+		}}
+
+		d := schema.TestResourceDataRaw(t, resourceAudience().Schema, map[string]interface{}{
+			"service_name":   "signups",
+			"component_name": "kafka",
+			"operation_type": "consumer",
+			"operation_name": "new-users",
+			"pipeline_ids":   []interface{}{"p1", "p2"},
+		})
+
+		return fake, resourceAudienceCreate(context.Background(), d, newFakeStreamdal(fake))
+	}
+
+	// An existing audience without assignments, or with the configured ones, is taken over
+	for _, assigned := range [][]string{nil, {"p1", "p2"}} {
+		if fake, diags := create(assigned...); diags.HasError() || len(fake.setPipelines) != 1 {
+			t.Errorf("%v: expected the audience to be created, got: %v", assigned, diags)
+		}
+	}
+
+	// Other assignments, including the configured ones in another order, aren't overwritten
+	for _, assigned := range [][]string{{"p3"}, {"p2", "p1"}} {
+		fake, diags := create(assigned...)
+		if !diags.HasError() || diags[0].Summary != "Audience already has pipeline assignments" {
+			t.Errorf("%v: expected an error, got: %v", assigned, diags)
+		}
+
+		if len(fake.setPipelines) != 0 {
+			t.Errorf("%v: expected SetPipelines not to be called", assigned)
+		}
+	}
+}
+
+// audienceRawValue returns a raw config or state value of a streamdal_audience,
+// with the attributes not given left null
+func audienceRawValue(attrs map[string]cty.Value) cty.Value {
+	vals := make(map[string]cty.Value)
+
+	for name, attrType := range resourceAudience().CoreConfigSchema().ImpliedType().AttributeTypes() {
+		if v, ok := attrs[name]; ok {
+			vals[name] = v
+		} else {
+			vals[name] = cty.NullVal(attrType)
+		}
+	}
+
+	return cty.ObjectVal(vals)
+}
+
+func TestResourceAudience_UnmanagedAssignments(t *testing.T) {
+	fake := &fakeExternalClient{getAll: &protos.GetAllResponse{}}
+	client := newFakeStreamdal(fake)
+
+	r := resourceAudience()
+
+	raw := map[string]interface{}{
+		"service_name":   "signups",
+		"component_name": "kafka",
+		"operation_type": "consumer",
+		"operation_name": "new-users",
+	}
+
+	rawValue := audienceRawValue(map[string]cty.Value{
+		"service_name":   cty.StringVal("signups"),
+		"component_name": cty.StringVal("kafka"),
+		"operation_type": cty.StringVal("consumer"),
+		"operation_name": cty.StringVal("new-users"),
+	})
+
+	// The audience is created without pipeline_ids or pipeline blocks
+	diff, err := r.Diff(context.Background(), &terraform.InstanceState{RawConfig: rawValue}, terraform.NewResourceConfigRaw(raw), client)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	state, diags := r.Apply(context.Background(), &terraform.InstanceState{RawConfig: rawValue}, diff, client)
+	if diags.HasError() {
+		t.Fatalf("unexpected error: %v", diags)
+	}
+
+	if len(fake.setPipelines) != 0 {
+		t.Fatalf("expected no assignments to be made, got: %v", fake.setPipelines)
+	}
+
+	// A streamdal_audience_pipeline assigns a pipeline to it
+	if diags := resourceAudiencePipelineCreate(context.Background(), bindingTestData(t, "p1"), client); diags.HasError() {
+		t.Fatalf("unexpected error: %v", diags)
+	}
+
+	// Refreshing the audience doesn't read the binding's assignment into state,
+	// so the next plan doesn't remove it
+	state.RawState = rawValue
+	state.RawConfig = rawValue
+
+	state, diags = r.RefreshWithoutUpgrade(context.Background(), state, client)
+	if diags.HasError() {
+		t.Fatalf("unexpected error: %v", diags)
+	}
+
+	if n := state.Attributes["pipeline_ids.#"]; n != "" && n != "0" {
+		t.Fatalf("expected pipeline_ids to stay unset, got: %v", state.Attributes)
+	}
+
+	state.RawState = rawValue
+	state.RawConfig = rawValue
+
+	diff, err = r.Diff(context.Background(), state, terraform.NewResourceConfigRaw(raw), client)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if diff != nil && !diff.Empty() {
+		t.Fatalf("expected no changes, got: %v", diff.Attributes)
+	}
+
+	// Updates, ie. to wait_for_propagation, leave the assignments alone too
+	raw["wait_for_propagation"] = []interface{}{map[string]interface{}{"timeout": "1s"}}
+	rawValue = audienceRawValue(map[string]cty.Value{
+		"service_name":   cty.StringVal("signups"),
+		"component_name": cty.StringVal("kafka"),
+		"operation_type": cty.StringVal("consumer"),
+		"operation_name": cty.StringVal("new-users"),
+		"wait_for_propagation": cty.ListVal([]cty.Value{cty.ObjectVal(map[string]cty.Value{
+			"timeout":    cty.StringVal("1s"),
+			"on_timeout": cty.NullVal(cty.String),
+		})}),
+	})
+	state.RawConfig = rawValue
+
+	diff, err = r.Diff(context.Background(), state, terraform.NewResourceConfigRaw(raw), client)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, diags := r.Apply(context.Background(), state, diff, client); diags.HasError() {
+		t.Fatalf("unexpected error: %v", diags)
+	}
+
+	if len(fake.setPipelines) != 1 {
+		t.Fatalf("expected only the binding's assignment, got: %v", fake.setPipelines)
+	}
+
+	if got := serverPipelineIDs(fake); len(got) != 1 || got[0] != "p1" {
+		t.Errorf("expected the binding's assignment to be kept, got: %v", got)
+	}
+}
@@ -2,6 +2,9 @@ package provider
 
 import (
 	"context"
+	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
@@ -18,6 +21,10 @@ func resourceNotification() *schema.Resource {
 		UpdateContext: resourceNotificationUpdate,
 		DeleteContext: resourceNotificationDelete,
 
+		Importer: &schema.ResourceImporter{
+			StateContext: resourceNotificationImport,
+		},
+
 		Schema: map[string]*schema.Schema{
 			"id": {
 				Description: "The ID of the notification configuration",
@@ -33,6 +40,12 @@ func resourceNotification() *schema.Resource {
 				Required:     true,
 				ValidateFunc: getNotificationConfigTypes(),
 			},
+			"force_detach": {
+				Description: "Remove the notification from pipelines still using it when it is deleted, instead of failing",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+			},
 			"slack": {
 				Type:     schema.TypeList,
 				Optional: true,
@@ -187,6 +200,25 @@ func resourceNotificationRead(ctx context.Context, d *schema.ResourceData, m int
 	return diags
 }
 
+// resourceNotificationImport populates the configuration of an imported notification,
+// as Read only refreshes the ID
+func resourceNotificationImport(ctx context.Context, d *schema.ResourceData, m interface{}) ([]*schema.ResourceData, error) {
+	client := m.(*streamdal.Streamdal)
+
+	resp, err := client.GetNotification(ctx, &protos.GetNotificationRequest{NotificationId: d.Id()})
+	if err != nil {
+		return nil, err
+	}
+
+	for k, v := range flattenNotification(resp.GetNotification()) {
+		if err := d.Set(k, v); err != nil {
+			return nil, err
+		}
+	}
+
+	return []*schema.ResourceData{d}, nil
+}
+
 func resourceNotificationCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
 	var diags diag.Diagnostics
 
@@ -236,7 +268,37 @@ func resourceNotificationUpdate(ctx context.Context, d *schema.ResourceData, m i
 func resourceNotificationDelete(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
 	var diags diag.Diagnostics
 
-	_, err := m.(*streamdal.Streamdal).DeleteNotification(ctx, &protos.DeleteNotificationRequest{
+	client := m.(*streamdal.Streamdal)
+
+	pipelines, err := client.GetPipelines(ctx)
+	if err != nil {
+		return diag.Errorf("Error deleting notification: unable to look up pipelines: %s", err)
+	}
+
+	refs := notificationReferences(pipelines, d.Id())
+
+	if len(refs) > 0 && !d.Get("force_detach").(bool) {
+		lines := make([]string, 0, len(refs))
+		for _, ref := range refs {
+			lines = append(lines, ref.String())
+		}
+
+		return diag.Diagnostics{{
+			Severity: diag.Error,
+			Summary:  "Notification is still used by pipelines",
+			Detail: fmt.Sprintf("Deleting the notification would silently stop alerts from:\n\n  %s\n\n"+
+				"Remove it from these pipelines, or set force_detach = true to remove it automatically, before deleting it.",
+				strings.Join(lines, "\n  ")),
+		}}
+	}
+
+	for _, ref := range refs {
+		if moreDiags := detachNotification(ctx, client, ref, d.Id()); moreDiags.HasError() {
+			return moreDiags
+		}
+	}
+
+	_, err = client.DeleteNotification(ctx, &protos.DeleteNotificationRequest{
 		NotificationId: d.Id(),
 	})
 
@@ -247,6 +309,108 @@ func resourceNotificationDelete(ctx context.Context, d *schema.ResourceData, m i
 	return diags
 }
 
+// notificationReference is a pipeline which uses a notification config, either in
+// its step conditions or by having it attached with AttachNotification
+type notificationReference struct {
+	pipeline   *protos.Pipeline
+	conditions []string
+	attached   bool
+}
+
+func (r notificationReference) String() string {
+	uses := append([]string{}, r.conditions...)
+	if r.attached {
+		uses = append(uses, "attached to pipeline")
+	}
+
+	return fmt.Sprintf("pipeline '%s' (%s): %s", r.pipeline.GetName(), r.pipeline.GetId(), strings.Join(uses, ", "))
+}
+
+// notificationReferences returns the pipelines using the notification config
+func notificationReferences(pipelines []*protos.Pipeline, notificationID string) []notificationReference {
+	refs := make([]notificationReference, 0)
+
+	for _, p := range pipelines {
+		ref := notificationReference{pipeline: p}
+
+		for i, step := range p.GetSteps() {
+			for name, cond := range stepConditions(step) {
+				if stringInSlice(notificationID, cond.GetNotification().GetNotificationConfigIds()) {
+					ref.conditions = append(ref.conditions, fmt.Sprintf("step %d '%s' %s", i, step.GetName(), name))
+				}
+			}
+		}
+
+		sort.Strings(ref.conditions)
+
+		for _, cfg := range p.GetXNotificationConfigs() {
+			if cfg.GetId() == notificationID {
+				ref.attached = true
+			}
+		}
+
+		if len(ref.conditions) > 0 || ref.attached {
+			refs = append(refs, ref)
+		}
+	}
+
+	return refs
+}
+
+// stepConditions returns a step's conditions, keyed by attribute name
+func stepConditions(step *protos.PipelineStep) map[string]*protos.PipelineStepConditions {
+	conds := make(map[string]*protos.PipelineStepConditions)
+
+	for name, cond := range map[string]*protos.PipelineStepConditions{
+		"on_true":  step.GetOnTrue(),
+		"on_false": step.GetOnFalse(),
+		"on_error": step.GetOnError(),
+	} {
+		if cond != nil {
+			conds[name] = cond
+		}
+	}
+
+	return conds
+}
+
+// detachNotification removes the notification config from a pipeline's step
+// conditions and detaches it from the pipeline
+func detachNotification(ctx context.Context, client *streamdal.Streamdal, ref notificationReference, notificationID string) diag.Diagnostics {
+	p := ref.pipeline
+
+	if len(ref.conditions) > 0 {
+		for _, step := range p.GetSteps() {
+			for _, cond := range stepConditions(step) {
+				n := cond.GetNotification()
+				if n == nil {
+					continue
+				}
+
+				n.NotificationConfigIds = removeString(n.NotificationConfigIds, notificationID)
+				if len(n.NotificationConfigIds) == 0 {
+					cond.Notification = nil
+				}
+			}
+		}
+
+		// Notification configs are returned for display only
+		p.XNotificationConfigs = nil
+
+		if _, err := client.UpdatePipeline(ctx, &protos.UpdatePipelineRequest{Pipeline: p}); err != nil {
+			return diag.Errorf("Error removing notification from pipeline '%s': %s", p.GetId(), err)
+		}
+	}
+
+	if ref.attached {
+		if _, err := client.DetachNotification(ctx, notificationID, p.GetId()); err != nil {
+			return diag.Errorf("Error detaching notification from pipeline '%s': %s", p.GetId(), err)
+		}
+	}
+
+	return nil
+}
+
 func buildNotification(d *schema.ResourceData, m interface{}) (*protos.NotificationConfig, diag.Diagnostics) {
 	var diags diag.Diagnostics
 
@@ -352,3 +516,70 @@ func buildNotification(d *schema.ResourceData, m interface{}) (*protos.Notificat
 
 	return n, diags
 }
+
+// flattenNotification converts a notification config to the resource's attributes
+func flattenNotification(n *protos.NotificationConfig) map[string]interface{} {
+	out := map[string]interface{}{
+		"name":      n.GetName(),
+		"type":      notificationConfigTypeToString(n.GetType()),
+		"slack":     []interface{}{},
+		"pagerduty": []interface{}{},
+		"email":     []interface{}{},
+	}
+
+	if slack := n.GetSlack(); slack != nil {
+		out["slack"] = []interface{}{
+			map[string]interface{}{
+				"channel":   slack.GetChannel(),
+				"bot_token": slack.GetBotToken(),
+			},
+		}
+	}
+
+	if pd := n.GetPagerduty(); pd != nil {
+		out["pagerduty"] = []interface{}{
+			map[string]interface{}{
+				"token":      pd.GetToken(),
+				"email":      pd.GetEmail(),
+				"service_id": pd.GetServiceId(),
+				"urgency":    pagerDutyUrgencyTypeToString(pd.GetUrgency()),
+			},
+		}
+	}
+
+	if email := n.GetEmail(); email != nil {
+		emailMap := map[string]interface{}{
+			"type":         emailTypeToString(email.GetType()),
+			"recipients":   email.GetRecipients(),
+			"from_address": email.GetFromAddress(),
+			"smtp":         []interface{}{},
+			"ses":          []interface{}{},
+		}
+
+		if smtp := email.GetSmtp(); smtp != nil {
+			emailMap["smtp"] = []interface{}{
+				map[string]interface{}{
+					"host":     smtp.GetHost(),
+					"port":     int(smtp.GetPort()),
+					"user":     smtp.GetUser(),
+					"password": smtp.GetPassword(),
+					"use_tls":  smtp.GetUseTls(),
+				},
+			}
+		}
+
+		if ses := email.GetSes(); ses != nil {
+			emailMap["ses"] = []interface{}{
+				map[string]interface{}{
+					"ses_region":            ses.GetSesRegion(),
+					"ses_access_key":        ses.GetSesAccessKeyId(),
+					"ses_secret_access_key": ses.GetSesSecretAccessKey(),
+				},
+			}
+		}
+
+		out["email"] = []interface{}{emailMap}
+	}
+
+	return out
+}
@@ -0,0 +1,102 @@
+package provider
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/streamdal/streamdal/libs/protos/build/go/protos"
+)
+
+func TestResourceNotificationDelete(t *testing.T) {
+	notify := func(ids ...string) *protos.PipelineStepConditions {
+		return &protos.PipelineStepConditions{Notification: &protos.PipelineStepNotification{NotificationConfigIds: ids}}
+	}
+
+	pipelines := func() []*protos.Pipeline {
+		return []*protos.Pipeline{
+			{
+				Id:   "p1",
+				Name: "find pii",
+				Steps: []*protos.PipelineStep{
+					{Name: "detect", OnTrue: notify("alerts", "other"), OnError: notify("alerts")},
+				},
+			},
+			{
+				Id:                   "p2",
+				Name:                 "legacy",
+				XNotificationConfigs: []*protos.NotificationConfig{{Id: proto.String("alerts")}},
+			},
+			{
+				Id:    "p3",
+				Name:  "unrelated",
+				Steps: []*protos.PipelineStep{{Name: "detect", OnTrue: notify("other")}},
+			},
+		}
+	}
+
+	notificationData := func(force bool) *schema.ResourceData {
+		d := schema.TestResourceDataRaw(t, resourceNotification().Schema, map[string]interface{}{
+			"name":         "alerts",
+			"type":         "slack",
+			"force_detach": force,
+		})
+		d.SetId("alerts")
+
+		return d
+	}
+
+	t.Run("referenced", func(t *testing.T) {
+		fake := &fakeExternalClient{pipelines: pipelines()}
+
+		diags := resourceNotificationDelete(context.Background(), notificationData(false), newFakeStreamdal(fake))
+		if !diags.HasError() {
+			t.Fatal("expected deletion to fail")
+		}
+
+		for _, want := range []string{
+			"pipeline 'find pii' (p1): step 0 'detect' on_error, step 0 'detect' on_true",
+			"pipeline 'legacy' (p2): attached to pipeline",
+		} {
+			if !strings.Contains(diags[0].Detail, want) {
+				t.Errorf("expected detail to contain %q, got:\n%s", want, diags[0].Detail)
+			}
+		}
+
+		if strings.Contains(diags[0].Detail, "unrelated") {
+			t.Errorf("unexpected pipeline in detail:\n%s", diags[0].Detail)
+		}
+
+		if len(fake.deletedNotifications) != 0 {
+			t.Error("expected notification not to be deleted")
+		}
+	})
+
+	t.Run("force_detach", func(t *testing.T) {
+		fake := &fakeExternalClient{pipelines: pipelines()}
+
+		if diags := resourceNotificationDelete(context.Background(), notificationData(true), newFakeStreamdal(fake)); diags.HasError() {
+			t.Fatalf("unexpected error: %v", diags)
+		}
+
+		step := fake.pipelines[0].GetSteps()[0]
+		if ids := step.GetOnTrue().GetNotification().GetNotificationConfigIds(); len(ids) != 1 || ids[0] != "other" {
+			t.Errorf("expected only the deleted notification to be removed, got: %v", ids)
+		}
+
+		if step.GetOnError().GetNotification() != nil {
+			t.Error("expected empty notification to be removed")
+		}
+
+		if len(fake.detached) != 1 || fake.detached[0].GetPipelineId() != "p2" {
+			t.Errorf("expected notification to be detached from p2, got: %v", fake.detached)
+		}
+
+		if len(fake.deletedNotifications) != 1 {
+			t.Error("expected notification to be deleted")
+		}
+	})
+}
@@ -2,15 +2,22 @@ package provider
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
 	"strings"
 
 	"github.com/golang/protobuf/proto"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
 
 	"github.com/streamdal/streamdal/libs/protos/build/go/protos"
 	"github.com/streamdal/streamdal/libs/protos/build/go/protos/steps"
+	"github.com/streamdal/terraform-provider-streamdal/internal/schemas"
 	"github.com/streamdal/terraform-provider-streamdal/streamdal"
+	"github.com/streamdal/terraform-provider-streamdal/util"
 )
 
 func resourcePipeline() *schema.Resource {
@@ -22,6 +29,8 @@ func resourcePipeline() *schema.Resource {
 		UpdateContext: resourcePipelineUpdate,
 		DeleteContext: resourcePipelineDelete,
 
+		CustomizeDiff: resourcePipelineCustomizeDiff,
+
 		SchemaVersion: 1,
 		Schema: map[string]*schema.Schema{
 			"id": {
@@ -41,6 +50,75 @@ func resourcePipeline() *schema.Resource {
 				ConfigMode:  schema.SchemaConfigModeBlock,
 				Elem:        stepSchema(),
 			},
+			"audiences": {
+				Description: "Audiences to assign the pipeline to. The pipeline is added to each audience's pipelines, " +
+					"leaving any other assignments in place",
+				Type:     schema.TypeList,
+				Optional: true,
+				Elem:     pipelineAudienceSchema(),
+			},
+			"deletion_protection": {
+				Description: "Refuse to delete the pipeline while it is running on audiences with connected clients",
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+			},
+			"wait_for_propagation": waitForPropagationSchema(),
+			"schema_compatibility": {
+				Description: "How to handle schema_validation steps which live traffic on the pipeline's audiences may fail, " +
+					"based on the schemas inferred by the server. One of `off`, `warn` or `strict`",
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      compatibilityWarn,
+				ValidateFunc: getSchemaCompatibilityModes(),
+			},
+			"schema_compatibility_report": {
+				Description: "Ways in which live traffic may fail the pipeline's schema_validation steps",
+				Type:        schema.TypeList,
+				Computed:    true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"sdk_compatibility": {
+				Description: "How to handle steps which SDK clients connected to the pipeline's audiences are too old " +
+					"to run. One of `off`, `warn` or `strict`",
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      compatibilityWarn,
+				ValidateFunc: getSchemaCompatibilityModes(),
+			},
+			"sdk_minimum_version": {
+				Description: "Minimum version of an SDK library able to run a step type, checked by sdk_compatibility. " +
+					"Adds to and overrides the built-in minimums",
+				Type:     schema.TypeList,
+				Optional: true,
+				Elem:     sdkMinimumVersionSchema(),
+			},
+			"sdk_compatibility_report": {
+				Description: "Steps which SDK clients connected to the pipeline's audiences are too old to run",
+				Type:        schema.TypeList,
+				Computed:    true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"validation_warnings": {
+				Description: "Warnings from the plan-time validation of the pipeline's steps, ie. steps which need a path but have none",
+				Type:        schema.TypeList,
+				Computed:    true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"source_hashes": {
+				Description: "Hashes of the schemas generated from local files, keyed by step index. Used to detect changes to the files",
+				Type:        schema.TypeMap,
+				Computed:    true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
 		},
 
 		Importer: &schema.ResourceImporter{
@@ -95,9 +173,10 @@ func stepSchema() *schema.Resource {
 				Elem: &schema.Resource{
 					Schema: map[string]*schema.Schema{
 						"path": {
-							Description: "Path",
-							Type:        schema.TypeString,
-							Optional:    true,
+							Description:  "Path",
+							Type:         schema.TypeString,
+							Optional:     true,
+							ValidateFunc: validateJSONPath(),
 						},
 						"type": {
 							Description:  "Detective Type",
@@ -136,12 +215,14 @@ func stepSchema() *schema.Resource {
 							Description: "Replace value of a field",
 							Type:        schema.TypeList,
 							Optional:    true,
+							MaxItems:    1,
 							Elem: &schema.Resource{
 								Schema: map[string]*schema.Schema{
 									"path": {
-										Description: "Path",
-										Type:        schema.TypeString,
-										Optional:    true, // TODO: make optional or required based on value of "dynamic"
+										Description:  "Path",
+										Type:         schema.TypeString,
+										Optional:     true, // TODO: make optional or required based on value of "dynamic"
+										ValidateFunc: validateJSONPath(),
 									},
 									"value": {
 										Description: "Value",
@@ -155,6 +236,7 @@ func stepSchema() *schema.Resource {
 							Description: "Delete field",
 							Type:        schema.TypeList,
 							Optional:    true,
+							MaxItems:    1,
 							Elem: &schema.Resource{
 								Schema: map[string]*schema.Schema{
 									"paths": {
@@ -165,7 +247,8 @@ func stepSchema() *schema.Resource {
 											return []string{}, nil
 										},
 										Elem: &schema.Schema{
-											Type: schema.TypeString,
+											Type:         schema.TypeString,
+											ValidateFunc: validateJSONPath(),
 										},
 									},
 								},
@@ -175,12 +258,14 @@ func stepSchema() *schema.Resource {
 							Description: "Obfuscate value",
 							Type:        schema.TypeList,
 							Optional:    true,
+							MaxItems:    1,
 							Elem: &schema.Resource{
 								Schema: map[string]*schema.Schema{
 									"path": {
-										Description: "Path",
-										Type:        schema.TypeString,
-										Optional:    true,
+										Description:  "Path",
+										Type:         schema.TypeString,
+										Optional:     true,
+										ValidateFunc: validateJSONPath(),
 									},
 								},
 							},
@@ -189,12 +274,14 @@ func stepSchema() *schema.Resource {
 							Description: "Mask value",
 							Type:        schema.TypeList,
 							Optional:    true,
+							MaxItems:    1,
 							Elem: &schema.Resource{
 								Schema: map[string]*schema.Schema{
 									"path": {
-										Description: "Path",
-										Type:        schema.TypeString,
-										Optional:    true,
+										Description:  "Path",
+										Type:         schema.TypeString,
+										Optional:     true,
+										ValidateFunc: validateJSONPath(),
 									},
 									"mask": {
 										Description: "Mask",
@@ -210,6 +297,7 @@ func stepSchema() *schema.Resource {
 							Description: "Truncate value",
 							Type:        schema.TypeList,
 							Optional:    true,
+							MaxItems:    1,
 							Elem: &schema.Resource{
 								Schema: map[string]*schema.Schema{
 									"type": {
@@ -219,9 +307,16 @@ func stepSchema() *schema.Resource {
 										ValidateFunc: getTransformTruncateTypes(),
 									},
 									"path": {
-										Description: "Path",
-										Type:        schema.TypeString,
-										Optional:    true,
+										Description:  "Path",
+										Type:         schema.TypeString,
+										Optional:     true,
+										ValidateFunc: validateJSONPath(),
+									},
+									"value": {
+										Description:  "Maximum length in bytes, or percentage (1-100) of the original value to keep",
+										Type:         schema.TypeInt,
+										Required:     true,
+										ValidateFunc: validation.IntAtLeast(1),
 									},
 								},
 							},
@@ -230,6 +325,7 @@ func stepSchema() *schema.Resource {
 							Description: "Extract value",
 							Type:        schema.TypeList,
 							Optional:    true,
+							MaxItems:    1,
 							Elem: &schema.Resource{
 								Schema: map[string]*schema.Schema{
 									"paths": {
@@ -240,9 +336,16 @@ func stepSchema() *schema.Resource {
 											return []string{}, nil
 										},
 										Elem: &schema.Schema{
-											Type: schema.TypeString,
+											Type:         schema.TypeString,
+											ValidateFunc: validateJSONPath(),
 										},
 									},
+									"flatten": {
+										Description: "Flatten the extracted fields into a single-level JSON object",
+										Type:        schema.TypeBool,
+										Optional:    true,
+										Default:     false,
+									},
 								},
 							},
 						},
@@ -325,9 +428,57 @@ func stepSchema() *schema.Resource {
 										ValidateFunc: getSchemaValidationJSONSchemaDrafts(),
 									},
 									"json_schema": {
-										Description: "Schema Definition",
+										Description:      "Schema Definition. Validated against the selected draft at plan time. Conflicts with source_file",
+										Type:             schema.TypeString,
+										Optional:         true,
+										ValidateFunc:     validation.StringIsJSON,
+										DiffSuppressFunc: suppressEquivalentJSON,
+									},
+									"source_file": {
+										Description: "Path to a JSON schema file. Relative $refs are resolved and inlined before the schema is uploaded. Conflicts with json_schema",
 										Type:        schema.TypeString,
-										Required:    true,
+										Optional:    true,
+									},
+									"protobuf": {
+										Description: "Generate the schema from a protobuf message. Conflicts with json_schema",
+										Type:        schema.TypeList,
+										Optional:    true,
+										MaxItems:    1,
+										Elem: &schema.Resource{
+											Schema: map[string]*schema.Schema{
+												"descriptor_set_file": {
+													Description: "Path to a descriptor set generated with protoc --include_imports --descriptor_set_out",
+													Type:        schema.TypeString,
+													Required:    true,
+												},
+												"message": {
+													Description: "Fully qualified name of the message, ie. events.UserCreated",
+													Type:        schema.TypeString,
+													Required:    true,
+												},
+												"use_proto_names": {
+													Description: "Use the field names from the .proto file instead of their lowerCamelCase JSON names",
+													Type:        schema.TypeBool,
+													Optional:    true,
+													Default:     false,
+												},
+											},
+										},
+									},
+									"avro": {
+										Description: "Generate the schema from an Avro schema. Conflicts with json_schema",
+										Type:        schema.TypeList,
+										Optional:    true,
+										MaxItems:    1,
+										Elem: &schema.Resource{
+											Schema: map[string]*schema.Schema{
+												"schema_file": {
+													Description: "Path to an Avro schema (.avsc) file",
+													Type:        schema.TypeString,
+													Required:    true,
+												},
+											},
+										},
 									},
 								},
 							},
@@ -355,9 +506,234 @@ func resourcePipelineRead(ctx context.Context, d *schema.ResourceData, m interfa
 
 	d.SetId(opts.GetId())
 	_ = d.Set("name", opts.GetName())
-	_ = d.Set("steps", opts.GetSteps())
 
-	return diags
+	// Schemas generated from local files can't be converted back into the file
+	// they came from, so keep the configured file and track the uploaded schema
+	// by its hash instead
+	prior := d.Get("step").([]interface{})
+	flattened := flattenPipelineSteps(opts.GetSteps())
+
+	_ = d.Set("source_hashes", sourceHashes(prior, opts.GetSteps()))
+	_ = d.Set("step", preserveSourceFiles(prior, flattened))
+
+	// Local only setting, default it for imported pipelines
+	if _, ok := d.GetOk("schema_compatibility"); !ok {
+		_ = d.Set("schema_compatibility", compatibilityWarn)
+	}
+
+	return append(diags, readPipelineAudiences(ctx, d, s)...)
+}
+
+// resourcePipelineCustomizeDiff runs validations at plan time which need to look
+// at more than a single attribute
+func resourcePipelineCustomizeDiff(ctx context.Context, d *schema.ResourceDiff, m interface{}) error {
+	pipelineSteps := d.Get("step").([]interface{})
+
+	unknown := func(key string) bool {
+		return !d.NewValueKnown(key)
+	}
+
+	stepDiags := validatePipelineSteps(markUnknownLists(pipelineSteps, unknown))
+
+	if err := diagsToError(stepDiags); err != nil {
+		return err
+	}
+
+	if err := diffValidationWarnings(d, stepDiags); err != nil {
+		return err
+	}
+
+	if err := diffSourceHashes(d, pipelineSteps); err != nil {
+		return err
+	}
+
+	if err := diffPipelineAudiences(d); err != nil {
+		return err
+	}
+
+	if err := diffNotificationConfigIDs(ctx, pipelineSteps, m); err != nil {
+		return err
+	}
+
+	if err := diffSchemaCompatibility(ctx, d, m); err != nil {
+		return err
+	}
+
+	return diffSDKCompatibility(ctx, d, m)
+}
+
+// diffSourceHashes plans a change to source_hashes when the schema generated from
+// a step's local files differs from the one that was uploaded. This is what causes
+// changes to a file, or any file it references, to show up in the plan.
+func diffSourceHashes(d *schema.ResourceDiff, pipelineSteps []interface{}) error {
+	hashes := map[string]interface{}{}
+
+	for i, step := range pipelineSteps {
+		stepMap, _ := step.(map[string]interface{})
+
+		jsCfg := jsonSchemaConfig(stepMap)
+		if schemaSource(jsCfg) == "" {
+			continue
+		}
+
+		if schemaSourceUnknown(jsCfg) {
+			return d.SetNewComputed("source_hashes")
+		}
+
+		doc, err := jsonSchemaDocument(jsCfg)
+		if err != nil {
+			return fmt.Errorf("step.%d: unable to generate schema: %s", i, err)
+		}
+
+		hash, err := schemas.Hash(doc)
+		if err != nil {
+			return fmt.Errorf("step.%d: unable to hash schema: %s", i, err)
+		}
+
+		hashes[strconv.Itoa(i)] = hash
+	}
+
+	if reflect.DeepEqual(hashes, d.Get("source_hashes").(map[string]interface{})) {
+		return nil
+	}
+
+	return d.SetNew("source_hashes", hashes)
+}
+
+// diffNotificationConfigIDs checks that the notification configs referenced by the
+// step conditions exist. IDs which aren't known until apply, ie. of notifications
+// created in the same apply, are skipped, as is the check when the server can't
+// be reached.
+func diffNotificationConfigIDs(ctx context.Context, pipelineSteps []interface{}, m interface{}) error {
+	client, ok := m.(*streamdal.Streamdal)
+	if !ok || client == nil {
+		return nil
+	}
+
+	refs := notificationConfigRefs(pipelineSteps)
+	if len(refs) == 0 {
+		return nil
+	}
+
+	notifications, err := client.GetNotifications(ctx)
+	if err != nil {
+		if serverUnreachable(err) {
+			return nil
+		}
+
+		return fmt.Errorf("unable to check notification_config_ids: %s", err)
+	}
+
+	missing := make([]string, 0)
+
+	for _, ref := range refs {
+		if _, ok := notifications[ref.id]; !ok {
+			missing = append(missing, fmt.Sprintf("%s: notification config '%s' does not exist", ref.path, ref.id))
+		}
+	}
+
+	if len(missing) > 0 {
+		return errors.New(strings.Join(missing, "\n"))
+	}
+
+	return nil
+}
+
+// notificationConfigRef is a notification config ID referenced by a step condition
+type notificationConfigRef struct {
+	path string
+	id   string
+}
+
+// notificationConfigRefs returns the known notification config IDs referenced by
+// the step conditions
+func notificationConfigRefs(pipelineSteps []interface{}) []notificationConfigRef {
+	refs := make([]notificationConfigRef, 0)
+
+	for i, step := range pipelineSteps {
+		stepMap, _ := step.(map[string]interface{})
+
+		for _, cond := range conditionTypes {
+			notification := firstBlock(firstBlock(stepMap[cond])["notification"])
+
+			ids, _ := notification["notification_config_ids"].([]interface{})
+
+			for j, id := range ids {
+				idStr, _ := id.(string)
+				if idStr == "" || idStr == unknownVariableValue {
+					continue
+				}
+
+				refs = append(refs, notificationConfigRef{
+					path: fmt.Sprintf("step.%d.%s.0.notification.0.notification_config_ids.%d", i, cond, j),
+					id:   idStr,
+				})
+			}
+		}
+	}
+
+	return refs
+}
+
+// sourceHashes returns the hashes of the uploaded schemas for steps which are
+// generated from local files
+func sourceHashes(stepCfgs []interface{}, pipelineSteps []*protos.PipelineStep) map[string]interface{} {
+	hashes := map[string]interface{}{}
+
+	for i, step := range stepCfgs {
+		if i >= len(pipelineSteps) {
+			break
+		}
+
+		stepMap, _ := step.(map[string]interface{})
+
+		if schemaSource(jsonSchemaConfig(stepMap)) == "" {
+			continue
+		}
+
+		js := pipelineSteps[i].GetSchemaValidation().GetJsonSchema()
+		if js == nil {
+			continue
+		}
+
+		hash, err := schemas.Hash(js.GetJsonSchema())
+		if err != nil {
+			continue
+		}
+
+		hashes[strconv.Itoa(i)] = hash
+	}
+
+	return hashes
+}
+
+// preserveSourceFiles copies the schema source from the prior state into the
+// flattened steps, replacing the schema that was generated from it
+func preserveSourceFiles(prior, flattened []interface{}) []interface{} {
+	for i, step := range prior {
+		if i >= len(flattened) {
+			break
+		}
+
+		stepMap, _ := step.(map[string]interface{})
+
+		priorCfg := jsonSchemaConfig(stepMap)
+
+		source := schemaSource(priorCfg)
+		if source == "" {
+			continue
+		}
+
+		jsCfg := jsonSchemaConfig(flattened[i].(map[string]interface{}))
+		if jsCfg == nil {
+			continue
+		}
+
+		jsCfg[source] = priorCfg[source]
+		jsCfg["json_schema"] = ""
+	}
+
+	return flattened
 }
 
 func resourcePipelineCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
@@ -370,6 +746,10 @@ func resourcePipelineCreate(ctx context.Context, d *schema.ResourceData, m inter
 		return append(diags, moreDiags...)
 	}
 
+	diags = append(diags, validatePipelineSteps(d.Get("step").([]interface{}))...)
+	diags = append(diags, schemaCompatibilityWarnings(d)...)
+	diags = append(diags, sdkCompatibilityWarnings(d)...)
+
 	resp, err := client.CreatePipeline(ctx, &protos.CreatePipelineRequest{
 		Pipeline: pipeline,
 	})
@@ -378,8 +758,15 @@ func resourcePipelineCreate(ctx context.Context, d *schema.ResourceData, m inter
 	}
 
 	d.SetId(resp.PipelineId)
+	_ = d.Set("source_hashes", sourceHashes(d.Get("step").([]interface{}), pipeline.Steps))
 
-	return diags
+	if moreDiags := assignPipelineAudiences(ctx, d, client); moreDiags.HasError() {
+		return append(diags, moreDiags...)
+	}
+
+	diags = append(diags, schemaFetchWarnings(ctx, d, client)...)
+
+	return append(diags, waitForPipelinePropagation(ctx, d, client)...)
 }
 
 func resourcePipelineUpdate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
@@ -391,42 +778,116 @@ func resourcePipelineUpdate(ctx context.Context, d *schema.ResourceData, m inter
 		return append(diags, moreDiags...)
 	}
 
+	diags = append(diags, validatePipelineSteps(d.Get("step").([]interface{}))...)
+	diags = append(diags, schemaCompatibilityWarnings(d)...)
+	diags = append(diags, sdkCompatibilityWarnings(d)...)
+
 	client := m.(*streamdal.Streamdal)
-	_, err := client.UpdatePipeline(ctx, &protos.UpdatePipelineRequest{
-		Pipeline: p,
-	})
-	if err != nil {
-		return diag.FromErr(err)
+
+	// Only changes to the pipeline itself are deployed. Changes to the computed
+	// reports or to local only settings, ie. deletion_protection, must not push the
+	// pipeline to every SDK client again. source_hashes changes when a step's local
+	// schema files change.
+	deployed := d.HasChanges("name", "step", "source_hashes", "audiences")
+
+	if d.HasChanges("name", "step", "source_hashes") {
+		p.Id = d.Id()
+
+		_, err := client.UpdatePipeline(ctx, &protos.UpdatePipelineRequest{
+			Pipeline: p,
+		})
+		if err != nil {
+			return diag.FromErr(err)
+		}
+
+		_ = d.Set("source_hashes", sourceHashes(d.Get("step").([]interface{}), p.Steps))
 	}
 
-	return diags
+	if d.HasChange("audiences") {
+		if moreDiags := assignPipelineAudiences(ctx, d, client); moreDiags.HasError() {
+			return append(diags, moreDiags...)
+		}
+	}
+
+	diags = append(diags, schemaFetchWarnings(ctx, d, client)...)
+
+	// There is nothing to wait for when no change was sent to the server
+	if !deployed {
+		return diags
+	}
+
+	return append(diags, waitForPipelinePropagation(ctx, d, client)...)
+}
+
+// waitForPipelinePropagation waits for live clients on the audiences the pipeline
+// is assigned to, see waitForPropagation
+func waitForPipelinePropagation(ctx context.Context, d *schema.ResourceData, client *streamdal.Streamdal) diag.Diagnostics {
+	return waitForPropagation(ctx, d, client, func(all *protos.GetAllResponse) []*protos.Audience {
+		return assignedAudiences(all, d.Id())
+	})
 }
 
 func resourcePipelineDelete(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
 	var diags diag.Diagnostics
 
 	s := m.(*streamdal.Streamdal)
-	_, err := s.DeletePipeline(ctx, &protos.DeletePipelineRequest{
+
+	all, err := s.GetAll(ctx)
+	if err != nil {
+		return diag.Errorf("Error deleting pipeline: unable to look up audiences: %s", err)
+	}
+
+	assigned := assignedAudiences(all, d.Id())
+
+	if d.Get("deletion_protection").(bool) {
+		if live := runningAudiences(all, d.Id(), assigned); len(live) > 0 {
+			return diag.Diagnostics{{
+				Severity: diag.Error,
+				Summary:  "Pipeline is protected from deletion",
+				Detail: fmt.Sprintf("The pipeline is running on audiences with connected clients:\n\n  %s\n\n"+
+					"Unassign or pause it on these audiences, or set deletion_protection = false, before deleting it.",
+					strings.Join(audienceStrings(live), "\n  ")),
+			}}
+		}
+	}
+
+	// Detach the pipeline from every audience first, so that no audience is left
+	// referencing a pipeline which no longer exists
+	for _, aud := range assigned {
+		if err := unassignPipeline(ctx, s, aud, d.Id()); err != nil {
+			return diag.Errorf("Error removing pipeline from audience '%s': %s", util.AudienceToStr(aud), err)
+		}
+	}
+
+	_, err = s.DeletePipeline(ctx, &protos.DeletePipelineRequest{
 		PipelineId: d.Id(),
 	})
-
 	if err != nil {
-		return diag.Errorf("Error deleting pipeline: %s", s.Token)
-		//return diag.FromErr(err)
+		return diag.Errorf("Error deleting pipeline: %s", err)
 	}
 
 	return diags
 }
 
 func buildPipeline(d *schema.ResourceData) (*protos.Pipeline, diag.Diagnostics) {
-	var diags diag.Diagnostics
+	pipelineSteps, diags := buildPipelineSteps(d.Get("step").([]interface{}))
+	if diags.HasError() {
+		return nil, diags
+	}
+
 	p := &protos.Pipeline{
 		Name:    d.Get("name").(string),
-		Steps:   []*protos.PipelineStep{},
+		Steps:   pipelineSteps,
 		XPaused: proto.Bool(false),
 	}
 
-	pipelineSteps := d.Get("step").([]interface{})
+	return p, diags
+}
+
+// buildPipelineSteps converts step{} blocks to pipeline steps
+func buildPipelineSteps(pipelineSteps []interface{}) ([]*protos.PipelineStep, diag.Diagnostics) {
+	var diags diag.Diagnostics
+	built := []*protos.PipelineStep{}
 
 	for _, step := range pipelineSteps {
 		stepMap := step.(map[string]interface{})
@@ -460,11 +921,10 @@ func buildPipeline(d *schema.ResourceData) (*protos.Pipeline, diag.Diagnostics)
 			return nil, append(diags, moreDiags...)
 		}
 
-		p.Steps = append(p.Steps, s)
-
+		built = append(built, s)
 	}
 
-	return p, diags
+	return built, diags
 }
 
 func generateCondition(stepMap map[string]interface{}, conditionType string) (*protos.PipelineStepConditions, diag.Diagnostics) {
@@ -605,13 +1065,31 @@ func generateSchemaValidationStep(s *protos.PipelineStep, stepMap map[string]int
 
 	switch t {
 	case steps.SchemaValidationType_SCHEMA_VALIDATION_TYPE_JSONSCHEMA:
-		draft, err := schemaValidationJSONSchemaDraftFromString(config["draft"].(string))
+		jsCfg := jsonSchemaConfig(stepMap)
+		if jsCfg == nil {
+			return diag.Errorf("Error generating schema validation step: json_schema config not found")
+		}
+
+		draft, err := schemaValidationJSONSchemaDraftFromString(jsCfg["draft"].(string))
+		if err != nil {
+			return diag.Errorf("Error generating schema validation step: %s", err)
+		}
+
+		doc, err := jsonSchemaDocument(jsCfg)
 		if err != nil {
 			return diag.Errorf("Error generating schema validation step: %s", err)
 		}
+
+		// Upload the schema in canonical form so that equivalent documents,
+		// ie. from jsonencode() or a heredoc, result in the same step
+		jsonSchema, err := schemas.Normalize(doc)
+		if err != nil {
+			return diag.Errorf("Error generating schema validation step: schema is not valid JSON: %s", err)
+		}
+
 		step.SchemaValidation.Options = &steps.SchemaValidationStep_JsonSchema{
 			JsonSchema: &steps.SchemaValidationJSONSchema{
-				JsonSchema: []byte(config["schema"].(string)),
+				JsonSchema: jsonSchema,
 				Draft:      draft,
 			},
 		}
@@ -681,19 +1159,22 @@ func generateStepDetective(s *protos.PipelineStep, stepMap map[string]interface{
 func generateStepTransform(s *protos.PipelineStep, stepMap map[string]interface{}) diag.Diagnostics {
 
 	stepData := stepMap["transform"].([]interface{})
-	config := stepData[0].(map[string]interface{})
+	config, _ := stepData[0].(map[string]interface{})
 
 	// Loop over all elements under the transform{} block and see if we encounter
 	// a block with the name of a transform type. If we do, we know that's the
 	// transform type we're dealing with.
-	typeStr := getTransformType(config)
-	if typeStr == "" {
+	typeStrs := getTransformTypes(config)
+	if len(typeStrs) == 0 {
 		return diag.Errorf("no transform configuration found. "+
-			"You must specify at least one of the following: %s", strings.Join(getTransformTypes(), ","))
+			"You must specify one of the following: %s", strings.Join(transformBlocks, ","))
+	} else if len(typeStrs) > 1 {
+		return diag.Errorf("multiple transform configurations found: %s. "+
+			"A transform step can only contain one of them", strings.Join(typeStrs, ","))
 	}
 
 	// Convert the above string to a protobuf enum
-	t, err := transformTypeFromString(typeStr)
+	t, err := transformTypeFromBlock(typeStrs[0])
 	if err != nil {
 		return diag.Errorf("Error generating transform step: %s", err)
 	}
@@ -704,94 +1185,76 @@ func generateStepTransform(s *protos.PipelineStep, stepMap map[string]interface{
 		},
 	}
 
+	// The block is guaranteed to exist at this point, but can be empty if
+	// none of its attributes were set, ie. `obfuscate {}`
+	cfg, _ := config[typeStrs[0]].([]interface{})[0].(map[string]interface{})
+	if cfg == nil {
+		cfg = map[string]interface{}{}
+	}
+
 	// Populate transform oneof
 	switch t {
 	case steps.TransformType_TRANSFORM_TYPE_REPLACE_VALUE:
-		replaceData, ok := config["replace_value"].([]interface{})
-		if !ok || len(replaceData) == 0 {
-			return diag.Errorf("Error generating transform step: replace value config not found")
-		}
-
-		replaceCfg := replaceData[0].(map[string]interface{})
-
 		s.GetTransform().Options = &steps.TransformStep_ReplaceValueOptions{
 			ReplaceValueOptions: &steps.TransformReplaceValueOptions{
-				Path:  replaceCfg["path"].(string),
-				Value: replaceCfg["value"].(string),
+				Path:  interfaceToString(cfg["path"]),
+				Value: interfaceToString(cfg["value"]),
 			},
 		}
 	case steps.TransformType_TRANSFORM_TYPE_DELETE_FIELD:
-		deleteData, ok := config["delete_field"].([]interface{})
-		if !ok || len(deleteData) == 0 {
-			return diag.Errorf("Error generating transform step: delete field config not found")
-		}
-
-		deleteCfg := deleteData[0].(map[string]interface{})
-
 		s.GetTransform().Options = &steps.TransformStep_DeleteFieldOptions{
 			DeleteFieldOptions: &steps.TransformDeleteFieldOptions{
-				Paths: interfaceToStrings(deleteCfg["path"]),
+				Paths: interfaceToStrings(cfg["paths"]),
 			},
 		}
 	case steps.TransformType_TRANSFORM_TYPE_OBFUSCATE_VALUE:
-		obfuscateData, ok := config["obfuscate"].([]interface{})
-		if !ok || len(obfuscateData) == 0 {
-			return diag.Errorf("Error generating transform step: obfuscate value config not found")
-		}
-
-		obfuscateCfg := obfuscateData[0].(map[string]interface{})
-
 		s.GetTransform().Options = &steps.TransformStep_ObfuscateOptions{
 			ObfuscateOptions: &steps.TransformObfuscateOptions{
-				Path: obfuscateCfg["path"].(string),
+				Path: interfaceToString(cfg["path"]),
 			},
 		}
 	case steps.TransformType_TRANSFORM_TYPE_MASK_VALUE:
-		maskData, ok := config["mask_value"].([]interface{})
-		if !ok || len(maskData) == 0 {
-			return diag.Errorf("Error generating transform mask step: mask value config not found")
-		}
-
-		maskCfg := maskData[0].(map[string]interface{})
-
 		s.GetTransform().Options = &steps.TransformStep_MaskOptions{
 			MaskOptions: &steps.TransformMaskOptions{
-				Path: maskCfg["path"].(string),
-				Mask: maskCfg["mask"].(string),
+				Path: interfaceToString(cfg["path"]),
+				Mask: interfaceToString(cfg["mask"]),
 			},
 		}
 	case steps.TransformType_TRANSFORM_TYPE_TRUNCATE_VALUE:
-		truncateData, ok := config["truncate"].([]interface{})
-		if !ok || len(truncateData) == 0 {
-			return diag.Errorf("Error generating transform step: truncate value config not found")
-		}
-
-		truncateCfg := truncateData[0].(map[string]interface{})
-
-		tt, err := transformTruncateTypeFromString(truncateCfg["type"].(string))
+		tt, err := transformTruncateTypeFromString(interfaceToString(cfg["type"]))
 		if err != nil {
 			return diag.Errorf("Error generating transform truncate step: %s", err)
 		}
 
+		value, _ := cfg["value"].(int)
+
+		switch tt {
+		case steps.TransformTruncateType_TRANSFORM_TRUNCATE_TYPE_LENGTH:
+			if value < 1 {
+				return diag.Errorf("Error generating transform truncate step: length must be greater than 0, got %d", value)
+			}
+		case steps.TransformTruncateType_TRANSFORM_TRUNCATE_TYPE_PERCENTAGE:
+			if value < 1 || value > 100 {
+				return diag.Errorf("Error generating transform truncate step: percentage must be between 1 and 100, got %d", value)
+			}
+		default:
+			return diag.Errorf("Error generating transform truncate step: unsupported truncate type: %s", tt)
+		}
+
 		s.GetTransform().Options = &steps.TransformStep_TruncateOptions{
 			TruncateOptions: &steps.TransformTruncateOptions{
 				Type:  tt,
-				Path:  truncateCfg["path"].(string),
-				Value: truncateCfg["type"].(int32),
+				Path:  interfaceToString(cfg["path"]),
+				Value: int32(value),
 			},
 		}
 	case steps.TransformType_TRANSFORM_TYPE_EXTRACT:
-		extractData, ok := config["extract"].([]interface{})
-		if !ok || len(extractData) == 0 {
-			return diag.Errorf("Error generating transform step: extract value config not found")
-		}
-
-		extractCfg := extractData[0].(map[string]interface{})
+		flatten, _ := cfg["flatten"].(bool)
 
 		s.GetTransform().Options = &steps.TransformStep_ExtractOptions{
 			ExtractOptions: &steps.TransformExtractOptions{
-				Paths:   interfaceToStrings(extractCfg["paths"]),
-				Flatten: extractCfg["flatten"].(bool),
+				Paths:   interfaceToStrings(cfg["paths"]),
+				Flatten: flatten,
 			},
 		}
 	default:
@@ -800,3 +1263,275 @@ func generateStepTransform(s *protos.PipelineStep, stepMap map[string]interface{
 
 	return diag.Diagnostics{}
 }
+
+// flattenPipelineSteps converts PipelineStep messages returned by the server into the
+// structure used by the "step" blocks. This is the inverse of buildPipeline()
+func flattenPipelineSteps(pipelineSteps []*protos.PipelineStep) []interface{} {
+	out := make([]interface{}, 0, len(pipelineSteps))
+
+	for _, s := range pipelineSteps {
+		stepMap := map[string]interface{}{
+			"name":     s.GetName(),
+			"dynamic":  s.GetDynamic(),
+			"on_true":  flattenCondition(s.GetOnTrue()),
+			"on_false": flattenCondition(s.GetOnFalse()),
+			"on_error": flattenCondition(s.GetOnError()),
+		}
+
+		switch s.Step.(type) {
+		case *protos.PipelineStep_Detective:
+			stepMap["detective"] = flattenStepDetective(s.GetDetective())
+		case *protos.PipelineStep_Transform:
+			stepMap["transform"] = flattenStepTransform(s.GetTransform())
+		case *protos.PipelineStep_HttpRequest:
+			stepMap["http_request"] = flattenStepHttpRequest(s.GetHttpRequest())
+		case *protos.PipelineStep_ValidJson:
+			stepMap["valid_json"] = []interface{}{map[string]interface{}{}}
+		case *protos.PipelineStep_SchemaValidation:
+			stepMap["schema_validation"] = flattenSchemaValidationStep(s.GetSchemaValidation())
+		}
+
+		out = append(out, stepMap)
+	}
+
+	return out
+}
+
+func flattenCondition(cond *protos.PipelineStepConditions) []interface{} {
+	if cond == nil {
+		return []interface{}{}
+	}
+
+	condMap := map[string]interface{}{
+		"abort":        abortConditionToString(cond.GetAbort()),
+		"metadata":     cond.GetMetadata(),
+		"notification": []interface{}{},
+	}
+
+	if n := cond.GetNotification(); n != nil {
+		condMap["notification"] = []interface{}{
+			map[string]interface{}{
+				"notification_config_ids": n.GetNotificationConfigIds(),
+				"payload_type":            notificationPayloadTypeToString(n.GetPayloadType()),
+				"paths":                   n.GetPaths(),
+			},
+		}
+	}
+
+	return []interface{}{condMap}
+}
+
+// jsonSchemaConfig returns the json_schema block of a schema_validation step,
+// or nil if the step doesn't have one
+func jsonSchemaConfig(stepMap map[string]interface{}) map[string]interface{} {
+	svCfg := firstBlock(stepMap["schema_validation"])
+	if svCfg == nil {
+		return nil
+	}
+
+	return firstBlock(svCfg["json_schema"])
+}
+
+// schemaSources are the attributes of a json_schema block which generate the
+// schema from local files instead of specifying it inline
+var schemaSources = []string{"source_file", "protobuf", "avro"}
+
+// schemaSource returns the name of the attribute the schema is generated from,
+// or an empty string if the schema is specified inline
+func schemaSource(jsCfg map[string]interface{}) string {
+	for _, source := range schemaSources {
+		if isSet(jsCfg[source]) {
+			return source
+		}
+	}
+
+	return ""
+}
+
+// isSet determines if a string attribute or block has a value
+func isSet(v interface{}) bool {
+	switch val := v.(type) {
+	case string:
+		return val != ""
+	case []interface{}:
+		return len(val) > 0
+	default:
+		return false
+	}
+}
+
+// schemaSourceUnknown determines if any of the values needed to generate the
+// schema are not known until apply
+func schemaSourceUnknown(jsCfg map[string]interface{}) bool {
+	values := []interface{}{jsCfg["source_file"]}
+
+	for _, block := range []string{"protobuf", "avro"} {
+		if cfg := firstBlock(jsCfg[block]); cfg != nil {
+			values = append(values, cfg["descriptor_set_file"], cfg["message"], cfg["schema_file"])
+		}
+	}
+
+	for _, v := range values {
+		if v == unknownVariableValue {
+			return true
+		}
+	}
+
+	return false
+}
+
+// jsonSchemaDocument returns the schema configured in a json_schema block, either
+// inline or generated from local files
+func jsonSchemaDocument(jsCfg map[string]interface{}) ([]byte, error) {
+	doc, _ := jsCfg["json_schema"].(string)
+
+	set := make([]string, 0)
+	if doc != "" {
+		set = append(set, "json_schema")
+	}
+
+	for _, source := range schemaSources {
+		if isSet(jsCfg[source]) {
+			set = append(set, source)
+		}
+	}
+
+	if len(set) != 1 {
+		return nil, fmt.Errorf("exactly one of json_schema, %s must be set", strings.Join(schemaSources, ", "))
+	}
+
+	switch set[0] {
+	case "source_file":
+		return schemas.Bundle(jsCfg["source_file"].(string))
+	case "protobuf":
+		cfg := firstBlock(jsCfg["protobuf"])
+		if cfg == nil {
+			return nil, errors.New("protobuf config not found")
+		}
+
+		useProtoNames, _ := cfg["use_proto_names"].(bool)
+
+		return schemas.FromProtobuf(interfaceToString(cfg["descriptor_set_file"]), interfaceToString(cfg["message"]), useProtoNames)
+	case "avro":
+		cfg := firstBlock(jsCfg["avro"])
+		if cfg == nil {
+			return nil, errors.New("avro config not found")
+		}
+
+		return schemas.FromAvro(interfaceToString(cfg["schema_file"]))
+	default:
+		return []byte(doc), nil
+	}
+}
+
+// firstBlock returns the configuration of a block with MaxItems: 1, or nil if
+// the block is not set
+func firstBlock(v interface{}) map[string]interface{} {
+	data, ok := v.([]interface{})
+	if !ok || len(data) == 0 || data[0] == nil {
+		return nil
+	}
+
+	cfg, _ := data[0].(map[string]interface{})
+
+	return cfg
+}
+
+func flattenStepDetective(d *steps.DetectiveStep) []interface{} {
+	return []interface{}{
+		map[string]interface{}{
+			"path":   d.GetPath(),
+			"type":   detectiveTypeToString(d.GetType()),
+			"args":   d.GetArgs(),
+			"negate": d.GetNegate(),
+		},
+	}
+}
+
+func flattenStepHttpRequest(h *steps.HttpRequestStep) []interface{} {
+	req := h.GetRequest()
+
+	return []interface{}{
+		map[string]interface{}{
+			"method":  httpMethodToString(req.GetMethod()),
+			"url":     req.GetUrl(),
+			"headers": req.GetHeaders(),
+			"body":    string(req.GetBody()),
+		},
+	}
+}
+
+func flattenSchemaValidationStep(sv *steps.SchemaValidationStep) []interface{} {
+	svMap := map[string]interface{}{
+		"type":        schemaValidationTypeToString(sv.GetType()),
+		"condition":   schemaValidationConditionToString(sv.GetCondition()),
+		"json_schema": []interface{}{},
+	}
+
+	if js := sv.GetJsonSchema(); js != nil {
+		svMap["json_schema"] = []interface{}{
+			map[string]interface{}{
+				"draft":       schemaValidationJSONSchemaDraftToString(js.GetDraft()),
+				"json_schema": string(js.GetJsonSchema()),
+			},
+		}
+	}
+
+	return []interface{}{svMap}
+}
+
+// flattenStepTransform converts a TransformStep into the structure used by the
+// transform{} block. This is the inverse of generateStepTransform()
+func flattenStepTransform(t *steps.TransformStep) []interface{} {
+	transformMap := map[string]interface{}{}
+
+	switch t.GetType() {
+	case steps.TransformType_TRANSFORM_TYPE_REPLACE_VALUE:
+		opts := t.GetReplaceValueOptions()
+		transformMap["replace_value"] = []interface{}{
+			map[string]interface{}{
+				"path":  opts.GetPath(),
+				"value": opts.GetValue(),
+			},
+		}
+	case steps.TransformType_TRANSFORM_TYPE_DELETE_FIELD:
+		transformMap["delete_field"] = []interface{}{
+			map[string]interface{}{
+				"paths": t.GetDeleteFieldOptions().GetPaths(),
+			},
+		}
+	case steps.TransformType_TRANSFORM_TYPE_OBFUSCATE_VALUE:
+		transformMap["obfuscate"] = []interface{}{
+			map[string]interface{}{
+				"path": t.GetObfuscateOptions().GetPath(),
+			},
+		}
+	case steps.TransformType_TRANSFORM_TYPE_MASK_VALUE:
+		opts := t.GetMaskOptions()
+		transformMap["mask_value"] = []interface{}{
+			map[string]interface{}{
+				"path": opts.GetPath(),
+				"mask": opts.GetMask(),
+			},
+		}
+	case steps.TransformType_TRANSFORM_TYPE_TRUNCATE_VALUE:
+		opts := t.GetTruncateOptions()
+		transformMap["truncate"] = []interface{}{
+			map[string]interface{}{
+				"type":  transformTruncateTypeToString(opts.GetType()),
+				"path":  opts.GetPath(),
+				"value": int(opts.GetValue()),
+			},
+		}
+	case steps.TransformType_TRANSFORM_TYPE_EXTRACT:
+		opts := t.GetExtractOptions()
+		transformMap["extract"] = []interface{}{
+			map[string]interface{}{
+				"paths":   opts.GetPaths(),
+				"flatten": opts.GetFlatten(),
+			},
+		}
+	}
+
+	return []interface{}{transformMap}
+}
@@ -0,0 +1,319 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+
+	"github.com/streamdal/streamdal/libs/protos/build/go/protos"
+	"github.com/streamdal/terraform-provider-streamdal/streamdal"
+	"github.com/streamdal/terraform-provider-streamdal/util"
+)
+
+func resourcePipelineAssignment() *schema.Resource {
+	return &schema.Resource{
+		Description: "Assigns a pipeline to every audience matching a set of selectors",
+
+		CreateContext: resourcePipelineAssignmentCreate,
+		ReadContext:   resourcePipelineAssignmentRead,
+		UpdateContext: resourcePipelineAssignmentUpdate,
+		DeleteContext: resourcePipelineAssignmentDelete,
+
+		CustomizeDiff: resourcePipelineAssignmentCustomizeDiff,
+
+		Schema: map[string]*schema.Schema{
+			"pipeline_id": {
+				Description: "ID of the pipeline to assign. Changing it replaces the assignment, unless rollout is set",
+				Type:        schema.TypeString,
+				Required:    true,
+			},
+			"selector": {
+				Description: "Audiences matching any of the selectors are assigned the pipeline",
+				Type:        schema.TypeList,
+				Required:    true,
+				MinItems:    1,
+				Elem:        audienceSelectorSchema(),
+			},
+			"rollout": rolloutSchema(),
+			"audiences": {
+				Description: "Audiences matched by the selectors which the pipeline is assigned to",
+				Type:        schema.TypeSet,
+				Computed:    true,
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+		},
+	}
+}
+
+// resourcePipelineAssignmentCustomizeDiff resolves the selectors against the server's
+// audiences, so that audiences registered since the last apply show up in the plan
+func resourcePipelineAssignmentCustomizeDiff(ctx context.Context, d *schema.ResourceDiff, m interface{}) error {
+	// With a rollout, a new pipeline ID is swapped in for the old one in place
+	if d.Id() != "" && d.HasChange("pipeline_id") && len(d.Get("rollout").([]interface{})) == 0 {
+		if err := d.ForceNew("pipeline_id"); err != nil {
+			return err
+		}
+	}
+
+	if !d.NewValueKnown("selector") {
+		return d.SetNewComputed("audiences")
+	}
+
+	selectors, err := newAudienceSelectors(d.Get("selector").([]interface{}))
+	if err != nil {
+		return err
+	}
+
+	client, ok := m.(*streamdal.Streamdal)
+	if !ok || client == nil {
+		return nil
+	}
+
+	all, err := client.GetAll(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to resolve audience selectors: %s", err)
+	}
+
+	matched := audienceStrings(selectAudiences(selectors, all.GetAudiences()))
+	current := interfaceToStrings(d.Get("audiences").(*schema.Set).List())
+
+	if d.NewValueKnown("pipeline_id") {
+		owner := fmt.Sprintf("the streamdal_pipeline_assignment of pipeline '%s'", d.Get("pipeline_id").(string))
+		if err := claims.assignments(owner, matched); err != nil {
+			return err
+		}
+	}
+
+	if d.Id() != "" && sameStrings(current, matched) {
+		return nil
+	}
+
+	return d.SetNew("audiences", matched)
+}
+
+func resourcePipelineAssignmentCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	d.SetId(d.Get("pipeline_id").(string))
+
+	return applyPipelineAssignment(ctx, d, m.(*streamdal.Streamdal), nil, "")
+}
+
+func resourcePipelineAssignmentUpdate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	old, _ := d.GetChange("audiences")
+	oldPipelineID, _ := d.GetChange("pipeline_id")
+
+	return applyPipelineAssignment(ctx, d, m.(*streamdal.Streamdal), interfaceToStrings(old.(*schema.Set).List()), oldPipelineID.(string))
+}
+
+func resourcePipelineAssignmentRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	client := m.(*streamdal.Streamdal)
+
+	selectors, err := newAudienceSelectors(d.Get("selector").([]interface{}))
+	if err != nil {
+		return diag.Errorf("Error reading pipeline assignment: %s", err)
+	}
+
+	all, err := client.GetAll(ctx)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	pipelineID := d.Get("pipeline_id").(string)
+	assigned := make([]*protos.Audience, 0)
+
+	for _, aud := range selectAudiences(selectors, all.GetAudiences()) {
+		if stringInSlice(pipelineID, audiencePipelineIDs(all, aud)) {
+			assigned = append(assigned, aud)
+		}
+	}
+
+	_ = d.Set("audiences", audienceStrings(assigned))
+
+	return diags
+}
+
+func resourcePipelineAssignmentDelete(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	client := m.(*streamdal.Streamdal)
+	pipelineID := d.Get("pipeline_id").(string)
+
+	remaining := interfaceToStrings(d.Get("audiences").(*schema.Set).List())
+
+	for _, id := range interfaceToStrings(d.Get("audiences").(*schema.Set).List()) {
+		if err := unassignPipeline(ctx, client, util.AudienceFromStr(id), pipelineID); err != nil {
+			_ = d.Set("audiences", remaining)
+			return diag.Errorf("Error removing pipeline from audience '%s': %s", id, err)
+		}
+
+		remaining = removeString(remaining, id)
+	}
+
+	return diags
+}
+
+// applyPipelineAssignment assigns the pipeline to every audience currently matching
+// the selectors, and removes it from previously matched audiences which no longer do.
+// previousID is the pipeline assigned by the last apply, which is replaced in place
+// when it differs from pipeline_id. With a rollout, the canary audiences are changed
+// and watched first.
+func applyPipelineAssignment(ctx context.Context, d *schema.ResourceData, client *streamdal.Streamdal, previous []string, previousID string) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	selectors, err := newAudienceSelectors(d.Get("selector").([]interface{}))
+	if err != nil {
+		return diag.Errorf("Error assigning pipeline: %s", err)
+	}
+
+	all, err := client.GetAll(ctx)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	pipelineID := d.Get("pipeline_id").(string)
+	if previousID == "" {
+		previousID = pipelineID
+	}
+
+	matched := selectAudiences(selectors, all.GetAudiences())
+	matchedIDs := audienceStrings(matched)
+
+	// Keep state accurate if some of the audiences fail to update
+	assigned := append([]string{}, previous...)
+
+	for _, id := range previous {
+		if stringInSlice(id, matchedIDs) {
+			continue
+		}
+
+		if err := unassignPipeline(ctx, client, util.AudienceFromStr(id), previousID); err != nil {
+			_ = d.Set("audiences", assigned)
+			return diag.Errorf("Error removing pipeline from audience '%s': %s", id, err)
+		}
+
+		assigned = removeString(assigned, id)
+	}
+
+	assign := func(ids []string) []string {
+		return swapPipeline(ids, previousID, pipelineID)
+	}
+
+	if cfg := firstBlock(d.Get("rollout")); cfg != nil {
+		rollout, err := newRolloutConfig(cfg)
+		if err != nil {
+			return diag.Errorf("Error assigning pipeline: %s", err)
+		}
+
+		// Audiences which already run the pipeline don't need a canary
+		pending := make([]*protos.Audience, 0)
+		for _, aud := range matched {
+			ids := audiencePipelineIDs(all, aud)
+			if !stringInSlice(pipelineID, ids) || (previousID != pipelineID && stringInSlice(previousID, ids)) {
+				pending = append(pending, aud)
+			}
+		}
+
+		sort.Slice(pending, func(i, j int) bool {
+			return util.AudienceToStr(pending[i]) < util.AudienceToStr(pending[j])
+		})
+
+		if len(pending) > rollout.canaryCount {
+			pending = pending[:rollout.canaryCount]
+		}
+
+		// Rolling back restores the audience's assignment of the previous pipeline,
+		// leaving changes made to its other pipelines in the meantime in place
+		revert := func(aud *protos.Audience, ids []string) []string {
+			if previousID != pipelineID && stringInSlice(previousID, audiencePipelineIDs(all, aud)) {
+				return swapPipeline(ids, pipelineID, previousID)
+			}
+
+			return removeString(ids, pipelineID)
+		}
+
+		if len(pending) > 0 {
+			if err := runCanary(ctx, client, rollout, pending, assign, revert); err != nil {
+				if d.IsNewResource() {
+					d.SetId("")
+				}
+
+				_ = d.Set("audiences", assigned)
+				_ = d.Set("pipeline_id", previousID)
+
+				return diag.Errorf("Error rolling out pipeline '%s': %s", pipelineID, err)
+			}
+		}
+	}
+
+	for _, aud := range matched {
+		if err := updateAudiencePipelines(ctx, client, aud, assign); err != nil {
+			_ = d.Set("audiences", assigned)
+			return diag.Errorf("Error assigning pipeline to audience '%s': %s", util.AudienceToStr(aud), err)
+		}
+
+		if !stringInSlice(util.AudienceToStr(aud), assigned) {
+			assigned = append(assigned, util.AudienceToStr(aud))
+		}
+	}
+
+	d.SetId(pipelineID)
+	_ = d.Set("audiences", assigned)
+
+	return diags
+}
+
+// swapPipeline replaces oldID with newID in an audience's pipeline list, keeping its
+// position. newID is appended if oldID isn't assigned.
+func swapPipeline(ids []string, oldID, newID string) []string {
+	out := make([]string, 0, len(ids)+1)
+
+	for _, id := range ids {
+		switch id {
+		case oldID:
+			if !stringInSlice(newID, out) {
+				out = append(out, newID)
+			}
+		case newID:
+			if !stringInSlice(newID, out) {
+				out = append(out, id)
+			}
+		default:
+			out = append(out, id)
+		}
+	}
+
+	if !stringInSlice(newID, out) {
+		out = append(out, newID)
+	}
+
+	return out
+}
+
+// unassignPipeline removes a single pipeline from an audience's assignments
+func unassignPipeline(ctx context.Context, client *streamdal.Streamdal, aud *protos.Audience, pipelineID string) error {
+	if aud == nil {
+		return fmt.Errorf("invalid audience")
+	}
+
+	return updateAudiencePipelines(ctx, client, aud, func(ids []string) []string {
+		return removeString(ids, pipelineID)
+	})
+}
+
+// audienceStrings returns the sorted audience strings of the given audiences
+func audienceStrings(audiences []*protos.Audience) []string {
+	out := make([]string, 0, len(audiences))
+	for _, aud := range audiences {
+		out = append(out, util.AudienceToStr(aud))
+	}
+
+	sort.Strings(out)
+
+	return out
+}
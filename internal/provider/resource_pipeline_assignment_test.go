@@ -0,0 +1,171 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+
+	"github.com/streamdal/streamdal/libs/protos/build/go/protos"
+	"github.com/streamdal/terraform-provider-streamdal/util"
+)
+
+func testAudience(service, component, operation string, opType protos.OperationType) *protos.Audience {
+	return &protos.Audience{
+		ServiceName:   service,
+		ComponentName: component,
+		OperationType: opType,
+		OperationName: operation,
+	}
+}
+
+func TestSelectAudiences(t *testing.T) {
+	consumer, producer := protos.OperationType_OPERATION_TYPE_CONSUMER, protos.OperationType_OPERATION_TYPE_PRODUCER
+
+	audiences := []*protos.Audience{
+		testAudience("billing-api", "kafka", "orders", consumer),
+		testAudience("billing-worker", "kafka", "invoices", consumer),
+		testAudience("billing-api", "kafka", "orders", producer),
+		testAudience("billing-api", "postgres", "orders", consumer),
+		testAudience("signups", "kafka", "new-users", consumer),
+	}
+
+	cases := map[string]struct {
+		selector map[string]interface{}
+		expected []int
+	}{
+		"glob": {
+			selector: map[string]interface{}{"service_name": "billing-*", "component_name": "kafka", "operation_type": "consumer", "match": "glob"},
+			expected: []int{0, 1},
+		},
+		"defaults match everything": {
+			selector: map[string]interface{}{},
+			expected: []int{0, 1, 2, 3, 4},
+		},
+		"single character wildcard": {
+			selector: map[string]interface{}{"operation_name": "order?", "match": "glob"},
+			expected: []int{0, 2, 3},
+		},
+		"regex is anchored": {
+			selector: map[string]interface{}{"service_name": "billing-(api|worker)", "operation_name": "in", "match": "regex"},
+			expected: []int{},
+		},
+		"regex": {
+			selector: map[string]interface{}{"service_name": "billing-(api|worker)", "operation_name": "in.*", "match": "regex"},
+			expected: []int{1},
+		},
+		"glob quotes regex characters": {
+			selector: map[string]interface{}{"service_name": "billing.api", "match": "glob"},
+			expected: []int{},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			sel, err := newAudienceSelector(tc.selector)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := make([]int, 0)
+			for i, aud := range audiences {
+				if sel.Matches(aud) {
+					got = append(got, i)
+				}
+			}
+
+			if fmt.Sprint(got) != fmt.Sprint(tc.expected) {
+				t.Errorf("expected %v, got %v", tc.expected, got)
+			}
+		})
+	}
+
+	if _, err := newAudienceSelector(map[string]interface{}{"service_name": "billing-(", "match": "regex"}); err == nil {
+		t.Error("expected error for invalid regex")
+	}
+}
+
+func TestResourcePipelineAssignment(t *testing.T) {
+	consumer := protos.OperationType_OPERATION_TYPE_CONSUMER
+
+	api := testAudience("billing-api", "kafka", "orders", consumer)
+	other := testAudience("signups", "kafka", "new-users", consumer)
+
+	fake := &fakeExternalClient{getAll: &protos.GetAllResponse{
+		Audiences: []*protos.Audience{api, other},
+		Configs: map[string]*protos.PipelineConfigs{
+			util.AudienceToStr(api): {Configs: []*protos.PipelineConfig{{Id: "existing"}}},
+		},
+	}}
+	client := newFakeStreamdal(fake)
+	ctx := context.Background()
+
+	r := resourcePipelineAssignment()
+	raw := map[string]interface{}{
+		"pipeline_id": "pii",
+		"selector": []interface{}{
+			map[string]interface{}{"service_name": "billing-*", "component_name": "kafka", "operation_type": "consumer"},
+		},
+	}
+
+	d := schema.TestResourceDataRaw(t, r.Schema, raw)
+	if diags := resourcePipelineAssignmentCreate(ctx, d, client); diags.HasError() {
+		t.Fatalf("unexpected error: %v", diags)
+	}
+
+	all, _ := fake.GetAll(ctx, nil)
+	if got := audiencePipelineIDs(all, api); fmt.Sprint(got) != "[existing pii]" {
+		t.Errorf("unexpected assignments: %v", got)
+	}
+
+	if got := audiencePipelineIDs(all, other); len(got) != 0 {
+		t.Errorf("expected unmatched audience to be left alone, got: %v", got)
+	}
+
+	// An SDK registers a new matching audience
+	worker := testAudience("billing-worker", "kafka", "invoices", consumer)
+	fake.getAll.Audiences = append(fake.getAll.Audiences, worker)
+
+	if diags := resourcePipelineAssignmentRead(ctx, d, client); diags.HasError() {
+		t.Fatalf("unexpected error: %v", diags)
+	}
+
+	state := d.State()
+
+	diff, err := schema.InternalMap(r.Schema).Diff(ctx, state, terraform.NewResourceConfigRaw(raw), r.CustomizeDiff, client, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if diff == nil || diff.Attributes["audiences.#"] == nil || diff.Attributes["audiences.#"].New != "2" {
+		t.Fatalf("expected the new audience to be planned, got: %v", diff)
+	}
+
+	d = r.Data(state)
+	if err := d.Set("audiences", []string{util.AudienceToStr(api), util.AudienceToStr(worker)}); err != nil {
+		t.Fatal(err)
+	}
+
+	if diags := resourcePipelineAssignmentUpdate(ctx, d, client); diags.HasError() {
+		t.Fatalf("unexpected error: %v", diags)
+	}
+
+	all, _ = fake.GetAll(ctx, nil)
+	if got := audiencePipelineIDs(all, worker); fmt.Sprint(got) != "[pii]" {
+		t.Errorf("expected new audience to be assigned, got: %v", got)
+	}
+
+	// Deleting only removes the assigned pipeline
+	if diags := resourcePipelineAssignmentDelete(ctx, d, client); diags.HasError() {
+		t.Fatalf("unexpected error: %v", diags)
+	}
+
+	all, _ = fake.GetAll(ctx, nil)
+	for _, aud := range []*protos.Audience{api, worker} {
+		if ids := audiencePipelineIDs(all, aud); stringInSlice("pii", ids) {
+			t.Errorf("expected pipeline to be removed from '%s', got: %v", util.AudienceToStr(aud), ids)
+		}
+	}
+}
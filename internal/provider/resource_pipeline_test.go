@@ -0,0 +1,587 @@
+package provider
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/streamdal/streamdal/libs/protos/build/go/protos"
+	"github.com/streamdal/streamdal/libs/protos/build/go/protos/steps"
+	"github.com/streamdal/terraform-provider-streamdal/internal/schemas"
+)
+
+func transformPipelineConfig(block string, cfg map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"name": "transform test",
+		"step": []interface{}{
+			map[string]interface{}{
+				"name": "transform step",
+				"transform": []interface{}{
+					map[string]interface{}{
+						block: []interface{}{cfg},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestGenerateStepTransform(t *testing.T) {
+	cases := map[string]struct {
+		block    string
+		cfg      map[string]interface{}
+		expected *steps.TransformStep
+	}{
+		"replace_value": {
+			block: "replace_value",
+			cfg:   map[string]interface{}{"path": "object.field", "value": "\"replaced\""},
+			expected: &steps.TransformStep{
+				Type: steps.TransformType_TRANSFORM_TYPE_REPLACE_VALUE,
+				Options: &steps.TransformStep_ReplaceValueOptions{
+					ReplaceValueOptions: &steps.TransformReplaceValueOptions{Path: "object.field", Value: "\"replaced\""},
+				},
+			},
+		},
+		"delete_field": {
+			block: "delete_field",
+			cfg:   map[string]interface{}{"paths": []interface{}{"object.a", "object.b"}},
+			expected: &steps.TransformStep{
+				Type: steps.TransformType_TRANSFORM_TYPE_DELETE_FIELD,
+				Options: &steps.TransformStep_DeleteFieldOptions{
+					DeleteFieldOptions: &steps.TransformDeleteFieldOptions{Paths: []string{"object.a", "object.b"}},
+				},
+			},
+		},
+		"obfuscate": {
+			block: "obfuscate",
+			cfg:   map[string]interface{}{"path": "object.ssn"},
+			expected: &steps.TransformStep{
+				Type: steps.TransformType_TRANSFORM_TYPE_OBFUSCATE_VALUE,
+				Options: &steps.TransformStep_ObfuscateOptions{
+					ObfuscateOptions: &steps.TransformObfuscateOptions{Path: "object.ssn"},
+				},
+			},
+		},
+		"mask_value": {
+			block: "mask_value",
+			cfg:   map[string]interface{}{"path": "object.email", "mask": "#"},
+			expected: &steps.TransformStep{
+				Type: steps.TransformType_TRANSFORM_TYPE_MASK_VALUE,
+				Options: &steps.TransformStep_MaskOptions{
+					MaskOptions: &steps.TransformMaskOptions{Path: "object.email", Mask: "#"},
+				},
+			},
+		},
+		"mask_value default mask": {
+			block: "mask_value",
+			cfg:   map[string]interface{}{"path": "object.email"},
+			expected: &steps.TransformStep{
+				Type: steps.TransformType_TRANSFORM_TYPE_MASK_VALUE,
+				Options: &steps.TransformStep_MaskOptions{
+					MaskOptions: &steps.TransformMaskOptions{Path: "object.email", Mask: "*"},
+				},
+			},
+		},
+		"truncate length": {
+			block: "truncate",
+			cfg:   map[string]interface{}{"type": "length", "path": "object.body", "value": 128},
+			expected: &steps.TransformStep{
+				Type: steps.TransformType_TRANSFORM_TYPE_TRUNCATE_VALUE,
+				Options: &steps.TransformStep_TruncateOptions{
+					TruncateOptions: &steps.TransformTruncateOptions{
+						Type:  steps.TransformTruncateType_TRANSFORM_TRUNCATE_TYPE_LENGTH,
+						Path:  "object.body",
+						Value: 128,
+					},
+				},
+			},
+		},
+		"truncate percentage": {
+			block: "truncate",
+			cfg:   map[string]interface{}{"type": "percentage", "path": "object.body", "value": 50},
+			expected: &steps.TransformStep{
+				Type: steps.TransformType_TRANSFORM_TYPE_TRUNCATE_VALUE,
+				Options: &steps.TransformStep_TruncateOptions{
+					TruncateOptions: &steps.TransformTruncateOptions{
+						Type:  steps.TransformTruncateType_TRANSFORM_TRUNCATE_TYPE_PERCENTAGE,
+						Path:  "object.body",
+						Value: 50,
+					},
+				},
+			},
+		},
+		"extract": {
+			block: "extract",
+			cfg:   map[string]interface{}{"paths": []interface{}{"object.id", "object.user.name"}, "flatten": true},
+			expected: &steps.TransformStep{
+				Type: steps.TransformType_TRANSFORM_TYPE_EXTRACT,
+				Options: &steps.TransformStep_ExtractOptions{
+					ExtractOptions: &steps.TransformExtractOptions{
+						Paths:   []string{"object.id", "object.user.name"},
+						Flatten: true,
+					},
+				},
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			d := schema.TestResourceDataRaw(t, resourcePipeline().Schema, transformPipelineConfig(tc.block, tc.cfg))
+
+			p, diags := buildPipeline(d)
+			if diags.HasError() {
+				t.Fatalf("unexpected error: %v", diags)
+			}
+
+			if got := p.Steps[0].GetTransform(); !proto.Equal(got, tc.expected) {
+				t.Fatalf("expected %v, got %v", tc.expected, got)
+			}
+
+			// Flatten the generated step back into state and make sure it results in the same step
+			rd := resourcePipeline().TestResourceData()
+			if err := rd.Set("step", flattenPipelineSteps(p.Steps)); err != nil {
+				t.Fatalf("unable to set flattened steps: %s", err)
+			}
+			_ = rd.Set("name", p.Name)
+
+			roundTrip, diags := buildPipeline(rd)
+			if diags.HasError() {
+				t.Fatalf("unexpected error on round trip: %v", diags)
+			}
+
+			if !proto.Equal(roundTrip, p) {
+				t.Fatalf("round trip mismatch: expected %v, got %v", p, roundTrip)
+			}
+		})
+	}
+}
+
+func TestGenerateStepTransform_Errors(t *testing.T) {
+	cases := map[string]map[string]interface{}{
+		"no transform block": {
+			"name": "transform test",
+			"step": []interface{}{
+				map[string]interface{}{
+					"transform": []interface{}{map[string]interface{}{}},
+				},
+			},
+		},
+		"multiple transform blocks": {
+			"name": "transform test",
+			"step": []interface{}{
+				map[string]interface{}{
+					"transform": []interface{}{
+						map[string]interface{}{
+							"obfuscate":  []interface{}{map[string]interface{}{"path": "a"}},
+							"mask_value": []interface{}{map[string]interface{}{"path": "b"}},
+						},
+					},
+				},
+			},
+		},
+		"truncate percentage out of range": transformPipelineConfig("truncate", map[string]interface{}{
+			"type":  "percentage",
+			"path":  "object.body",
+			"value": 150,
+		}),
+	}
+
+	for name, raw := range cases {
+		t.Run(name, func(t *testing.T) {
+			d := schema.TestResourceDataRaw(t, resourcePipeline().Schema, raw)
+
+			if _, diags := buildPipeline(d); !diags.HasError() {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+func TestFlattenPipelineSteps(t *testing.T) {
+	pipelineSteps := []*protos.PipelineStep{
+		{
+			Name: "detect",
+			OnFalse: &protos.PipelineStepConditions{
+				Abort:    protos.AbortCondition_ABORT_CONDITION_ABORT_CURRENT,
+				Metadata: map[string]string{},
+			},
+			Step: &protos.PipelineStep_Detective{
+				Detective: &steps.DetectiveStep{
+					Path:   proto.String("object.email"),
+					Args:   []string{},
+					Negate: proto.Bool(false),
+					Type:   steps.DetectiveType_DETECTIVE_TYPE_PII_EMAIL,
+				},
+			},
+		},
+		{
+			Name:    "mask",
+			Dynamic: true,
+			Step: &protos.PipelineStep_Transform{
+				Transform: &steps.TransformStep{
+					Type: steps.TransformType_TRANSFORM_TYPE_MASK_VALUE,
+					Options: &steps.TransformStep_MaskOptions{
+						MaskOptions: &steps.TransformMaskOptions{Mask: "*"},
+					},
+				},
+			},
+		},
+	}
+
+	d := resourcePipeline().TestResourceData()
+	if err := d.Set("step", flattenPipelineSteps(pipelineSteps)); err != nil {
+		t.Fatalf("unable to set flattened steps: %s", err)
+	}
+
+	p, diags := buildPipeline(d)
+	if diags.HasError() {
+		t.Fatalf("unexpected error: %v", diags)
+	}
+
+	for i := range pipelineSteps {
+		if !proto.Equal(p.Steps[i], pipelineSteps[i]) {
+			t.Fatalf("step %d mismatch: expected %v, got %v", i, pipelineSteps[i], p.Steps[i])
+		}
+	}
+}
+
+func TestGenerateSchemaValidationStep(t *testing.T) {
+	heredoc := `{
+  "type": "object",
+  "required": ["email"]
+}`
+
+	raw := map[string]interface{}{
+		"name": "schema validation test",
+		"step": []interface{}{
+			map[string]interface{}{
+				"schema_validation": []interface{}{
+					map[string]interface{}{
+						"type":      "jsonschema",
+						"condition": "match",
+						"json_schema": []interface{}{
+							map[string]interface{}{
+								"draft":       "draft_07",
+								"json_schema": heredoc,
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	d := schema.TestResourceDataRaw(t, resourcePipeline().Schema, raw)
+
+	p, diags := buildPipeline(d)
+	if diags.HasError() {
+		t.Fatalf("unexpected error: %v", diags)
+	}
+
+	expected := &steps.SchemaValidationStep{
+		Type:      steps.SchemaValidationType_SCHEMA_VALIDATION_TYPE_JSONSCHEMA,
+		Condition: steps.SchemaValidationCondition_SCHEMA_VALIDATION_CONDITION_MATCH,
+		Options: &steps.SchemaValidationStep_JsonSchema{
+			JsonSchema: &steps.SchemaValidationJSONSchema{
+				JsonSchema: []byte(`{"required":["email"],"type":"object"}`),
+				Draft:      steps.JSONSchemaDraft_JSONSCHEMA_DRAFT_07,
+			},
+		},
+	}
+
+	if got := p.Steps[0].GetSchemaValidation(); !proto.Equal(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+
+	// Schemas which don't match the selected draft are rejected at plan time
+	raw["step"].([]interface{})[0].(map[string]interface{})["schema_validation"].([]interface{})[0].(map[string]interface{})["json_schema"] = []interface{}{
+		map[string]interface{}{
+			"draft":       "draft_04",
+			"json_schema": `{"type":"object","required":[]}`,
+		},
+	}
+
+	d = schema.TestResourceDataRaw(t, resourcePipeline().Schema, raw)
+	if diags := validatePipelineSteps(d.Get("step").([]interface{})); !diags.HasError() {
+		t.Fatal("expected an empty required array to be rejected for draft 4")
+	}
+}
+
+func TestGenerateSchemaValidationStep_SourceFile(t *testing.T) {
+	dir := t.TempDir()
+
+	files := map[string]string{
+		"event.json":   `{"type":"object","properties":{"address":{"$ref":"./address.json"}}}`,
+		"address.json": `{"type":"object","required":["street"]}`,
+	}
+
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatalf("unable to write %s: %s", name, err)
+		}
+	}
+
+	raw := map[string]interface{}{
+		"name": "schema validation test",
+		"step": []interface{}{
+			map[string]interface{}{
+				"schema_validation": []interface{}{
+					map[string]interface{}{
+						"type":      "jsonschema",
+						"condition": "match",
+						"json_schema": []interface{}{
+							map[string]interface{}{
+								"draft":       "draft_07",
+								"source_file": filepath.Join(dir, "event.json"),
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	d := schema.TestResourceDataRaw(t, resourcePipeline().Schema, raw)
+
+	p, diags := buildPipeline(d)
+	if diags.HasError() {
+		t.Fatalf("unexpected error: %v", diags)
+	}
+
+	expected := `{"definitions":{"address":{"required":["street"],"type":"object"}},` +
+		`"properties":{"address":{"$ref":"#/definitions/address"}},"type":"object"}`
+
+	if got := string(p.Steps[0].GetSchemaValidation().GetJsonSchema().GetJsonSchema()); got != expected {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+
+	// Reading the step back keeps the configured file instead of the uploaded schema
+	prior := d.Get("step").([]interface{})
+	flattened := preserveSourceFiles(prior, flattenPipelineSteps(p.Steps))
+
+	jsCfg := jsonSchemaConfig(flattened[0].(map[string]interface{}))
+	if jsCfg["source_file"] != filepath.Join(dir, "event.json") || jsCfg["json_schema"] != "" {
+		t.Fatalf("expected source_file to be preserved, got %v", jsCfg)
+	}
+
+	hash, err := schemas.Hash([]byte(expected))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if hashes := sourceHashes(prior, p.Steps); hashes["0"] != hash {
+		t.Fatalf("expected hash %s, got %v", hash, hashes)
+	}
+
+	// Setting both an inline schema and a file is rejected
+	jsonSchemaConfig(raw["step"].([]interface{})[0].(map[string]interface{}))["json_schema"] = `{"type":"object"}`
+
+	d = schema.TestResourceDataRaw(t, resourcePipeline().Schema, raw)
+	if diags := validatePipelineSteps(d.Get("step").([]interface{})); !diags.HasError() {
+		t.Fatal("expected json_schema and source_file together to be rejected")
+	}
+}
+
+func TestGenerateSchemaValidationStep_Avro(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "user.avsc")
+
+	avsc := `{"type":"record","name":"User","fields":[{"name":"email","type":"string"}]}`
+	if err := os.WriteFile(path, []byte(avsc), 0o644); err != nil {
+		t.Fatalf("unable to write schema: %s", err)
+	}
+
+	raw := map[string]interface{}{
+		"name": "schema validation test",
+		"step": []interface{}{
+			map[string]interface{}{
+				"schema_validation": []interface{}{
+					map[string]interface{}{
+						"type":      "jsonschema",
+						"condition": "match",
+						"json_schema": []interface{}{
+							map[string]interface{}{
+								"draft": "draft_07",
+								"avro": []interface{}{
+									map[string]interface{}{"schema_file": path},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	d := schema.TestResourceDataRaw(t, resourcePipeline().Schema, raw)
+
+	p, diags := buildPipeline(d)
+	if diags.HasError() {
+		t.Fatalf("unexpected error: %v", diags)
+	}
+
+	expected := `{"properties":{"email":{"type":"string"}},"required":["email"],"type":"object"}`
+
+	if got := string(p.Steps[0].GetSchemaValidation().GetJsonSchema().GetJsonSchema()); got != expected {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+
+	// The avro block is kept in state instead of the generated schema
+	flattened := preserveSourceFiles(d.Get("step").([]interface{}), flattenPipelineSteps(p.Steps))
+
+	jsCfg := jsonSchemaConfig(flattened[0].(map[string]interface{}))
+	if firstBlock(jsCfg["avro"])["schema_file"] != path || jsCfg["json_schema"] != "" {
+		t.Fatalf("expected avro block to be preserved, got %v", jsCfg)
+	}
+}
+
+func TestDiffNotificationConfigIDs(t *testing.T) {
+	pipelineSteps := []interface{}{
+		map[string]interface{}{
+			"on_true": []interface{}{map[string]interface{}{
+				"notification": []interface{}{map[string]interface{}{
+					"notification_config_ids": []interface{}{"slack", unknownVariableValue},
+				}},
+			}},
+			"on_error": []interface{}{map[string]interface{}{
+				"notification": []interface{}{map[string]interface{}{
+					"notification_config_ids": []interface{}{"pagerduty"},
+				}},
+			}},
+		},
+	}
+
+	fake := &fakeExternalClient{notifications: map[string]*protos.NotificationConfig{"slack": {}}}
+
+	err := diffNotificationConfigIDs(context.Background(), pipelineSteps, newFakeStreamdal(fake))
+	if err == nil || err.Error() != "step.0.on_error.0.notification.0.notification_config_ids.0: notification config 'pagerduty' does not exist" {
+		t.Fatalf("expected missing notification error, got: %v", err)
+	}
+
+	fake.notifications["pagerduty"] = &protos.NotificationConfig{}
+
+	if err := diffNotificationConfigIDs(context.Background(), pipelineSteps, newFakeStreamdal(fake)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The check is skipped when the server can't be reached
+	fake.notificationsErr = status.Error(codes.Unavailable, "connection refused")
+
+	if err := diffNotificationConfigIDs(context.Background(), pipelineSteps, newFakeStreamdal(fake)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestResourcePipelineUpdate(t *testing.T) {
+	r := resourcePipeline()
+
+	raw := transformPipelineConfig("mask_value", map[string]interface{}{"path": "object.card", "mask": "*"})
+
+	d := schema.TestResourceDataRaw(t, r.Schema, raw)
+	d.SetId("pipeline-id")
+
+	apply := func(fake *fakeExternalClient, raw map[string]interface{}, attrs map[string]string) {
+		t.Helper()
+
+		state := d.State()
+		for k, v := range attrs {
+			state.Attributes[k] = v
+		}
+
+		client := newFakeStreamdal(fake)
+
+		diff, err := r.Diff(context.Background(), state, terraform.NewResourceConfigRaw(raw), client)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if diff == nil || diff.Empty() {
+			t.Fatal("expected a diff")
+		}
+
+		if _, diags := r.Apply(context.Background(), state, diff, client); diags.HasError() {
+			t.Fatalf("unexpected error: %v", diags)
+		}
+	}
+
+	newFake := func() *fakeExternalClient {
+		return &fakeExternalClient{pipelines: []*protos.Pipeline{{Id: "pipeline-id"}}}
+	}
+
+	// A changed compatibility report alone doesn't redeploy the pipeline
+	fake := newFake()
+	apply(fake, raw, map[string]string{
+		"schema_compatibility_report.#": "1",
+		"schema_compatibility_report.0": "audience 'a', step 'b': no longer incompatible",
+	})
+
+	if len(fake.updatedPipelines) != 0 {
+		t.Errorf("expected no pipeline update for a report change, got %v", fake.updatedPipelines)
+	}
+
+	// Nor do changed validation warnings, ie. once a path was set elsewhere
+	fake = newFake()
+	apply(fake, raw, map[string]string{
+		"validation_warnings.#": "1",
+		"validation_warnings.0": "step.0.transform.0.mask_value.0.path: Transform 'mask_value' has no path set",
+	})
+
+	if len(fake.updatedPipelines) != 0 {
+		t.Errorf("expected no pipeline update for a validation warnings change, got %v", fake.updatedPipelines)
+	}
+
+	// A changed step is deployed to the existing pipeline
+	fake = newFake()
+	apply(fake, transformPipelineConfig("mask_value", map[string]interface{}{"path": "object.ssn", "mask": "*"}), nil)
+
+	if len(fake.updatedPipelines) != 1 || fake.updatedPipelines[0] != "pipeline-id" {
+		t.Errorf("expected the pipeline to be updated by ID, got %v", fake.updatedPipelines)
+	}
+}
+
+func TestResourcePipelineUpdate_LocalSettings(t *testing.T) {
+	r := resourcePipeline()
+
+	raw := transformPipelineConfig("mask_value", map[string]interface{}{"path": "object.card", "mask": "*"})
+
+	d := schema.TestResourceDataRaw(t, r.Schema, raw)
+	d.SetId("pipeline-id")
+	state := d.State()
+
+	raw["deletion_protection"] = true
+	raw["wait_for_propagation"] = []interface{}{map[string]interface{}{"timeout": "1m", "on_timeout": "fail"}}
+
+	fake := &fakeExternalClient{pipelines: []*protos.Pipeline{{Id: "pipeline-id"}}}
+	client := newFakeStreamdal(fake)
+
+	diff, err := r.Diff(context.Background(), state, terraform.NewResourceConfigRaw(raw), client)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The fake's GetAllStream blocks until the context is done, so this also
+	// fails if the update waits for propagation
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	newState, diags := r.Apply(ctx, state, diff, client)
+	if diags.HasError() {
+		t.Fatalf("unexpected error: %v", diags)
+	}
+
+	if newState.Attributes["deletion_protection"] != "true" {
+		t.Errorf("expected deletion_protection to be set in state, got: %v", newState.Attributes)
+	}
+
+	if len(fake.updatedPipelines) != 0 || len(fake.setPipelines) != 0 {
+		t.Errorf("expected no writes to the server, got updates %v and assignments %v", fake.updatedPipelines, fake.setPipelines)
+	}
+}
@@ -0,0 +1,235 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+
+	"github.com/streamdal/streamdal/libs/protos/build/go/protos"
+	"github.com/streamdal/terraform-provider-streamdal/streamdal"
+	"github.com/streamdal/terraform-provider-streamdal/util"
+)
+
+const (
+	// rolloutBaselineWindow is the longest time rates are sampled for before the
+	// canary audiences are changed
+	rolloutBaselineWindow = 30 * time.Second
+
+	// rolloutMetricsTimeout is how long to wait for a metrics snapshot
+	rolloutMetricsTimeout = 10 * time.Second
+)
+
+var (
+	// defaultErrorMetrics and defaultProcessedMetrics are used when error_metrics
+	// or processed_metrics are not set. The SDK ignores defaults on lists, so they
+	// are applied by newRolloutConfig.
+	defaultErrorMetrics     = []string{"counter_consume_errors", "counter_produce_errors"}
+	defaultProcessedMetrics = []string{"counter_consume_processed", "counter_produce_processed"}
+)
+
+// rolloutSchema returns the schema of the rollout block of a pipeline assignment
+func rolloutSchema() *schema.Schema {
+	return &schema.Schema{
+		Description: "Assign the pipeline to a few canary audiences first, and only promote it to the other audiences " +
+			"if their error and throughput signals stay within the thresholds during the bake time",
+		Type:     schema.TypeList,
+		Optional: true,
+		MaxItems: 1,
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"canary_count": {
+					Description:  "Number of audiences to assign the pipeline to first",
+					Type:         schema.TypeInt,
+					Optional:     true,
+					Default:      1,
+					ValidateFunc: validation.IntAtLeast(1),
+				},
+				"bake_time": {
+					Description:  "How long to watch the canary audiences before promoting, as a duration such as `5m`",
+					Type:         schema.TypeString,
+					Optional:     true,
+					Default:      "5m",
+					ValidateFunc: validateDuration(),
+				},
+				"max_error_ratio": {
+					Description:  "Highest ratio of errors to processed payloads on a canary audience during the bake time",
+					Type:         schema.TypeFloat,
+					Optional:     true,
+					Default:      0.01,
+					ValidateFunc: validation.FloatAtLeast(0),
+				},
+				"max_rate_drop": {
+					Description:  "Highest drop of the processed rate of a canary audience, compared to before the change, between 0 and 1",
+					Type:         schema.TypeFloat,
+					Optional:     true,
+					Default:      0.5,
+					ValidateFunc: validation.FloatBetween(0, 1),
+				},
+				"error_metrics": {
+					Description: "Names of the counters of errors. Defaults to the consume and produce error counters",
+					Type:        schema.TypeList,
+					Optional:    true,
+					Elem: &schema.Schema{
+						Type: schema.TypeString,
+					},
+				},
+				"processed_metrics": {
+					Description: "Names of the counters of processed payloads. Defaults to the consume and produce processed counters",
+					Type:        schema.TypeList,
+					Optional:    true,
+					Elem: &schema.Schema{
+						Type: schema.TypeString,
+					},
+				},
+			},
+		},
+	}
+}
+
+// rolloutConfig is the parsed rollout block
+type rolloutConfig struct {
+	canaryCount      int
+	bakeTime         time.Duration
+	maxErrorRatio    float64
+	maxRateDrop      float64
+	errorMetrics     []string
+	processedMetrics []string
+}
+
+func newRolloutConfig(cfg map[string]interface{}) (*rolloutConfig, error) {
+	bakeTime, err := time.ParseDuration(cfg["bake_time"].(string))
+	if err != nil {
+		return nil, fmt.Errorf("invalid bake_time: %s", err)
+	}
+
+	errorMetrics := interfaceToStrings(cfg["error_metrics"])
+	if len(errorMetrics) == 0 {
+		errorMetrics = defaultErrorMetrics
+	}
+
+	processedMetrics := interfaceToStrings(cfg["processed_metrics"])
+	if len(processedMetrics) == 0 {
+		processedMetrics = defaultProcessedMetrics
+	}
+
+	return &rolloutConfig{
+		canaryCount:      cfg["canary_count"].(int),
+		bakeTime:         bakeTime,
+		maxErrorRatio:    cfg["max_error_ratio"].(float64),
+		maxRateDrop:      cfg["max_rate_drop"].(float64),
+		errorMetrics:     errorMetrics,
+		processedMetrics: processedMetrics,
+	}, nil
+}
+
+// runCanary applies assign to the canary audiences and watches them for the bake
+// time. If the signals can't be read or breach the thresholds, revert is applied to
+// the audiences which were changed and an error is returned.
+func runCanary(ctx context.Context, client *streamdal.Streamdal, cfg *rolloutConfig, canary []*protos.Audience,
+	assign func([]string) []string, revert func(*protos.Audience, []string) []string) error {
+	baselineWindow := cfg.bakeTime
+	if baselineWindow > rolloutBaselineWindow {
+		baselineWindow = rolloutBaselineWindow
+	}
+
+	baseline, err := sampleAudienceRates(ctx, client, baselineWindow)
+	if err != nil {
+		return fmt.Errorf("unable to sample rates before the rollout: %s", err)
+	}
+
+	before, err := metricsSnapshot(ctx, client, rolloutMetricsTimeout)
+	if err != nil {
+		return fmt.Errorf("unable to read metrics before the rollout: %s", err)
+	}
+
+	applied := make([]*protos.Audience, 0, len(canary))
+
+	rollback := func(cause error) error {
+		for _, aud := range applied {
+			err := updateAudiencePipelines(ctx, client, aud, func(ids []string) []string {
+				return revert(aud, ids)
+			})
+			if err != nil {
+				return fmt.Errorf("%s; rolling back audience '%s' failed: %s", cause, util.AudienceToStr(aud), err)
+			}
+		}
+
+		return fmt.Errorf("%s; the canary audiences were rolled back", cause)
+	}
+
+	for _, aud := range canary {
+		if err := updateAudiencePipelines(ctx, client, aud, assign); err != nil {
+			return rollback(fmt.Errorf("unable to assign pipeline to canary audience '%s': %s", util.AudienceToStr(aud), err))
+		}
+
+		applied = append(applied, aud)
+	}
+
+	bake, err := sampleAudienceRates(ctx, client, cfg.bakeTime)
+	if err != nil {
+		return rollback(fmt.Errorf("unable to sample rates during the bake time: %s", err))
+	}
+
+	after, err := metricsSnapshot(ctx, client, rolloutMetricsTimeout)
+	if err != nil {
+		return rollback(fmt.Errorf("unable to read metrics after the bake time: %s", err))
+	}
+
+	breaches := cfg.evaluate(canary, averageAudienceRates(baseline), averageAudienceRates(bake), before, after)
+	if len(breaches) > 0 {
+		return rollback(fmt.Errorf("canary audiences breached the rollout thresholds:\n%s", strings.Join(breaches, "\n")))
+	}
+
+	return nil
+}
+
+// evaluate returns a message for each threshold a canary audience breached
+func (cfg *rolloutConfig) evaluate(canary []*protos.Audience, baseline, bake map[string]*protos.AudienceRate,
+	before, after map[string]*protos.Metric) []string {
+	breaches := make([]string, 0)
+
+	for _, aud := range canary {
+		audStr := util.AudienceToStr(aud)
+
+		errors := metricDelta(before, after, audStr, cfg.errorMetrics)
+		processed := metricDelta(before, after, audStr, cfg.processedMetrics)
+
+		if errors > 0 && (processed <= 0 || errors/processed > cfg.maxErrorRatio) {
+			breaches = append(breaches, fmt.Sprintf("audience '%s': %g errors for %g processed payloads, more than "+
+				"the max_error_ratio of %g", audStr, errors, processed, cfg.maxErrorRatio))
+		}
+
+		if base := baseline[audStr].GetProcessed(); base > 0 {
+			current := bake[audStr].GetProcessed()
+
+			if drop := 1 - current/base; drop > cfg.maxRateDrop {
+				breaches = append(breaches, fmt.Sprintf("audience '%s': processed rate dropped from %g/s to %g/s, "+
+					"more than the max_rate_drop of %g", audStr, base, current, cfg.maxRateDrop))
+			}
+		}
+	}
+
+	return breaches
+}
+
+// metricDelta returns how much the named counters of an audience grew between two
+// metrics snapshots
+func metricDelta(before, after map[string]*protos.Metric, audStr string, names []string) float64 {
+	sum := func(snapshot map[string]*protos.Metric) float64 {
+		total := 0.0
+
+		for _, metric := range snapshot {
+			if stringInSlice(metric.GetName(), names) && metricAudience(metric) == audStr {
+				total += metric.GetValue()
+			}
+		}
+
+		return total
+	}
+
+	return sum(after) - sum(before)
+}
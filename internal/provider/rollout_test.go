@@ -0,0 +1,282 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+
+	"github.com/streamdal/streamdal/libs/protos/build/go/protos"
+	"github.com/streamdal/terraform-provider-streamdal/util"
+)
+
+func TestSwapPipeline(t *testing.T) {
+	cases := []struct {
+		ids      []string
+		expected string
+	}{
+		{ids: []string{"a", "v1", "b"}, expected: "[a v2 b]"},
+		{ids: []string{"a", "b"}, expected: "[a b v2]"},
+		{ids: []string{"v2", "v1"}, expected: "[v2]"},
+		{ids: []string{}, expected: "[v2]"},
+	}
+
+	for _, tc := range cases {
+		if got := fmt.Sprint(swapPipeline(tc.ids, "v1", "v2")); got != tc.expected {
+			t.Errorf("%v: expected %s, got %s", tc.ids, tc.expected, got)
+		}
+	}
+}
+
+func TestNewRolloutConfig(t *testing.T) {
+	d := schema.TestResourceDataRaw(t, resourcePipelineAssignment().Schema, map[string]interface{}{
+		"pipeline_id": "v2",
+		"rollout":     []interface{}{map[string]interface{}{"canary_count": 1}},
+	})
+
+	cfg, err := newRolloutConfig(firstBlock(d.Get("rollout")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := fmt.Sprint(cfg.errorMetrics); got != "[counter_consume_errors counter_produce_errors]" {
+		t.Errorf("expected default error metrics, got: %s", got)
+	}
+
+	if got := fmt.Sprint(cfg.processedMetrics); got != "[counter_consume_processed counter_produce_processed]" {
+		t.Errorf("expected default processed metrics, got: %s", got)
+	}
+
+	// The defaults must be enough for the error ratio to be checked
+	aud := testAudience("billing-svc", "kafka", "orders", protos.OperationType_OPERATION_TYPE_CONSUMER)
+
+	before := map[string]*protos.Metric{
+		"errors":    {Name: "counter_consume_errors", Audience: aud, Value: 0},
+		"processed": {Name: "counter_consume_processed", Audience: aud, Value: 0},
+	}
+	after := map[string]*protos.Metric{
+		"errors":    {Name: "counter_consume_errors", Audience: aud, Value: 50},
+		"processed": {Name: "counter_consume_processed", Audience: aud, Value: 100},
+	}
+
+	breaches := cfg.evaluate([]*protos.Audience{aud}, nil, nil, before, after)
+	if len(breaches) != 1 || !strings.Contains(breaches[0], "max_error_ratio") {
+		t.Errorf("expected a max_error_ratio breach with the default metrics, got: %v", breaches)
+	}
+
+	d = schema.TestResourceDataRaw(t, resourcePipelineAssignment().Schema, map[string]interface{}{
+		"pipeline_id": "v2",
+		"rollout": []interface{}{map[string]interface{}{
+			"error_metrics": []interface{}{"custom_errors"},
+		}},
+	})
+
+	cfg, err = newRolloutConfig(firstBlock(d.Get("rollout")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := fmt.Sprint(cfg.errorMetrics); got != "[custom_errors]" {
+		t.Errorf("expected configured error metrics, got: %s", got)
+	}
+}
+
+func TestRolloutEvaluate(t *testing.T) {
+	aud := testAudience("billing-svc", "kafka", "orders", protos.OperationType_OPERATION_TYPE_CONSUMER)
+	audStr := util.AudienceToStr(aud)
+
+	cfg := &rolloutConfig{
+		maxErrorRatio:    0.01,
+		maxRateDrop:      0.5,
+		errorMetrics:     []string{"counter_consume_errors"},
+		processedMetrics: []string{"counter_consume_processed"},
+	}
+
+	counters := func(errors, processed float64) map[string]*protos.Metric {
+		return map[string]*protos.Metric{
+			"errors":    {Name: "counter_consume_errors", Audience: aud, Value: errors},
+			"processed": {Name: "counter_consume_processed", Audience: aud, Value: processed},
+			"other":     {Name: "counter_consume_errors", Value: 1000},
+		}
+	}
+
+	rate := func(processed float64) map[string]*protos.AudienceRate {
+		return map[string]*protos.AudienceRate{audStr: {Processed: processed}}
+	}
+
+	cases := map[string]struct {
+		after    map[string]*protos.Metric
+		bake     map[string]*protos.AudienceRate
+		expected string
+	}{
+		"healthy":      {after: counters(15, 2000), bake: rate(80)},
+		"errors":       {after: counters(40, 2000), bake: rate(80), expected: "max_error_ratio"},
+		"only errors":  {after: counters(11, 1000), bake: rate(80), expected: "max_error_ratio"},
+		"rate drop":    {after: counters(10, 1100), bake: rate(20), expected: "max_rate_drop"},
+		"traffic gone": {after: counters(10, 1000), bake: rate(0), expected: "max_rate_drop"},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			breaches := cfg.evaluate([]*protos.Audience{aud}, rate(100), tc.bake, counters(10, 1000), tc.after)
+
+			if tc.expected == "" {
+				if len(breaches) != 0 {
+					t.Fatalf("unexpected breaches: %v", breaches)
+				}
+
+				return
+			}
+
+			if len(breaches) != 1 || !strings.Contains(breaches[0], tc.expected) {
+				t.Fatalf("expected a single %s breach, got: %v", tc.expected, breaches)
+			}
+		})
+	}
+}
+
+func TestPipelineAssignmentRollout(t *testing.T) {
+	canary := testAudience("billing-api", "kafka", "invoices", protos.OperationType_OPERATION_TYPE_CONSUMER)
+	rest := testAudience("billing-api", "kafka", "orders", protos.OperationType_OPERATION_TYPE_CONSUMER)
+
+	server := func() *protos.GetAllResponse {
+		return &protos.GetAllResponse{
+			Audiences: []*protos.Audience{rest, canary},
+			Configs: map[string]*protos.PipelineConfigs{
+				util.AudienceToStr(canary): {Configs: []*protos.PipelineConfig{{Id: "first"}, {Id: "v1"}, {Id: "last"}}},
+				util.AudienceToStr(rest):   {Configs: []*protos.PipelineConfig{{Id: "v1"}}},
+			},
+		}
+	}
+
+	rates := func(processed float64) []*protos.GetAudienceRatesResponse {
+		return []*protos.GetAudienceRatesResponse{{Rates: map[string]*protos.AudienceRate{
+			util.AudienceToStr(canary): {Processed: processed},
+			util.AudienceToStr(rest):   {Processed: 100},
+		}}}
+	}
+
+	// Swaps pipeline v1 for v2 on the audiences matched by the selector
+	updateData := func(t *testing.T, client interface{}) *schema.ResourceData {
+		r := resourcePipelineAssignment()
+
+		state := &terraform.InstanceState{
+			ID: "v1",
+			Attributes: map[string]string{
+				"id":                        "v1",
+				"pipeline_id":               "v1",
+				"selector.#":                "1",
+				"selector.0.service_name":   "billing-*",
+				"selector.0.component_name": "*",
+				"selector.0.operation_name": "*",
+				"selector.0.match":          "glob",
+				"audiences.#":               "2",
+				"audiences.1":               util.AudienceToStr(canary),
+				"audiences.2":               util.AudienceToStr(rest),
+			},
+		}
+
+		cfg := terraform.NewResourceConfigRaw(map[string]interface{}{
+			"pipeline_id": "v2",
+			"selector":    []interface{}{map[string]interface{}{"service_name": "billing-*"}},
+			"rollout":     []interface{}{map[string]interface{}{"bake_time": "20ms"}},
+		})
+
+		diff, err := schema.InternalMap(r.Schema).Diff(context.Background(), state, cfg, r.CustomizeDiff, client, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if diff.RequiresNew() {
+			t.Fatal("expected pipeline_id to be updated in place with a rollout")
+		}
+
+		d, err := schema.InternalMap(r.Schema).Data(state, diff)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return d
+	}
+
+	t.Run("promotes", func(t *testing.T) {
+		fake := &fakeExternalClient{getAll: server(), rates: rates(100), metrics: []*protos.GetMetricsResponse{{}}}
+		client := newFakeStreamdal(fake)
+
+		d := updateData(t, client)
+		if diags := resourcePipelineAssignmentUpdate(context.Background(), d, client); diags.HasError() {
+			t.Fatalf("unexpected error: %v", diags)
+		}
+
+		all, _ := fake.GetAll(context.Background(), nil)
+
+		if got := fmt.Sprint(audiencePipelineIDs(all, canary)); got != "[first v2 last]" {
+			t.Errorf("expected v2 in place of v1 on the canary, got: %s", got)
+		}
+
+		if got := fmt.Sprint(audiencePipelineIDs(all, rest)); got != "[v2]" {
+			t.Errorf("expected v2 to be promoted, got: %s", got)
+		}
+
+		if d.Id() != "v2" {
+			t.Errorf("expected ID to follow the pipeline, got: %s", d.Id())
+		}
+	})
+
+	t.Run("rolls back", func(t *testing.T) {
+		fake := &fakeExternalClient{
+			getAll:           server(),
+			rates:            rates(100),
+			ratesAfterChange: rates(10),
+			metrics:          []*protos.GetMetricsResponse{{}},
+		}
+		client := newFakeStreamdal(fake)
+
+		d := updateData(t, client)
+
+		diags := resourcePipelineAssignmentUpdate(context.Background(), d, client)
+		if !diags.HasError() || !strings.Contains(diags[0].Summary, "max_rate_drop") {
+			t.Fatalf("expected rollout to fail on the rate drop, got: %v", diags)
+		}
+
+		all, _ := fake.GetAll(context.Background(), nil)
+
+		if got := fmt.Sprint(audiencePipelineIDs(all, canary)); got != "[first v1 last]" {
+			t.Errorf("expected canary to be rolled back, got: %s", got)
+		}
+
+		if got := fmt.Sprint(audiencePipelineIDs(all, rest)); got != "[v1]" {
+			t.Errorf("expected other audiences to be left alone, got: %s", got)
+		}
+
+		if got := d.Get("pipeline_id").(string); got != "v1" {
+			t.Errorf("expected pipeline_id to stay at v1 in state, got: %s", got)
+		}
+	})
+}
+
+func TestPipelineAssignmentForceNew(t *testing.T) {
+	r := resourcePipelineAssignment()
+
+	state := &terraform.InstanceState{
+		ID:         "v1",
+		Attributes: map[string]string{"id": "v1", "pipeline_id": "v1", "selector.#": "1", "selector.0.service_name": "*"},
+	}
+
+	cfg := terraform.NewResourceConfigRaw(map[string]interface{}{
+		"pipeline_id": "v2",
+		"selector":    []interface{}{map[string]interface{}{"service_name": "*"}},
+	})
+
+	diff, err := schema.InternalMap(r.Schema).Diff(context.Background(), state, cfg, r.CustomizeDiff, nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !diff.RequiresNew() {
+		t.Error("expected changing pipeline_id without a rollout to replace the assignment")
+	}
+}
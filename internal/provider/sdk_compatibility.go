@@ -0,0 +1,222 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/hashicorp/go-version"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+
+	"github.com/streamdal/streamdal/libs/protos/build/go/protos"
+	"github.com/streamdal/terraform-provider-streamdal/streamdal"
+	"github.com/streamdal/terraform-provider-streamdal/util"
+)
+
+// sdkStepSupport is the built-in minimum version of each SDK library able to run a
+// step type. Step types which aren't listed are supported by every version, and
+// libraries which aren't listed for a step type are not checked. Pipelines add to
+// and override it with sdk_minimum_version blocks.
+//
+// It is empty until the minimums are confirmed against the SDK releases, see
+// https://github.com/streamdal/streamdal/releases. Each entry must be the first
+// release of the SDK whose Wasm runtime handles the step type, with the release
+// noted next to it.
+var sdkStepSupport = map[string]map[string]string{}
+
+// sdkMinimumVersionSchema returns the schema of an sdk_minimum_version block
+func sdkMinimumVersionSchema() *schema.Resource {
+	return &schema.Resource{
+		Schema: map[string]*schema.Schema{
+			"step_type": {
+				Description:  "Step type, ie. `kv` or `schema_validation`",
+				Type:         schema.TypeString,
+				Required:     true,
+				ValidateFunc: validation.StringInSlice(stepTypes, false),
+			},
+			"library": {
+				Description: "SDK library name as reported by live clients, ie. `go-sdk`",
+				Type:        schema.TypeString,
+				Required:    true,
+			},
+			"version": {
+				Description:  "First version of the library able to run the step type",
+				Type:         schema.TypeString,
+				Required:     true,
+				ValidateFunc: validateVersion(),
+			},
+		},
+	}
+}
+
+func validateVersion() schema.SchemaValidateFunc {
+	return func(i interface{}, k string) ([]string, []error) {
+		v, ok := i.(string)
+		if !ok {
+			return nil, []error{fmt.Errorf("expected type of %s to be string", k)}
+		}
+
+		if _, err := version.NewVersion(v); err != nil {
+			return nil, []error{fmt.Errorf("%s: expected a version such as '0.1.0', got '%s'", k, v)}
+		}
+
+		return nil, nil
+	}
+}
+
+// sdkMinimumVersions merges the sdk_minimum_version blocks of a pipeline into the
+// built-in table
+func sdkMinimumVersions(blocks []interface{}) map[string]map[string]string {
+	minimums := make(map[string]map[string]string)
+
+	for stepType, libs := range sdkStepSupport {
+		minimums[stepType] = make(map[string]string)
+		for lib, v := range libs {
+			minimums[stepType][lib] = v
+		}
+	}
+
+	for _, block := range blocks {
+		cfg, ok := block.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		stepType, lib, v := cfg["step_type"].(string), cfg["library"].(string), cfg["version"].(string)
+		if _, err := version.NewVersion(v); err != nil {
+			continue
+		}
+
+		if minimums[stepType] == nil {
+			minimums[stepType] = make(map[string]string)
+		}
+
+		minimums[stepType][lib] = v
+	}
+
+	return minimums
+}
+
+// diffSDKCompatibility checks the step types of a pipeline against the SDK versions
+// of the live clients on the audiences the pipeline is assigned to. In strict mode
+// unsupported steps fail the plan, otherwise they are reported in
+// sdk_compatibility_report. The check runs on every plan, so that clients which
+// connect later are reported too. In warn mode it is skipped when the server
+// can't be reached.
+func diffSDKCompatibility(ctx context.Context, d *schema.ResourceDiff, m interface{}) error {
+	client, ok := m.(*streamdal.Streamdal)
+	if !ok || client == nil {
+		return nil
+	}
+
+	mode := d.Get("sdk_compatibility").(string)
+
+	// With mode off, any report left over from a previous mode is cleared
+	report := make([]interface{}, 0)
+
+	minimums := sdkMinimumVersions(d.Get("sdk_minimum_version").([]interface{}))
+
+	// Without minimum versions there is nothing to check
+	if mode != compatibilityOff && len(minimums) > 0 {
+		audiences, err := pipelineAudiences(ctx, d, client)
+		if err != nil {
+			if mode == compatibilityWarn && serverUnreachable(err) {
+				return nil
+			}
+
+			return fmt.Errorf("unable to determine audiences for SDK compatibility check: %s", err)
+		}
+
+		all, err := client.GetAll(ctx)
+		if err != nil {
+			if mode == compatibilityWarn && serverUnreachable(err) {
+				return nil
+			}
+
+			return fmt.Errorf("unable to fetch live clients for SDK compatibility check: %s", err)
+		}
+
+		msgs := sdkCompatibilityReport(all, audiences, d.Get("step").([]interface{}), minimums)
+
+		if mode == compatibilityStrict && len(msgs) > 0 {
+			return fmt.Errorf("pipeline steps are not supported by connected SDK clients:\n%s", strings.Join(msgs, "\n"))
+		}
+
+		for _, msg := range msgs {
+			report = append(report, msg)
+		}
+	}
+
+	if reflect.DeepEqual(report, d.Get("sdk_compatibility_report").([]interface{})) {
+		return nil
+	}
+
+	return d.SetNew("sdk_compatibility_report", report)
+}
+
+// sdkCompatibilityReport returns a message for each step which live clients on the
+// given audiences run a library version too old for, according to minimums
+func sdkCompatibilityReport(all *protos.GetAllResponse, audiences []*protos.Audience, pipelineSteps []interface{},
+	minimums map[string]map[string]string) []string {
+	report := make([]string, 0)
+
+	for i, step := range pipelineSteps {
+		stepMap, _ := step.(map[string]interface{})
+
+		stepType := getStepType(stepMap)
+
+		for _, aud := range audiences {
+			// Each library version is reported once per audience
+			seen := make(map[string]bool)
+
+			for _, live := range all.GetLive() {
+				if !util.AudienceInList(aud, live.GetAudiences()) {
+					continue
+				}
+
+				lib, libVersion := live.GetClient().GetLibraryName(), live.GetClient().GetLibraryVersion()
+
+				minimum, ok := minimums[stepType][lib]
+				if !ok || supportsVersion(libVersion, minimum) || seen[lib+"@"+libVersion] {
+					continue
+				}
+
+				seen[lib+"@"+libVersion] = true
+
+				report = append(report, fmt.Sprintf("audience '%s', step %d '%s': %s steps need %s %s or later, "+
+					"but clients run %s %s", util.AudienceToStr(aud), i, stepMap["name"], stepType, lib, minimum, lib, libVersion))
+			}
+		}
+	}
+
+	return report
+}
+
+// supportsVersion returns true if v is at least minimum. Versions which can't be
+// parsed, ie. development builds, are assumed to be supported.
+func supportsVersion(v, minimum string) bool {
+	current, err := version.NewVersion(v)
+	if err != nil {
+		return true
+	}
+
+	return current.GreaterThanOrEqual(version.Must(version.NewVersion(minimum)))
+}
+
+// sdkCompatibilityWarnings turns the SDK compatibility report into warnings on apply
+func sdkCompatibilityWarnings(d *schema.ResourceData) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	for _, msg := range interfaceToStrings(d.Get("sdk_compatibility_report")) {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Warning,
+			Summary:  "Pipeline step is not supported by connected SDK clients",
+			Detail:   msg,
+		})
+	}
+
+	return diags
+}
@@ -0,0 +1,161 @@
+package provider
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/streamdal/streamdal/libs/protos/build/go/protos"
+)
+
+func TestSDKCompatibilityReport(t *testing.T) {
+	aud := testAudience("billing-svc", "kafka", "orders", protos.OperationType_OPERATION_TYPE_CONSUMER)
+	other := testAudience("signups", "kafka", "new-users", protos.OperationType_OPERATION_TYPE_CONSUMER)
+
+	client := func(a *protos.Audience, lib, v string) *protos.LiveInfo {
+		return &protos.LiveInfo{
+			Audiences: []*protos.Audience{a},
+			Client:    &protos.ClientInfo{LibraryName: lib, LibraryVersion: v},
+		}
+	}
+
+	all := &protos.GetAllResponse{
+		Live: []*protos.LiveInfo{
+			client(aud, "go-sdk", "v0.0.95"),
+			client(aud, "go-sdk", "v0.0.95"),
+			client(aud, "python-sdk", "0.1.2"),
+			client(aud, "rust-sdk", "0.0.1"),
+			client(aud, "node-sdk", "dev"),
+			client(other, "go-sdk", "0.0.1"),
+		},
+	}
+
+	pipelineSteps := []interface{}{
+		map[string]interface{}{"name": "Is JSON", "valid_json": []interface{}{map[string]interface{}{}}},
+		map[string]interface{}{"name": "Match schema", "schema_validation": []interface{}{map[string]interface{}{}}},
+	}
+
+	minimums := map[string]map[string]string{"schema_validation": {"go-sdk": "0.1.0"}}
+
+	report := sdkCompatibilityReport(all, []*protos.Audience{aud}, pipelineSteps, minimums)
+	if len(report) != 1 {
+		t.Fatalf("expected a single finding, got: %v", report)
+	}
+
+	for _, s := range []string{"step 1 'Match schema'", "go-sdk 0.1.0 or later", "go-sdk v0.0.95"} {
+		if !strings.Contains(report[0], s) {
+			t.Errorf("expected report to contain '%s', got: %s", s, report[0])
+		}
+	}
+
+	// Libraries without a minimum version aren't checked
+	if report := sdkCompatibilityReport(all, []*protos.Audience{aud}, pipelineSteps, nil); len(report) != 0 {
+		t.Errorf("expected no findings without minimum versions, got: %v", report)
+	}
+}
+
+func TestSDKMinimumVersions(t *testing.T) {
+	minimums := sdkMinimumVersions([]interface{}{
+		map[string]interface{}{"step_type": "kv", "library": "go-sdk", "version": "0.1.0"},
+		map[string]interface{}{"step_type": "kv", "library": "python-sdk", "version": "0.2.0"},
+	})
+
+	if minimums["kv"]["go-sdk"] != "0.1.0" || minimums["kv"]["python-sdk"] != "0.2.0" {
+		t.Errorf("unexpected minimum versions: %v", minimums)
+	}
+
+	if _, errs := validateVersion()("latest", "version"); len(errs) == 0 {
+		t.Error("expected an error for an invalid version")
+	}
+}
+
+func TestSupportsVersion(t *testing.T) {
+	cases := map[string]bool{
+		"0.1.0":        true,
+		"v0.1.3":       true,
+		"0.0.99":       false,
+		"0.1.0-beta.1": false,
+		"unknown":      true,
+	}
+
+	for v, expected := range cases {
+		if got := supportsVersion(v, "0.1.0"); got != expected {
+			t.Errorf("%s: expected %t, got %t", v, expected, got)
+		}
+	}
+}
+
+func TestDiffSDKCompatibility(t *testing.T) {
+	aud := testAudience("billing-svc", "kafka", "orders", protos.OperationType_OPERATION_TYPE_CONSUMER)
+
+	r := resourcePipeline()
+
+	// The pipeline is unchanged since the last apply
+	state := &terraform.InstanceState{
+		ID: "pipeline-id",
+		Attributes: map[string]string{
+			"id":                              "pipeline-id",
+			"name":                            "Orders",
+			"step.#":                          "1",
+			"step.0.name":                     "Match schema",
+			"step.0.schema_validation.#":      "1",
+			"sdk_minimum_version.#":           "1",
+			"sdk_minimum_version.0.step_type": "schema_validation",
+			"sdk_minimum_version.0.library":   "go-sdk",
+			"sdk_minimum_version.0.version":   "0.1.0",
+			"schema_compatibility":            compatibilityOff,
+			"sdk_compatibility":               compatibilityWarn,
+			"deletion_protection":             "false",
+		},
+	}
+
+	cfg := terraform.NewResourceConfigRaw(map[string]interface{}{
+		"name":                 "Orders",
+		"schema_compatibility": compatibilityOff,
+		"step": []interface{}{map[string]interface{}{
+			"name":              "Match schema",
+			"schema_validation": []interface{}{map[string]interface{}{}},
+		}},
+		"sdk_minimum_version": []interface{}{map[string]interface{}{
+			"step_type": "schema_validation",
+			"library":   "go-sdk",
+			"version":   "0.1.0",
+		}},
+	})
+
+	diff := func(client interface{}) (*terraform.InstanceDiff, error) {
+		return schema.InternalMap(r.Schema).Diff(context.Background(), state, cfg, func(ctx context.Context, d *schema.ResourceDiff, m interface{}) error {
+			return diffSDKCompatibility(ctx, d, m)
+		}, client, true)
+	}
+
+	// An old client which connected since the last apply is reported
+	fake := &fakeExternalClient{getAll: &protos.GetAllResponse{
+		Pipelines: map[string]*protos.PipelineInfo{"pipeline-id": {Audiences: []*protos.Audience{aud}}},
+		Live: []*protos.LiveInfo{{
+			Audiences: []*protos.Audience{aud},
+			Client:    &protos.ClientInfo{LibraryName: "go-sdk", LibraryVersion: "0.0.1"},
+		}},
+	}}
+
+	d, err := diff(newFakeStreamdal(fake))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if attr := d.Attributes["sdk_compatibility_report.#"]; attr == nil || attr.New != "1" {
+		t.Fatalf("expected the old client to be reported, got: %v", d.Attributes)
+	}
+
+	// The check is skipped when the server can't be reached
+	fake.getAllErr = status.Error(codes.DeadlineExceeded, "context deadline exceeded")
+
+	if _, err := diff(newFakeStreamdal(fake)); err != nil {
+		t.Fatalf("expected the check to be skipped in warn mode, got: %s", err)
+	}
+}
@@ -0,0 +1,157 @@
+package provider
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+
+	"github.com/streamdal/streamdal/libs/protos/build/go/protos"
+)
+
+// Selector match modes
+const (
+	matchGlob  = "glob"
+	matchRegex = "regex"
+)
+
+// audienceSelectorSchema returns the schema of a block selecting audiences by
+// patterns on their fields
+func audienceSelectorSchema() *schema.Resource {
+	return &schema.Resource{
+		Schema: map[string]*schema.Schema{
+			"service_name": {
+				Description: "Pattern the service name must match",
+				Type:        schema.TypeString,
+				Optional:    true,
+				Default:     "*",
+			},
+			"component_name": {
+				Description: "Pattern the component name must match",
+				Type:        schema.TypeString,
+				Optional:    true,
+				Default:     "*",
+			},
+			"operation_name": {
+				Description: "Pattern the operation name must match",
+				Type:        schema.TypeString,
+				Optional:    true,
+				Default:     "*",
+			},
+			"operation_type": {
+				Description:  "The type of the operation, either `consumer` or `producer`. Matches both if not set",
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: getAudienceOperationTypes(),
+			},
+			"match": {
+				Description:  "How patterns are interpreted, either `glob` (`*` and `?` wildcards) or `regex`",
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      matchGlob,
+				ValidateFunc: validation.StringInSlice([]string{matchGlob, matchRegex}, false),
+			},
+		},
+	}
+}
+
+// audienceSelector matches audiences against the patterns of a selector block
+type audienceSelector struct {
+	service       *regexp.Regexp
+	component     *regexp.Regexp
+	operation     *regexp.Regexp
+	operationType string
+}
+
+// newAudienceSelectors compiles the selector blocks of a resource
+func newAudienceSelectors(blocks []interface{}) ([]*audienceSelector, error) {
+	selectors := make([]*audienceSelector, 0, len(blocks))
+
+	for i, block := range blocks {
+		cfg, ok := block.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		sel, err := newAudienceSelector(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("selector %d: %s", i, err)
+		}
+
+		selectors = append(selectors, sel)
+	}
+
+	return selectors, nil
+}
+
+func newAudienceSelector(cfg map[string]interface{}) (*audienceSelector, error) {
+	mode, _ := cfg["match"].(string)
+
+	sel := &audienceSelector{}
+	sel.operationType, _ = cfg["operation_type"].(string)
+
+	fields := map[string]**regexp.Regexp{
+		"service_name":   &sel.service,
+		"component_name": &sel.component,
+		"operation_name": &sel.operation,
+	}
+
+	for k, re := range fields {
+		pattern, _ := cfg[k].(string)
+
+		compiled, err := compilePattern(pattern, mode)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s pattern '%s': %s", k, pattern, err)
+		}
+
+		*re = compiled
+	}
+
+	return sel, nil
+}
+
+// compilePattern turns a glob or regex pattern into a regexp matching the whole value
+func compilePattern(pattern, mode string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		pattern = "*"
+		mode = matchGlob
+	}
+
+	if mode == matchRegex {
+		return regexp.Compile("^(?:" + pattern + ")$")
+	}
+
+	expr := regexp.QuoteMeta(pattern)
+	expr = strings.ReplaceAll(expr, `\*`, ".*")
+	expr = strings.ReplaceAll(expr, `\?`, ".")
+
+	return regexp.Compile("^" + expr + "$")
+}
+
+func (s *audienceSelector) Matches(aud *protos.Audience) bool {
+	if s.operationType != "" && audienceOperationTypeToString(aud.GetOperationType()) != s.operationType {
+		return false
+	}
+
+	return s.service.MatchString(aud.GetServiceName()) &&
+		s.component.MatchString(aud.GetComponentName()) &&
+		s.operation.MatchString(aud.GetOperationName())
+}
+
+// selectAudiences returns the audiences matched by any of the selectors
+func selectAudiences(selectors []*audienceSelector, audiences []*protos.Audience) []*protos.Audience {
+	matched := make([]*protos.Audience, 0)
+
+	for _, aud := range audiences {
+		for _, sel := range selectors {
+			if sel.Matches(aud) {
+				matched = append(matched, aud)
+				break
+			}
+		}
+	}
+
+	return matched
+}
@@ -76,7 +76,8 @@ func conditionSchema() *schema.Resource {
 							Type:        schema.TypeList,
 							Optional:    true,
 							Elem: &schema.Schema{
-								Type: schema.TypeString,
+								Type:         schema.TypeString,
+								ValidateFunc: validateJSONPath(),
 							},
 							DefaultFunc: func() (interface{}, error) {
 								return []string{}, nil
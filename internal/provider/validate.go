@@ -0,0 +1,645 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+
+	"github.com/streamdal/terraform-provider-streamdal/internal/schemas"
+)
+
+// unknownVariableValue is the placeholder the plugin SDK uses for nested values
+// which are not known until apply, ie. references to attributes of other resources
+const unknownVariableValue = "74D93920-ED26-11E3-AC10-0800200C9A66"
+
+// conditionTypes are the attributes of a step holding its conditions
+var conditionTypes = []string{"on_true", "on_false", "on_error"}
+
+// pathTransforms are the transform blocks which operate on a user supplied path,
+// along with the name of the attribute holding the path(s)
+var pathTransforms = map[string]string{
+	"replace_value": "path",
+	"delete_field":  "paths",
+	"obfuscate":     "path",
+	"mask_value":    "path",
+	"truncate":      "path",
+	"extract":       "paths",
+}
+
+// stepChecks are the checks run on each "step" block, along with their lint rule
+var stepChecks = []struct {
+	rule  string
+	check func(map[string]interface{}, cty.Path) diag.Diagnostics
+}{
+	{RuleStepType, validateStepType},
+	{RuleDetectiveArgs, validateDetectiveArgs},
+	{RuleMissingPath, validateStepPaths},
+	{RuleJSONSchema, validateSchemaValidationStep},
+	{RuleNotificationPaths, validateNotificationPayload},
+}
+
+// pipelineChecks are the checks run on all "step" blocks at once, along with their lint rule
+var pipelineChecks = []struct {
+	rule  string
+	check func([]interface{}) diag.Diagnostics
+}{
+	{RuleDynamicStep, validateDynamicSteps},
+	{RuleUnreachableSteps, validateReachableSteps},
+}
+
+// validatePipelineSteps performs checks on the "step" blocks of a pipeline which
+// span multiple attributes, and therefore can't be done with a ValidateFunc.
+func validatePipelineSteps(pipelineSteps []interface{}) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	for _, d := range checkPipelineSteps(pipelineSteps) {
+		diags = append(diags, d.Diagnostic)
+	}
+
+	return diags
+}
+
+// checkPipelineSteps runs the checks of validatePipelineSteps, and tags each
+// diagnostic with the lint rule of the check that produced it
+func checkPipelineSteps(pipelineSteps []interface{}) []RuleDiagnostic {
+	found := make([]RuleDiagnostic, 0)
+
+	for i, step := range pipelineSteps {
+		stepMap, ok := step.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		stepPath := cty.GetAttrPath("step").IndexInt(i)
+
+		for _, c := range stepChecks {
+			for _, d := range c.check(stepMap, stepPath) {
+				found = append(found, RuleDiagnostic{Rule: c.rule, Diagnostic: d})
+			}
+		}
+	}
+
+	for _, c := range pipelineChecks {
+		for _, d := range c.check(pipelineSteps) {
+			found = append(found, RuleDiagnostic{Rule: c.rule, Diagnostic: d})
+		}
+	}
+
+	return found
+}
+
+// validateStepType checks that each step has exactly one step type block
+func validateStepType(stepMap map[string]interface{}, stepPath cty.Path) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	found := make([]string, 0)
+
+	for _, st := range stepTypes {
+		if opts, ok := stepMap[st].([]interface{}); ok && len(opts) > 0 {
+			found = append(found, st)
+		}
+	}
+
+	switch {
+	case len(found) == 0:
+		diags = append(diags, diag.Diagnostic{
+			Severity:      diag.Error,
+			Summary:       "Step has no type",
+			Detail:        fmt.Sprintf("Each step must contain exactly one of: %s", strings.Join(stepTypes, ", ")),
+			AttributePath: stepPath,
+		})
+	case len(found) > 1:
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Step has multiple types",
+			Detail: fmt.Sprintf("Each step must contain exactly one step type, found: %s. Use a separate step for each.",
+				strings.Join(found, ", ")),
+			AttributePath: stepPath.GetAttr(found[1]),
+		})
+	}
+
+	return diags
+}
+
+// markUnknownLists returns a copy of the steps in which lists of values that are not
+// known until apply are replaced with unknownVariableValue. The SDK reads those as
+// empty lists, which the validations would otherwise treat as having no values.
+// unknown is called with the flatmap key of each empty list, ie. "step.0.detective.0.args".
+func markUnknownLists(pipelineSteps []interface{}, unknown func(key string) bool) []interface{} {
+	stepSchema := resourcePipeline().Schema["step"].Elem.(*schema.Resource).Schema
+
+	marked := make([]interface{}, len(pipelineSteps))
+	for i, step := range pipelineSteps {
+		marked[i] = markUnknownBlock(step, stepSchema, fmt.Sprintf("step.%d", i), unknown)
+	}
+
+	return marked
+}
+
+func markUnknownBlock(block interface{}, blockSchema map[string]*schema.Schema, key string,
+	unknown func(string) bool) interface{} {
+	blockMap, ok := block.(map[string]interface{})
+	if !ok {
+		return block
+	}
+
+	marked := make(map[string]interface{}, len(blockMap))
+
+	for attr, v := range blockMap {
+		marked[attr] = v
+
+		s, ok := blockSchema[attr]
+		if !ok || s.Type != schema.TypeList {
+			continue
+		}
+
+		list, _ := v.([]interface{})
+		attrKey := key + "." + attr
+
+		switch elem := s.Elem.(type) {
+		case *schema.Resource:
+			blocks := make([]interface{}, len(list))
+			for i, nested := range list {
+				blocks[i] = markUnknownBlock(nested, elem.Schema, fmt.Sprintf("%s.%d", attrKey, i), unknown)
+			}
+
+			marked[attr] = blocks
+		case *schema.Schema:
+			if len(list) == 0 && unknown(attrKey) {
+				marked[attr] = unknownVariableValue
+			}
+		}
+	}
+
+	return marked
+}
+
+// isTypeArgs are the types accepted by the is_type detective
+var isTypeArgs = []string{"string", "number", "boolean", "bool", "array", "object", "null"}
+
+// validateDetectiveArgs checks the number and format of the args of detective types
+// which need them. Types which take no args are not checked.
+func validateDetectiveArgs(stepMap map[string]interface{}, stepPath cty.Path) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	cfg := firstBlock(stepMap["detective"])
+	if cfg == nil {
+		return diags
+	}
+
+	detectiveType := strings.ToLower(interfaceToString(cfg["type"]))
+	if detectiveType == "" || detectiveType == unknownVariableValue {
+		return diags
+	}
+
+	// Args which aren't known until apply are marked by markUnknownLists, and not checked
+	args, ok := cfg["args"].([]interface{})
+	if !ok {
+		return diags
+	}
+
+	values := make([]string, 0, len(args))
+	for _, arg := range args {
+		v, ok := arg.(string)
+		if !ok || v == unknownVariableValue {
+			return diags
+		}
+
+		values = append(values, v)
+	}
+
+	var err error
+
+	switch {
+	case detectiveType == "string_contains_any", detectiveType == "string_contains_all", detectiveType == "string_equal":
+		err = expectArgs(values, 1, -1, nil)
+	case detectiveType == "regex":
+		err = expectArgs(values, 1, 1, func(v string) error {
+			_, err := regexp.Compile(v)
+			return err
+		})
+	case detectiveType == "is_type":
+		err = expectArgs(values, 1, 1, func(v string) error {
+			for _, t := range isTypeArgs {
+				if v == t {
+					return nil
+				}
+			}
+
+			return fmt.Errorf("must be one of: %s", strings.Join(isTypeArgs, ", "))
+		})
+	case detectiveType == "string_length_min", detectiveType == "string_length_max":
+		err = expectArgs(values, 1, 1, isInt)
+	case detectiveType == "string_length_range":
+		err = expectRange(values, isInt)
+	case detectiveType == "numeric_range":
+		err = expectRange(values, isNumber)
+	case strings.HasPrefix(detectiveType, "numeric_"):
+		err = expectArgs(values, 1, 1, isNumber)
+	}
+
+	if err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity:      diag.Error,
+			Summary:       fmt.Sprintf("Invalid args for '%s' detective", detectiveType),
+			Detail:        err.Error(),
+			AttributePath: stepPath.GetAttr("detective").IndexInt(0).GetAttr("args"),
+		})
+	}
+
+	return diags
+}
+
+// expectArgs checks that there are between min and max args (max -1 for no limit),
+// and that each of them passes the check, if given
+func expectArgs(args []string, min, max int, check func(string) error) error {
+	if len(args) < min {
+		return fmt.Errorf("expected at least %d arg(s), got %d", min, len(args))
+	}
+
+	if max >= 0 && len(args) > max {
+		return fmt.Errorf("expected at most %d arg(s), got %d", max, len(args))
+	}
+
+	if check == nil {
+		return nil
+	}
+
+	for i, arg := range args {
+		if err := check(arg); err != nil {
+			return fmt.Errorf("arg %d '%s': %s", i, arg, err)
+		}
+	}
+
+	return nil
+}
+
+// expectRange checks for a minimum and maximum arg, with the minimum not above the maximum
+func expectRange(args []string, check func(string) error) error {
+	if len(args) != 2 {
+		return fmt.Errorf("expected a minimum and maximum arg, got %d arg(s)", len(args))
+	}
+
+	if err := expectArgs(args, 2, 2, check); err != nil {
+		return err
+	}
+
+	lo, _ := strconv.ParseFloat(args[0], 64)
+	hi, _ := strconv.ParseFloat(args[1], 64)
+
+	if lo > hi {
+		return fmt.Errorf("minimum %s is greater than maximum %s", args[0], args[1])
+	}
+
+	return nil
+}
+
+func isInt(v string) error {
+	if _, err := strconv.Atoi(v); err != nil {
+		return errors.New("must be an integer")
+	}
+
+	return nil
+}
+
+func isNumber(v string) error {
+	if _, err := strconv.ParseFloat(v, 64); err != nil {
+		return errors.New("must be a number")
+	}
+
+	return nil
+}
+
+// validateDynamicSteps warns about dynamic steps which won't get any results to
+// operate on. Only transforms use the results of the previous step, and only
+// detectives produce them.
+func validateDynamicSteps(pipelineSteps []interface{}) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	for i, step := range pipelineSteps {
+		stepMap, _ := step.(map[string]interface{})
+
+		if dynamic, _ := stepMap["dynamic"].(bool); !dynamic {
+			continue
+		}
+
+		stepPath := cty.GetAttrPath("step").IndexInt(i).GetAttr("dynamic")
+
+		var detail string
+
+		switch {
+		case getStepType(stepMap) != "transform":
+			detail = "Only transform steps use the results of the previous step, 'dynamic' has no effect on this step."
+		case i == 0:
+			detail = "The first step of a pipeline has no previous step to take results from."
+		default:
+			prev, _ := pipelineSteps[i-1].(map[string]interface{})
+			if getStepType(prev) == "detective" {
+				continue
+			}
+
+			detail = "Only detective steps produce results for a dynamic step, but the previous step is not a detective."
+		}
+
+		diags = append(diags, diag.Diagnostic{
+			Severity:      diag.Warning,
+			Summary:       "Dynamic step has no results to operate on",
+			Detail:        detail,
+			AttributePath: stepPath,
+		})
+	}
+
+	return diags
+}
+
+// validateReachableSteps warns about steps which can never run, because every
+// outcome of an earlier step aborts the pipeline
+func validateReachableSteps(pipelineSteps []interface{}) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	for i, step := range pipelineSteps {
+		if i == len(pipelineSteps)-1 {
+			break
+		}
+
+		stepMap, _ := step.(map[string]interface{})
+
+		if !alwaysAborts(stepMap) {
+			continue
+		}
+
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Warning,
+			Summary:  "Unreachable steps",
+			Detail: fmt.Sprintf("Step '%s' aborts the pipeline on true, false and error, so the %d step(s) after it never run.",
+				interfaceToString(stepMap["name"]), len(pipelineSteps)-i-1),
+			AttributePath: cty.GetAttrPath("step").IndexInt(i + 1),
+		})
+
+		break
+	}
+
+	return diags
+}
+
+// alwaysAborts returns true if all of the step's conditions abort the pipeline
+func alwaysAborts(stepMap map[string]interface{}) bool {
+	for _, cond := range conditionTypes {
+		cfg := firstBlock(stepMap[cond])
+		if cfg == nil {
+			return false
+		}
+
+		switch strings.ToLower(interfaceToString(cfg["abort"])) {
+		case "abort_current", "abort_all":
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// validateStepPaths warns when a step that operates on a path has none set.
+// Dynamic transforms get their paths from the results of the previous step, so
+// an empty path is expected for those.
+func validateStepPaths(stepMap map[string]interface{}, stepPath cty.Path) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	if cfg := firstBlock(stepMap["detective"]); cfg != nil {
+		detectiveType := strings.ToLower(interfaceToString(cfg["type"]))
+
+		if detectiveNeedsPath(detectiveType) && interfaceToString(cfg["path"]) == "" {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Warning,
+				Summary:  fmt.Sprintf("Detective '%s' has no path set", detectiveType),
+				Detail: fmt.Sprintf("The '%s' detective checks the value of a field, and needs a 'path' to the field "+
+					"to check.", detectiveType),
+				AttributePath: stepPath.GetAttr("detective").IndexInt(0).GetAttr("path"),
+			})
+		}
+	}
+
+	if dynamic, _ := stepMap["dynamic"].(bool); dynamic {
+		return diags
+	}
+
+	transformData, ok := stepMap["transform"].([]interface{})
+	if !ok || len(transformData) == 0 {
+		return diags
+	}
+
+	config, _ := transformData[0].(map[string]interface{})
+
+	for _, block := range getTransformTypes(config) {
+		attr := pathTransforms[block]
+
+		cfg, _ := config[block].([]interface{})[0].(map[string]interface{})
+
+		var empty bool
+		switch v := cfg[attr].(type) {
+		case string:
+			empty = v == ""
+		case []interface{}:
+			empty = len(v) == 0
+		default:
+			empty = true
+		}
+
+		if !empty {
+			continue
+		}
+
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Warning,
+			Summary:  fmt.Sprintf("Transform '%s' has no %s set", block, attr),
+			Detail: fmt.Sprintf("The '%s' transform needs a '%s' to operate on unless 'dynamic' is set to true, "+
+				"in which case the results of the previous step are used.", block, attr),
+			AttributePath: stepPath.GetAttr("transform").IndexInt(0).GetAttr(block).IndexInt(0).GetAttr(attr),
+		})
+	}
+
+	return diags
+}
+
+// pathDetectivePrefixes are the prefixes of the detective types which check the
+// value of a single field. PII types scan the whole payload when no path is set,
+// and is_empty checks the payload itself.
+var pathDetectivePrefixes = []string{
+	"has_field", "is_type", "string_", "regex", "numeric_", "ipv4_", "ipv6_", "mac_",
+	"timestamp_", "boolean_", "uuid", "url", "hostname", "semver",
+}
+
+// detectiveNeedsPath returns true if the detective type has nothing to check without a path
+func detectiveNeedsPath(detectiveType string) bool {
+	for _, prefix := range pathDetectivePrefixes {
+		if strings.HasPrefix(detectiveType, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// validateNotificationPayload checks that notifications which send selected paths
+// of the payload have paths to select
+func validateNotificationPayload(stepMap map[string]interface{}, stepPath cty.Path) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	for _, cond := range conditionTypes {
+		notification := firstBlock(firstBlock(stepMap[cond])["notification"])
+		if notification == nil {
+			continue
+		}
+
+		if payloadType, _ := notification["payload_type"].(string); payloadType != "select_paths" {
+			continue
+		}
+
+		// Paths which aren't known until apply are marked by markUnknownLists, and not checked
+		paths, ok := notification["paths"].([]interface{})
+		if !ok || len(paths) > 0 {
+			continue
+		}
+
+		diags = append(diags, diag.Diagnostic{
+			Severity:      diag.Error,
+			Summary:       "Notification has no paths to select",
+			Detail:        "payload_type 'select_paths' sends only the given paths of the payload, so at least one path must be set.",
+			AttributePath: stepPath.GetAttr(cond).IndexInt(0).GetAttr("notification").IndexInt(0).GetAttr("paths"),
+		})
+	}
+
+	return diags
+}
+
+// validateSchemaValidationStep checks that the JSON schema of a schema_validation step
+// is valid for the selected draft. Schemas from local files are generated first.
+func validateSchemaValidationStep(stepMap map[string]interface{}, stepPath cty.Path) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	jsCfg := jsonSchemaConfig(stepMap)
+	if jsCfg == nil {
+		return diags
+	}
+
+	jsPath := stepPath.GetAttr("schema_validation").IndexInt(0).GetAttr("json_schema").IndexInt(0)
+
+	doc, _ := jsCfg["json_schema"].(string)
+	draftStr, _ := jsCfg["draft"].(string)
+
+	// Values which aren't known until apply are checked when the step is built
+	if doc == unknownVariableValue || schemaSourceUnknown(jsCfg) || draftStr == "" || draftStr == unknownVariableValue {
+		return diags
+	}
+
+	attr := schemaSource(jsCfg)
+	if attr == "" {
+		attr = "json_schema"
+	}
+
+	docBytes, err := jsonSchemaDocument(jsCfg)
+	if err != nil {
+		return append(diags, diag.Diagnostic{
+			Severity:      diag.Error,
+			Summary:       "Invalid JSON schema configuration",
+			Detail:        err.Error(),
+			AttributePath: jsPath.GetAttr(attr),
+		})
+	}
+
+	draft, err := schemaValidationJSONSchemaDraftFromString(draftStr)
+	if err != nil {
+		return diags
+	}
+
+	if err := schemas.Validate(docBytes, draft); err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity:      diag.Error,
+			Summary:       "Invalid JSON schema",
+			Detail:        err.Error(),
+			AttributePath: jsPath.GetAttr(attr),
+		})
+	}
+
+	return diags
+}
+
+// diagsToError combines all error diagnostics into a single error, for use in
+// places such as CustomizeDiff which can't return diagnostics
+func diagsToError(diags diag.Diagnostics) error {
+	msgs := make([]string, 0)
+
+	for _, d := range diags {
+		if d.Severity == diag.Error {
+			msgs = append(msgs, diagMessage(d))
+		}
+	}
+
+	if len(msgs) == 0 {
+		return nil
+	}
+
+	return errors.New(strings.Join(msgs, "\n"))
+}
+
+// diffValidationWarnings plans the warning diagnostics of the step validations as
+// changes to validation_warnings, since CustomizeDiff can't return warnings
+func diffValidationWarnings(d *schema.ResourceDiff, diags diag.Diagnostics) error {
+	warnings := make([]interface{}, 0)
+
+	for _, diagnostic := range diags {
+		if diagnostic.Severity == diag.Warning {
+			warnings = append(warnings, diagMessage(diagnostic))
+		}
+	}
+
+	if reflect.DeepEqual(warnings, d.Get("validation_warnings").([]interface{})) {
+		return nil
+	}
+
+	return d.SetNew("validation_warnings", warnings)
+}
+
+// diagMessage renders a diagnostic on a single line, prefixed by its attribute path
+func diagMessage(d diag.Diagnostic) string {
+	msg := d.Summary
+	if len(d.AttributePath) > 0 {
+		msg = attributePathString(d.AttributePath) + ": " + msg
+	}
+
+	if d.Detail != "" {
+		msg += ": " + d.Detail
+	}
+
+	return msg
+}
+
+// attributePathString renders an attribute path in the dotted form used in
+// Terraform's flatmap state, ie. "step.0.transform.0.obfuscate"
+func attributePathString(p cty.Path) string {
+	parts := make([]string, 0, len(p))
+
+	for _, step := range p {
+		switch s := step.(type) {
+		case cty.GetAttrStep:
+			parts = append(parts, s.Name)
+		case cty.IndexStep:
+			if s.Key.Type() == cty.Number {
+				i, _ := s.Key.AsBigFloat().Int64()
+				parts = append(parts, fmt.Sprintf("%d", i))
+			} else {
+				parts = append(parts, s.Key.AsString())
+			}
+		}
+	}
+
+	return strings.Join(parts, ".")
+}
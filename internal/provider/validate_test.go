@@ -0,0 +1,253 @@
+package provider
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+func TestPipelinePathValidation(t *testing.T) {
+	raw := transformPipelineConfig("obfuscate", map[string]interface{}{"path": "object..email"})
+
+	diags := resourcePipeline().Validate(terraform.NewResourceConfigRaw(raw))
+	if !diags.HasError() {
+		t.Fatal("expected invalid path to fail validation")
+	}
+
+	raw = transformPipelineConfig("obfuscate", map[string]interface{}{"path": "object.email"})
+
+	if diags := resourcePipeline().Validate(terraform.NewResourceConfigRaw(raw)); diags.HasError() {
+		t.Fatalf("unexpected validation error: %v", diags)
+	}
+}
+
+func TestValidatePipelineSteps_EmptyPath(t *testing.T) {
+	stepWithPath := func(dynamic bool, path string) []interface{} {
+		return []interface{}{
+			map[string]interface{}{
+				"dynamic": dynamic,
+				"transform": []interface{}{
+					map[string]interface{}{
+						"mask_value": []interface{}{map[string]interface{}{"path": path, "mask": "*"}},
+					},
+				},
+			},
+		}
+	}
+
+	diags := validatePipelineSteps(stepWithPath(false, ""))
+	if len(diags) != 1 || diags[0].Severity != diag.Warning {
+		t.Fatalf("expected a single warning, got %v", diags)
+	}
+
+	// Dynamic steps take their path from the results of a preceding detective
+	detective := map[string]interface{}{
+		"detective": []interface{}{map[string]interface{}{"type": "pii_email", "args": []interface{}{}}},
+	}
+
+	if diags := validatePipelineSteps(append([]interface{}{detective}, stepWithPath(true, "")...)); len(diags) != 0 {
+		t.Fatalf("expected no warnings for dynamic step, got %v", diags)
+	}
+
+	if diags := validatePipelineSteps(stepWithPath(false, "object.email")); len(diags) != 0 {
+		t.Fatalf("expected no warnings, got %v", diags)
+	}
+}
+
+func TestValidatePipelineSteps_DetectivePath(t *testing.T) {
+	detectiveStep := func(detectiveType, path string) []interface{} {
+		return []interface{}{
+			map[string]interface{}{
+				"detective": []interface{}{map[string]interface{}{"type": detectiveType, "path": path, "args": []interface{}{}}},
+			},
+		}
+	}
+
+	diags := validatePipelineSteps(detectiveStep("has_field", ""))
+	if len(diags) != 1 || diags[0].Severity != diag.Warning ||
+		attributePathString(diags[0].AttributePath) != "step.0.detective.0.path" {
+		t.Fatalf("expected a single warning on the path, got %v", diags)
+	}
+
+	// PII detectives scan the whole payload without a path
+	for _, steps := range [][]interface{}{detectiveStep("has_field", "object.email"), detectiveStep("pii_email", "")} {
+		if diags := validatePipelineSteps(steps); len(diags) != 0 {
+			t.Errorf("unexpected diagnostics: %v", diags)
+		}
+	}
+}
+
+func TestValidationWarningsPlan(t *testing.T) {
+	raw := transformPipelineConfig("mask_value", map[string]interface{}{"path": "", "mask": "*"})
+
+	diff, err := resourcePipeline().SimpleDiff(context.Background(), nil, terraform.NewResourceConfigRaw(raw), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if attr := diff.Attributes["validation_warnings.#"]; attr == nil || attr.New != "1" {
+		t.Fatalf("expected the empty path warning in the plan, got %v", diff.Attributes)
+	}
+
+	if attr := diff.Attributes["validation_warnings.0"]; attr == nil ||
+		!strings.HasPrefix(attr.New, "step.0.transform.0.mask_value.0.path: Transform 'mask_value' has no path set") {
+		t.Fatalf("unexpected warning: %v", attr)
+	}
+}
+
+func TestValidateDetectiveArgs(t *testing.T) {
+	detectiveStep := func(detectiveType string, args ...interface{}) []interface{} {
+		return []interface{}{
+			map[string]interface{}{
+				"detective": []interface{}{map[string]interface{}{"type": detectiveType, "path": "object.field", "args": args}},
+			},
+		}
+	}
+
+	invalid := map[string][]interface{}{
+		"regex":               detectiveStep("regex", "("),
+		"is_type":             detectiveStep("is_type", "integer"),
+		"string_contains_any": detectiveStep("string_contains_any"),
+		"string_length_range": detectiveStep("string_length_range", "5", "1"),
+		"numeric_min":         detectiveStep("numeric_min", "ten"),
+	}
+
+	for name, steps := range invalid {
+		if diags := validatePipelineSteps(steps); !diags.HasError() {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+
+	valid := [][]interface{}{
+		detectiveStep("regex", "^[a-z]+$"),
+		detectiveStep("numeric_range", "1", "10.5"),
+		detectiveStep("pii_email"),
+		detectiveStep("regex", unknownVariableValue),
+	}
+
+	for _, steps := range valid {
+		if diags := validatePipelineSteps(steps); len(diags) != 0 {
+			t.Errorf("unexpected diagnostics: %v", diags)
+		}
+	}
+}
+
+func TestValidateDetectiveArgs_UnknownList(t *testing.T) {
+	raw := map[string]interface{}{
+		"name": "Unknown args",
+		"step": []interface{}{
+			map[string]interface{}{
+				"name": "Contains any",
+				"detective": []interface{}{map[string]interface{}{
+					"path": "object.field",
+					"type": "string_contains_any",
+					"args": unknownVariableValue,
+				}},
+			},
+		},
+	}
+
+	if _, err := resourcePipeline().SimpleDiff(context.Background(), nil, terraform.NewResourceConfigRaw(raw), nil); err != nil {
+		t.Fatalf("expected args which aren't known until apply to pass the plan, got: %s", err)
+	}
+
+	if diags := ValidatePipeline(context.Background(), raw); len(diags) != 0 {
+		t.Fatalf("unexpected diagnostics: %v", diags)
+	}
+
+	// Known but empty args are still rejected
+	raw["step"].([]interface{})[0].(map[string]interface{})["detective"].([]interface{})[0].(map[string]interface{})["args"] = []interface{}{}
+
+	if _, err := resourcePipeline().SimpleDiff(context.Background(), nil, terraform.NewResourceConfigRaw(raw), nil); err == nil {
+		t.Fatal("expected empty args to fail the plan")
+	}
+}
+
+func TestValidatePipelineSteps_Order(t *testing.T) {
+	abort := []interface{}{map[string]interface{}{"abort": "abort_all"}}
+
+	pipelineSteps := []interface{}{
+		map[string]interface{}{
+			"name":       "Always aborts",
+			"dynamic":    true,
+			"valid_json": []interface{}{map[string]interface{}{}},
+			"on_true":    abort,
+			"on_false":   abort,
+			"on_error":   abort,
+		},
+		map[string]interface{}{
+			"name": "No type",
+		},
+	}
+
+	summaries := make(map[string]bool)
+	for _, d := range validatePipelineSteps(pipelineSteps) {
+		summaries[d.Summary] = true
+	}
+
+	for _, s := range []string{"Step has no type", "Dynamic step has no results to operate on", "Unreachable steps"} {
+		if !summaries[s] {
+			t.Errorf("expected diagnostic '%s', got %v", s, summaries)
+		}
+	}
+}
+
+func TestValidateNotificationPayload(t *testing.T) {
+	stepWithNotification := func(payloadType string, paths ...interface{}) []interface{} {
+		return []interface{}{
+			map[string]interface{}{
+				"valid_json": []interface{}{map[string]interface{}{}},
+				"on_false": []interface{}{map[string]interface{}{
+					"notification": []interface{}{map[string]interface{}{
+						"notification_config_ids": []interface{}{"slack"},
+						"payload_type":            payloadType,
+						"paths":                   paths,
+					}},
+				}},
+			},
+		}
+	}
+
+	diags := validatePipelineSteps(stepWithNotification("select_paths"))
+	if !diags.HasError() || attributePathString(diags[0].AttributePath) != "step.0.on_false.0.notification.0.paths" {
+		t.Fatalf("expected error for select_paths without paths, got %v", diags)
+	}
+
+	if diags := validatePipelineSteps(stepWithNotification("select_paths", "object.email")); len(diags) != 0 {
+		t.Fatalf("unexpected diagnostics: %v", diags)
+	}
+
+	if diags := validatePipelineSteps(stepWithNotification("exclude")); len(diags) != 0 {
+		t.Fatalf("unexpected diagnostics: %v", diags)
+	}
+}
+
+func TestValidateNotificationPayload_UnknownPaths(t *testing.T) {
+	raw := map[string]interface{}{
+		"name": "Unknown paths",
+		"step": []interface{}{
+			map[string]interface{}{
+				"name":       "Valid JSON",
+				"valid_json": []interface{}{map[string]interface{}{}},
+				"on_false": []interface{}{map[string]interface{}{
+					"notification": []interface{}{map[string]interface{}{
+						"notification_config_ids": []interface{}{"slack"},
+						"payload_type":            "select_paths",
+						"paths":                   unknownVariableValue,
+					}},
+				}},
+			},
+		},
+	}
+
+	if _, err := resourcePipeline().SimpleDiff(context.Background(), nil, terraform.NewResourceConfigRaw(raw), nil); err != nil {
+		t.Fatalf("expected paths which aren't known until apply to pass the plan, got: %s", err)
+	}
+
+	if diags := ValidatePipeline(context.Background(), raw); len(diags) != 0 {
+		t.Fatalf("unexpected diagnostics: %v", diags)
+	}
+}
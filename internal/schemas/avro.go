@@ -0,0 +1,252 @@
+package schemas
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+)
+
+// FromAvro converts the Avro schema (.avsc) at path into a JSON schema. The JSON
+// schema describes records as plain JSON objects, ie. a union of "null" and
+// "string" is either null or a string, rather than Avro's own JSON encoding which
+// wraps union values in an object naming their type.
+func FromAvro(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read Avro schema: %s", err)
+	}
+
+	var parsed interface{}
+	if err := unmarshal(data, &parsed); err != nil {
+		return nil, fmt.Errorf("schema '%s' is not valid JSON: %s", path, err)
+	}
+
+	c := &avroConverter{
+		seen: make(map[string]bool),
+		defs: make(map[string]interface{}),
+	}
+
+	// References to the top level type point to the root of the document
+	if obj, ok := parsed.(map[string]interface{}); ok {
+		if name, _ := obj["name"].(string); name != "" {
+			c.root = avroFullName(name, obj["namespace"], "")
+		}
+	}
+
+	root, err := c.convert(parsed, "")
+	if err != nil {
+		return nil, fmt.Errorf("unable to convert Avro schema '%s': %s", path, err)
+	}
+
+	if len(c.defs) > 0 {
+		root[definitionsKey] = c.defs
+	}
+
+	return json.Marshal(root)
+}
+
+type avroConverter struct {
+	// root is the full name of the top level type, if it is a named type
+	root string
+
+	// seen contains the full names of all named types seen so far
+	seen map[string]bool
+
+	// defs contains the schemas of named types other than the root, keyed by full name
+	defs map[string]interface{}
+}
+
+// convert returns the schema for an Avro type. namespace is the namespace of the
+// enclosing named type, used to resolve unqualified names.
+func (c *avroConverter) convert(t interface{}, namespace string) (map[string]interface{}, error) {
+	switch val := t.(type) {
+	case string:
+		if schema, ok := avroPrimitive(val); ok {
+			return schema, nil
+		}
+
+		return c.reference(val, namespace)
+	case []interface{}:
+		// Unions
+		anyOf := make([]interface{}, 0, len(val))
+
+		for _, member := range val {
+			schema, err := c.convert(member, namespace)
+			if err != nil {
+				return nil, err
+			}
+
+			anyOf = append(anyOf, schema)
+		}
+
+		return map[string]interface{}{"anyOf": anyOf}, nil
+	case map[string]interface{}:
+		return c.complex(val, namespace)
+	default:
+		return nil, fmt.Errorf("invalid type definition '%v'", t)
+	}
+}
+
+func (c *avroConverter) complex(obj map[string]interface{}, namespace string) (map[string]interface{}, error) {
+	typeName, ok := obj["type"].(string)
+	if !ok {
+		// ie. {"type": {"type": "array", ...}}
+		return c.convert(obj["type"], namespace)
+	}
+
+	switch typeName {
+	case "record", "error", "enum", "fixed":
+		return c.named(obj, typeName, namespace)
+	case "array":
+		items, err := c.convert(obj["items"], namespace)
+		if err != nil {
+			return nil, err
+		}
+
+		return map[string]interface{}{"type": "array", "items": items}, nil
+	case "map":
+		values, err := c.convert(obj["values"], namespace)
+		if err != nil {
+			return nil, err
+		}
+
+		return map[string]interface{}{"type": "object", "additionalProperties": values}, nil
+	default:
+		// Primitives with attributes such as logicalType validate as their
+		// underlying type
+		return c.convert(typeName, namespace)
+	}
+}
+
+// named converts a record, enum or fixed type and registers it so that later
+// references to it by name can be resolved
+func (c *avroConverter) named(obj map[string]interface{}, typeName, namespace string) (map[string]interface{}, error) {
+	name, _ := obj["name"].(string)
+	if name == "" {
+		return nil, fmt.Errorf("%s is missing a name", typeName)
+	}
+
+	fullName := avroFullName(name, obj["namespace"], namespace)
+	if c.seen[fullName] {
+		return nil, fmt.Errorf("type '%s' is defined more than once", fullName)
+	}
+
+	c.seen[fullName] = true
+
+	if i := strings.LastIndex(fullName, "."); i >= 0 {
+		namespace = fullName[:i]
+	} else {
+		namespace = ""
+	}
+
+	var schema map[string]interface{}
+
+	switch typeName {
+	case "enum":
+		symbols, _ := obj["symbols"].([]interface{})
+		schema = map[string]interface{}{"type": "string", "enum": symbols}
+	case "fixed":
+		schema = map[string]interface{}{"type": "string"}
+	default:
+		fields, _ := obj["fields"].([]interface{})
+
+		properties := map[string]interface{}{}
+		required := make([]interface{}, 0)
+
+		for _, f := range fields {
+			field, _ := f.(map[string]interface{})
+
+			fieldName, _ := field["name"].(string)
+			if fieldName == "" {
+				return nil, fmt.Errorf("record '%s' has a field without a name", fullName)
+			}
+
+			fieldSchema, err := c.convert(field["type"], namespace)
+			if err != nil {
+				return nil, fmt.Errorf("field '%s.%s': %s", fullName, fieldName, err)
+			}
+
+			properties[fieldName] = fieldSchema
+
+			// Fields without a default must be present
+			if _, ok := field["default"]; !ok {
+				required = append(required, fieldName)
+			}
+		}
+
+		schema = map[string]interface{}{"type": "object", "properties": properties}
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+	}
+
+	if fullName == c.root {
+		return schema, nil
+	}
+
+	c.defs[fullName] = schema
+
+	return map[string]interface{}{"$ref": "#/" + definitionsKey + "/" + fullName}, nil
+}
+
+// reference resolves a reference to a previously defined named type
+func (c *avroConverter) reference(name, namespace string) (map[string]interface{}, error) {
+	candidates := []string{name}
+	if !strings.Contains(name, ".") && namespace != "" {
+		candidates = []string{namespace + "." + name, name}
+	}
+
+	for _, candidate := range candidates {
+		if !c.seen[candidate] {
+			continue
+		}
+
+		if candidate == c.root {
+			return map[string]interface{}{"$ref": "#"}, nil
+		}
+
+		return map[string]interface{}{"$ref": "#/" + definitionsKey + "/" + candidate}, nil
+	}
+
+	return nil, fmt.Errorf("unknown type '%s'", name)
+}
+
+func avroPrimitive(name string) (map[string]interface{}, bool) {
+	switch name {
+	case "null":
+		return map[string]interface{}{"type": "null"}, true
+	case "boolean":
+		return map[string]interface{}{"type": "boolean"}, true
+	case "int":
+		return map[string]interface{}{"type": "integer", "minimum": math.MinInt32, "maximum": math.MaxInt32}, true
+	case "long":
+		return map[string]interface{}{"type": "integer"}, true
+	case "float", "double":
+		return map[string]interface{}{"type": "number"}, true
+	case "bytes", "string":
+		return map[string]interface{}{"type": "string"}, true
+	}
+
+	return nil, false
+}
+
+// avroFullName resolves the full name of a named type, see
+// https://avro.apache.org/docs/1.11.1/specification/#names
+func avroFullName(name string, namespace interface{}, enclosing string) string {
+	if strings.Contains(name, ".") {
+		return name
+	}
+
+	ns, ok := namespace.(string)
+	if !ok {
+		ns = enclosing
+	}
+
+	if ns == "" {
+		return name
+	}
+
+	return ns + "." + name
+}
@@ -0,0 +1,95 @@
+package schemas
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/streamdal/streamdal/libs/protos/build/go/protos/steps"
+)
+
+func TestFromAvro(t *testing.T) {
+	avsc := `{
+  "type": "record",
+  "name": "User",
+  "namespace": "events",
+  "fields": [
+    { "name": "id", "type": "long" },
+    { "name": "email", "type": ["null", "string"], "default": null },
+    { "name": "status", "type": { "type": "enum", "name": "Status", "symbols": ["ACTIVE", "DISABLED"] } },
+    { "name": "previous_status", "type": "Status", "default": "ACTIVE" },
+    { "name": "address", "type": {
+      "type": "record",
+      "name": "Address",
+      "namespace": "events.common",
+      "fields": [{ "name": "street", "type": "string" }]
+    } },
+    { "name": "tags", "type": { "type": "map", "values": { "type": "array", "items": "string" } } },
+    { "name": "manager", "type": ["null", "User"], "default": null },
+    { "name": "created_at", "type": { "type": "long", "logicalType": "timestamp-millis" } }
+  ]
+}`
+
+	path := filepath.Join(t.TempDir(), "user.avsc")
+	if err := os.WriteFile(path, []byte(avsc), 0o644); err != nil {
+		t.Fatalf("unable to write schema: %s", err)
+	}
+
+	doc, err := FromAvro(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := `{
+  "type": "object",
+  "properties": {
+    "id": { "type": "integer" },
+    "email": { "anyOf": [{ "type": "null" }, { "type": "string" }] },
+    "status": { "$ref": "#/definitions/events.Status" },
+    "previous_status": { "$ref": "#/definitions/events.Status" },
+    "address": { "$ref": "#/definitions/events.common.Address" },
+    "tags": { "type": "object", "additionalProperties": { "type": "array", "items": { "type": "string" } } },
+    "manager": { "anyOf": [{ "type": "null" }, { "$ref": "#" }] },
+    "created_at": { "type": "integer" }
+  },
+  "required": ["id", "status", "address", "tags", "created_at"],
+  "definitions": {
+    "events.Status": { "type": "string", "enum": ["ACTIVE", "DISABLED"] },
+    "events.common.Address": {
+      "type": "object",
+      "properties": { "street": { "type": "string" } },
+      "required": ["street"]
+    }
+  }
+}`
+
+	if !Equal(doc, []byte(expected)) {
+		t.Fatalf("unexpected schema: %s", doc)
+	}
+
+	if err := Validate(doc, steps.JSONSchemaDraft_JSONSCHEMA_DRAFT_07); err != nil {
+		t.Fatalf("expected generated schema to be valid, got: %s", err)
+	}
+}
+
+func TestFromAvro_Errors(t *testing.T) {
+	cases := map[string]string{
+		"unknown type":   `{"type":"record","name":"User","fields":[{"name":"a","type":"Missing"}]}`,
+		"missing name":   `{"type":"record","fields":[]}`,
+		"duplicate type": `{"type":"record","name":"User","fields":[{"name":"a","type":{"type":"record","name":"User","fields":[]}}]}`,
+		"not json":       `{"type":`,
+	}
+
+	for name, avsc := range cases {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "schema.avsc")
+			if err := os.WriteFile(path, []byte(avsc), 0o644); err != nil {
+				t.Fatalf("unable to write schema: %s", err)
+			}
+
+			if _, err := FromAvro(path); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,223 @@
+package schemas
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// definitionsKey is where bundled documents are placed in the root schema. Drafts
+// 4 through 7 don't define a location for reusable schemas, but "definitions" is
+// the conventional one and is understood by all of them.
+const definitionsKey = "definitions"
+
+var invalidKeyChars = regexp.MustCompile(`[^A-Za-z0-9_]+`)
+
+// Bundle loads the JSON schema at rootPath and inlines every schema it references
+// through a relative "$ref", such as "./common/address.json#/properties/street".
+// Referenced documents are placed under "definitions" in the root schema and the
+// references are rewritten to point at them, so the result is a single
+// self-contained document. References are resolved relative to the file they
+// appear in and are never fetched from the network.
+func Bundle(rootPath string) ([]byte, error) {
+	b := &bundler{
+		rootDir: filepath.Dir(rootPath),
+		keys:    make(map[string]string),
+		defs:    make(map[string]interface{}),
+	}
+
+	absRoot, err := filepath.Abs(rootPath)
+	if err != nil {
+		return nil, err
+	}
+
+	root, err := b.load(absRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	rootObj, ok := root.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("schema '%s' must be a JSON object", rootPath)
+	}
+
+	// The root document is referenced by its own JSON pointers, mark it so
+	// references back to it are rewritten to "#"
+	b.keys[absRoot] = ""
+
+	if existing, ok := rootObj[definitionsKey].(map[string]interface{}); ok {
+		for k := range existing {
+			b.defs[k] = existing[k]
+		}
+	}
+
+	walked, err := b.walk(rootObj, absRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	rootObj = walked.(map[string]interface{})
+
+	if len(b.defs) > 0 {
+		rootObj[definitionsKey] = b.defs
+	}
+
+	return json.Marshal(rootObj)
+}
+
+type bundler struct {
+	rootDir string
+
+	// keys maps absolute file paths to their key under definitions
+	keys map[string]string
+
+	// defs contains the bundled documents, keyed by definition name
+	defs map[string]interface{}
+}
+
+func (b *bundler) load(path string) (interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read schema: %s", err)
+	}
+
+	var doc interface{}
+	if err := unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("schema '%s' is not valid JSON: %s", path, err)
+	}
+
+	return doc, nil
+}
+
+// walk rewrites every $ref in v, which was loaded from the file at path
+func (b *bundler) walk(v interface{}, path string) (interface{}, error) {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, child := range val {
+			if k == "$ref" {
+				ref, ok := child.(string)
+				if !ok {
+					continue
+				}
+
+				rewritten, err := b.rewriteRef(ref, path)
+				if err != nil {
+					return nil, err
+				}
+
+				val[k] = rewritten
+				continue
+			}
+
+			walked, err := b.walk(child, path)
+			if err != nil {
+				return nil, err
+			}
+
+			val[k] = walked
+		}
+	case []interface{}:
+		for i, child := range val {
+			walked, err := b.walk(child, path)
+			if err != nil {
+				return nil, err
+			}
+
+			val[i] = walked
+		}
+	}
+
+	return v, nil
+}
+
+// rewriteRef converts a reference found in the file at path into a reference
+// within the bundled document, bundling the referenced file if needed
+func (b *bundler) rewriteRef(ref, path string) (string, error) {
+	file, fragment := ref, ""
+	if i := strings.Index(ref, "#"); i >= 0 {
+		file, fragment = ref[:i], ref[i+1:]
+	}
+
+	if strings.Contains(file, "://") {
+		return "", fmt.Errorf("reference '%s' in '%s' is not a local file, only relative references can be bundled", ref, path)
+	}
+
+	target := path
+	if file != "" {
+		target = filepath.Join(filepath.Dir(path), filepath.FromSlash(file))
+	}
+
+	key, err := b.include(target)
+	if err != nil {
+		return "", fmt.Errorf("unable to resolve reference '%s' in '%s': %s", ref, path, err)
+	}
+
+	if key == "" {
+		return "#" + fragment, nil
+	}
+
+	return "#/" + definitionsKey + "/" + key + fragment, nil
+}
+
+// include bundles the document at path, returning its key under definitions.
+// Documents are only bundled once, which also takes care of circular references.
+func (b *bundler) include(path string) (string, error) {
+	if key, ok := b.keys[path]; ok {
+		return key, nil
+	}
+
+	doc, err := b.load(path)
+	if err != nil {
+		return "", err
+	}
+
+	// Reserve the key in defs before walking so documents included while
+	// walking this one can't be given the same key
+	key := b.uniqueKey(path)
+	b.keys[path] = key
+	b.defs[key] = nil
+
+	// Embedded documents must not change the base URI or declare a draft,
+	// both are determined by the root document
+	if obj, ok := doc.(map[string]interface{}); ok {
+		delete(obj, "$id")
+		delete(obj, "id")
+		delete(obj, "$schema")
+	}
+
+	walked, err := b.walk(doc, path)
+	if err != nil {
+		return "", err
+	}
+
+	b.defs[key] = walked
+
+	return key, nil
+}
+
+// uniqueKey derives a definition name from the path of a file relative to the
+// root schema, ie. "common/address.json" becomes "common_address"
+func (b *bundler) uniqueKey(path string) string {
+	rel, err := filepath.Rel(b.rootDir, path)
+	if err != nil {
+		rel = filepath.Base(path)
+	}
+
+	rel = strings.TrimSuffix(filepath.ToSlash(rel), filepath.Ext(rel))
+	base := strings.Trim(invalidKeyChars.ReplaceAllString(rel, "_"), "_")
+	if base == "" {
+		base = "schema"
+	}
+
+	key := base
+	for i := 2; ; i++ {
+		if _, ok := b.defs[key]; !ok {
+			return key
+		}
+
+		key = fmt.Sprintf("%s_%d", base, i)
+	}
+}
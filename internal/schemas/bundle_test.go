@@ -0,0 +1,140 @@
+package schemas
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/streamdal/streamdal/libs/protos/build/go/protos/steps"
+)
+
+func writeSchemaFiles(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("unable to create directory: %s", err)
+		}
+
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("unable to write %s: %s", name, err)
+		}
+	}
+
+	return dir
+}
+
+func TestBundle(t *testing.T) {
+	dir := writeSchemaFiles(t, map[string]string{
+		"event.json": `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "type": "object",
+  "properties": {
+    "shipping": { "$ref": "./common/address.json" },
+    "billing": { "$ref": "common/address.json" },
+    "street": { "$ref": "./common/address.json#/properties/street" },
+    "parent": { "$ref": "#" }
+  }
+}`,
+		"common/address.json": `{
+  "$id": "https://example.com/address.json",
+  "type": "object",
+  "properties": {
+    "street": { "type": "string" },
+    "country": { "$ref": "../country.json" },
+    "previous": { "$ref": "#" }
+  }
+}`,
+		"country.json": `{"type": "string", "minLength": 2, "maxLength": 2}`,
+	})
+
+	bundled, err := Bundle(filepath.Join(dir, "event.json"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "type": "object",
+  "properties": {
+    "shipping": { "$ref": "#/definitions/common_address" },
+    "billing": { "$ref": "#/definitions/common_address" },
+    "street": { "$ref": "#/definitions/common_address/properties/street" },
+    "parent": { "$ref": "#" }
+  },
+  "definitions": {
+    "common_address": {
+      "type": "object",
+      "properties": {
+        "street": { "type": "string" },
+        "country": { "$ref": "#/definitions/country" },
+        "previous": { "$ref": "#/definitions/common_address" }
+      }
+    },
+    "country": {"type": "string", "minLength": 2, "maxLength": 2}
+  }
+}`
+
+	if !Equal(bundled, []byte(expected)) {
+		t.Fatalf("unexpected bundle: %s", bundled)
+	}
+
+	if err := Validate(bundled, steps.JSONSchemaDraft_JSONSCHEMA_DRAFT_07); err != nil {
+		t.Fatalf("expected bundled schema to be valid, got: %s", err)
+	}
+}
+
+func TestBundle_Errors(t *testing.T) {
+	cases := map[string]map[string]string{
+		"missing file": {
+			"event.json": `{"properties":{"a":{"$ref":"./missing.json"}}}`,
+		},
+		"remote reference": {
+			"event.json": `{"properties":{"a":{"$ref":"https://example.com/schema.json"}}}`,
+		},
+		"invalid referenced file": {
+			"event.json":  `{"properties":{"a":{"$ref":"./common.json"}}}`,
+			"common.json": `{"type":`,
+		},
+		"root not an object": {
+			"event.json": `[]`,
+		},
+	}
+
+	for name, files := range cases {
+		t.Run(name, func(t *testing.T) {
+			dir := writeSchemaFiles(t, files)
+
+			if _, err := Bundle(filepath.Join(dir, "event.json")); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+func TestBundle_KeyCollision(t *testing.T) {
+	dir := writeSchemaFiles(t, map[string]string{
+		"event.json": `{"properties":{"a":{"$ref":"./a-b.json"}}}`,
+		"a-b.json":   `{"properties":{"b":{"$ref":"./a_b.json"}}}`,
+		"a_b.json":   `{"type": "string"}`,
+	})
+
+	bundled, err := Bundle(filepath.Join(dir, "event.json"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := `{
+  "properties": { "a": { "$ref": "#/definitions/a_b" } },
+  "definitions": {
+    "a_b": { "properties": { "b": { "$ref": "#/definitions/a_b_2" } } },
+    "a_b_2": { "type": "string" }
+  }
+}`
+
+	if !Equal(bundled, []byte(expected)) {
+		t.Fatalf("unexpected bundle: %s", bundled)
+	}
+}
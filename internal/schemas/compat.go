@@ -0,0 +1,260 @@
+package schemas
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// maxCompareDepth limits how deep Compare follows nested and recursive schemas
+const maxCompareDepth = 32
+
+// Incompatibility describes a constraint of a schema that is not satisfied by all
+// documents described by another schema
+type Incompatibility struct {
+	// Path is the location of the field in the payload, ie. "user.email"
+	Path string
+
+	Reason string
+}
+
+func (i Incompatibility) String() string {
+	if i.Path == "" {
+		return "payload " + i.Reason
+	}
+
+	return fmt.Sprintf("field '%s' %s", i.Path, i.Reason)
+}
+
+// Compare reports the constraints of candidate that observed does not guarantee,
+// ie. fields candidate requires that are optional in observed, or types candidate
+// doesn't allow that observed does. observed is typically the schema the server
+// inferred from live traffic, so each incompatibility is a way live payloads may
+// fail validation against candidate.
+//
+// Only the keywords used by inferred schemas are compared: type, required,
+// properties, additionalProperties, items and enum.
+func Compare(candidate, observed []byte) ([]Incompatibility, error) {
+	var c, o interface{}
+
+	if err := unmarshal(candidate, &c); err != nil {
+		return nil, fmt.Errorf("schema is not valid JSON: %s", err)
+	}
+
+	if err := unmarshal(observed, &o); err != nil {
+		return nil, fmt.Errorf("observed schema is not valid JSON: %s", err)
+	}
+
+	cmp := &comparer{candidateRoot: c, observedRoot: o}
+	cmp.compare(c, o, "", 0)
+
+	return cmp.found, nil
+}
+
+type comparer struct {
+	candidateRoot interface{}
+	observedRoot  interface{}
+	found         []Incompatibility
+}
+
+func (cmp *comparer) add(path, format string, args ...interface{}) {
+	cmp.found = append(cmp.found, Incompatibility{Path: path, Reason: fmt.Sprintf(format, args...)})
+}
+
+func (cmp *comparer) compare(candidate, observed interface{}, path string, depth int) {
+	if depth > maxCompareDepth {
+		return
+	}
+
+	c := resolveRef(cmp.candidateRoot, candidate)
+	o := resolveRef(cmp.observedRoot, observed)
+
+	if c == nil || o == nil {
+		return
+	}
+
+	cmp.compareTypes(c, o, path)
+	cmp.compareEnum(c, o, path)
+	cmp.compareRequired(c, o, path)
+
+	cProps, _ := c["properties"].(map[string]interface{})
+	oProps, _ := o["properties"].(map[string]interface{})
+
+	for _, name := range sortedKeys(cProps) {
+		if oProp, ok := oProps[name]; ok {
+			cmp.compare(cProps[name], oProp, joinPath(path, name), depth+1)
+		}
+	}
+
+	if additional, ok := c["additionalProperties"].(bool); ok && !additional {
+		if _, hasPatterns := c["patternProperties"]; !hasPatterns {
+			for _, name := range sortedKeys(oProps) {
+				if _, ok := cProps[name]; !ok {
+					cmp.add(joinPath(path, name), "is present in live traffic but not allowed by additionalProperties")
+				}
+			}
+		}
+	}
+
+	if cItems, ok := c["items"].(map[string]interface{}); ok {
+		if oItems, ok := o["items"].(map[string]interface{}); ok {
+			cmp.compare(cItems, oItems, joinPath(path, "#"), depth+1)
+		}
+	}
+}
+
+func (cmp *comparer) compareTypes(c, o map[string]interface{}, path string) {
+	allowed := schemaTypes(c)
+	seen := schemaTypes(o)
+
+	if len(allowed) == 0 || len(seen) == 0 {
+		return
+	}
+
+	for _, t := range seen {
+		if typeAllowed(t, allowed) {
+			continue
+		}
+
+		cmp.add(path, "has type %s in live traffic, but the schema only allows %s", t, strings.Join(allowed, ", "))
+	}
+}
+
+func (cmp *comparer) compareEnum(c, o map[string]interface{}, path string) {
+	allowed, ok := c["enum"].([]interface{})
+	if !ok {
+		return
+	}
+
+	// Without an enum in the observed schema there is no way to tell which
+	// values are flowing, so only compare when both have one
+	seen, ok := o["enum"].([]interface{})
+	if !ok {
+		return
+	}
+
+	for _, v := range seen {
+		found := false
+
+		for _, a := range allowed {
+			if fmt.Sprint(a) == fmt.Sprint(v) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			cmp.add(path, "has value %v in live traffic, which is not in the schema's enum", v)
+		}
+	}
+}
+
+func (cmp *comparer) compareRequired(c, o map[string]interface{}, path string) {
+	cRequired, _ := c["required"].([]interface{})
+	oRequired, _ := o["required"].([]interface{})
+	oProps, _ := o["properties"].(map[string]interface{})
+
+	for _, r := range cRequired {
+		name, _ := r.(string)
+
+		if containsValue(oRequired, name) {
+			continue
+		}
+
+		if _, ok := oProps[name]; ok {
+			cmp.add(joinPath(path, name), "is required by the schema, but is not always present in live traffic")
+		} else {
+			cmp.add(joinPath(path, name), "is required by the schema, but has not been seen in live traffic")
+		}
+	}
+}
+
+// resolveRef follows a local "$ref" such as "#/definitions/address" within root
+func resolveRef(root, schema interface{}) map[string]interface{} {
+	obj, _ := schema.(map[string]interface{})
+
+	for i := 0; i < maxCompareDepth && obj != nil; i++ {
+		ref, ok := obj["$ref"].(string)
+		if !ok || !strings.HasPrefix(ref, "#") {
+			return obj
+		}
+
+		var target interface{} = root
+
+		for _, token := range strings.Split(strings.TrimPrefix(ref, "#"), "/") {
+			if token == "" {
+				continue
+			}
+
+			token = strings.Replace(strings.Replace(token, "~1", "/", -1), "~0", "~", -1)
+
+			m, ok := target.(map[string]interface{})
+			if !ok {
+				return nil
+			}
+
+			target = m[token]
+		}
+
+		obj, _ = target.(map[string]interface{})
+	}
+
+	return obj
+}
+
+func schemaTypes(schema map[string]interface{}) []string {
+	switch t := schema["type"].(type) {
+	case string:
+		return []string{t}
+	case []interface{}:
+		types := make([]string, 0, len(t))
+		for _, v := range t {
+			if s, ok := v.(string); ok {
+				types = append(types, s)
+			}
+		}
+
+		return types
+	}
+
+	return nil
+}
+
+func typeAllowed(t string, allowed []string) bool {
+	for _, a := range allowed {
+		if a == t || (t == "integer" && a == "number") {
+			return true
+		}
+	}
+
+	return false
+}
+
+func containsValue(list []interface{}, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+
+	return path + "." + name
+}
@@ -0,0 +1,83 @@
+package schemas
+
+import (
+	"testing"
+)
+
+func TestCompare(t *testing.T) {
+	observed := `{
+  "type": "object",
+  "required": ["id", "user"],
+  "properties": {
+    "id": { "type": "integer" },
+    "email": { "type": "string" },
+    "status": { "type": "string", "enum": ["active", "disabled"] },
+    "user": {
+      "type": "object",
+      "required": ["name"],
+      "properties": {
+        "name": { "type": "string" },
+        "age": { "type": ["integer", "null"] }
+      }
+    },
+    "tags": { "type": "array", "items": { "type": "string" } }
+  }
+}`
+
+	candidate := `{
+  "type": "object",
+  "required": ["id", "email", "created_at"],
+  "additionalProperties": false,
+  "properties": {
+    "id": { "type": "number" },
+    "email": { "type": "string" },
+    "status": { "enum": ["active"] },
+    "user": { "$ref": "#/definitions/user" },
+    "created_at": { "type": "string" }
+  },
+  "definitions": {
+    "user": {
+      "type": "object",
+      "properties": {
+        "age": { "type": "integer" }
+      }
+    }
+  }
+}`
+
+	found, err := Compare([]byte(candidate), []byte(observed))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := []string{
+		"field 'email' is required by the schema, but is not always present in live traffic",
+		"field 'created_at' is required by the schema, but has not been seen in live traffic",
+		"field 'status' has value disabled in live traffic, which is not in the schema's enum",
+		"field 'user.age' has type null in live traffic, but the schema only allows integer",
+		"field 'tags' is present in live traffic but not allowed by additionalProperties",
+	}
+
+	if len(found) != len(expected) {
+		t.Fatalf("expected %d incompatibilities, got %d: %v", len(expected), len(found), found)
+	}
+
+	for i := range expected {
+		if found[i].String() != expected[i] {
+			t.Errorf("expected '%s', got '%s'", expected[i], found[i])
+		}
+	}
+
+	found, err = Compare([]byte(`{"type":"object"}`), []byte(`{"type":"array"}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if len(found) != 1 || found[0].String() != "payload has type array in live traffic, but the schema only allows object" {
+		t.Fatalf("unexpected incompatibilities: %v", found)
+	}
+
+	if found, _ := Compare([]byte(observed), []byte(observed)); len(found) != 0 {
+		t.Fatalf("expected a schema to be compatible with itself, got: %v", found)
+	}
+}
@@ -0,0 +1,316 @@
+package schemas
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/streamdal/streamdal/libs/protos/build/go/protos/steps"
+)
+
+var (
+	emailRegex = regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`)
+	uuidRegex  = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+)
+
+// formats are the string formats that can be inferred, in order of preference.
+// A format is only used if every sampled value of a field matches it.
+var formats = []struct {
+	name  string
+	match func(string) bool
+}{
+	{"date-time", func(s string) bool { _, err := time.Parse(time.RFC3339Nano, s); return err == nil }},
+	{"date", func(s string) bool { _, err := time.Parse("2006-01-02", s); return err == nil }},
+	{"email", emailRegex.MatchString},
+	{"uuid", uuidRegex.MatchString},
+	{"ipv4", func(s string) bool {
+		ip := net.ParseIP(s)
+		return ip != nil && ip.To4() != nil && strings.Contains(s, ".")
+	}},
+	{"ipv6", func(s string) bool { return net.ParseIP(s) != nil && strings.Contains(s, ":") }},
+	{"uri", func(s string) bool { u, err := url.Parse(s); return err == nil && u.Scheme != "" && u.Host != "" }},
+}
+
+// InferOptions controls how a schema is inferred from sample documents
+type InferOptions struct {
+	Draft steps.JSONSchemaDraft
+
+	// MaxEnumValues is the most distinct values a string field may have to be
+	// considered an enum candidate. Zero disables enum detection.
+	MaxEnumValues int
+
+	// IncludeEnums adds the enum candidates to the schema
+	IncludeEnums bool
+}
+
+// EnumCandidate is a string field which only had a few distinct values in the
+// sample documents
+type EnumCandidate struct {
+	// Path is the location of the field, ie. "user.status"
+	Path   string
+	Values []string
+}
+
+// Infer generates a JSON schema which all of the sample documents satisfy. Types
+// and formats are the union of what was seen, and fields are required if they
+// were present in every sampled object at that location.
+func Infer(docs [][]byte, opts InferOptions) ([]byte, []EnumCandidate, error) {
+	if len(docs) == 0 {
+		return nil, nil, fmt.Errorf("at least one sample document is required")
+	}
+
+	if _, ok := draftURLs[opts.Draft]; !ok {
+		return nil, nil, fmt.Errorf("unsupported JSON schema draft '%s'", opts.Draft)
+	}
+
+	root := newInferNode()
+
+	for i, doc := range docs {
+		var parsed interface{}
+		if err := unmarshal(doc, &parsed); err != nil {
+			return nil, nil, fmt.Errorf("sample document %d is not valid JSON: %s", i, err)
+		}
+
+		root.add(parsed, opts.MaxEnumValues)
+	}
+
+	candidates := make([]EnumCandidate, 0)
+	schema := root.schema("", opts, &candidates)
+	schema["$schema"] = "http://" + draftURLs[opts.Draft] + "#"
+
+	out, err := json.Marshal(schema)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return out, candidates, nil
+}
+
+// inferNode accumulates the values seen at a single location in the documents
+type inferNode struct {
+	types map[string]bool
+
+	// objects is how many objects were seen, presence how many of them had each property
+	objects    int
+	presence   map[string]int
+	properties map[string]*inferNode
+
+	items *inferNode
+
+	// strings counts the distinct string values, up to the enum limit
+	strings      map[string]int
+	tooManyEnums bool
+
+	// formats contains the formats that all string values matched so far
+	formats []string
+	sawStr  bool
+}
+
+func newInferNode() *inferNode {
+	return &inferNode{
+		types:      make(map[string]bool),
+		presence:   make(map[string]int),
+		properties: make(map[string]*inferNode),
+		strings:    make(map[string]int),
+	}
+}
+
+func (n *inferNode) add(v interface{}, maxEnum int) {
+	switch val := v.(type) {
+	case nil:
+		n.types["null"] = true
+	case bool:
+		n.types["boolean"] = true
+	case json.Number:
+		if _, err := val.Int64(); err == nil && !strings.ContainsAny(val.String(), ".eE") {
+			n.types["integer"] = true
+		} else {
+			n.types["number"] = true
+		}
+	case string:
+		n.types["string"] = true
+		n.addString(val, maxEnum)
+	case []interface{}:
+		n.types["array"] = true
+		if n.items == nil {
+			n.items = newInferNode()
+		}
+
+		for _, item := range val {
+			n.items.add(item, maxEnum)
+		}
+	case map[string]interface{}:
+		n.types["object"] = true
+		n.objects++
+
+		for k, child := range val {
+			if _, ok := n.properties[k]; !ok {
+				n.properties[k] = newInferNode()
+			}
+
+			n.presence[k]++
+			n.properties[k].add(child, maxEnum)
+		}
+	}
+}
+
+func (n *inferNode) addString(s string, maxEnum int) {
+	if !n.sawStr {
+		n.sawStr = true
+		for _, f := range formats {
+			n.formats = append(n.formats, f.name)
+		}
+	}
+
+	matching := n.formats[:0]
+	for _, name := range n.formats {
+		for _, f := range formats {
+			if f.name == name && f.match(s) {
+				matching = append(matching, name)
+			}
+		}
+	}
+	n.formats = matching
+
+	if maxEnum <= 0 || n.tooManyEnums {
+		return
+	}
+
+	n.strings[s]++
+	if len(n.strings) > maxEnum {
+		n.tooManyEnums = true
+		n.strings = nil
+	}
+}
+
+func (n *inferNode) schema(path string, opts InferOptions, candidates *[]EnumCandidate) map[string]interface{} {
+	schema := map[string]interface{}{}
+
+	types := make([]string, 0, len(n.types))
+	for t := range n.types {
+		// Integers are also numbers
+		if t == "integer" && n.types["number"] {
+			continue
+		}
+
+		types = append(types, t)
+	}
+
+	sort.Strings(types)
+
+	switch len(types) {
+	case 0:
+		// Only seen in empty arrays, anything goes
+	case 1:
+		schema["type"] = types[0]
+	default:
+		list := make([]interface{}, len(types))
+		for i, t := range types {
+			list[i] = t
+		}
+
+		schema["type"] = list
+	}
+
+	if n.types["string"] {
+		if len(n.formats) > 0 {
+			schema["format"] = n.formats[0]
+		}
+
+		if values := n.enumValues(); values != nil {
+			*candidates = append(*candidates, EnumCandidate{Path: path, Values: values})
+
+			// Enums must list every allowed value, so only use them for fields which
+			// are always strings
+			if opts.IncludeEnums && len(types) == 1 {
+				enum := make([]interface{}, len(values))
+				for i, v := range values {
+					enum[i] = v
+				}
+
+				schema["enum"] = enum
+			}
+		}
+	}
+
+	if n.types["object"] {
+		properties := map[string]interface{}{}
+		required := make([]interface{}, 0)
+
+		for _, name := range sortedNodeKeys(n.properties) {
+			properties[name] = n.properties[name].schema(joinPath(path, escapePathKey(name)), opts, candidates)
+
+			if n.presence[name] == n.objects {
+				required = append(required, name)
+			}
+		}
+
+		schema["properties"] = properties
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+	}
+
+	if n.items != nil && len(n.items.types) > 0 {
+		schema["items"] = n.items.schema(joinPath(path, "#"), opts, candidates)
+	}
+
+	return schema
+}
+
+// enumValues returns the distinct values of a string field if it looks like an
+// enum: a small number of values which repeat across the samples
+func (n *inferNode) enumValues() []string {
+	if n.tooManyEnums || len(n.strings) == 0 {
+		return nil
+	}
+
+	total := 0
+	for _, count := range n.strings {
+		total += count
+	}
+
+	if total <= len(n.strings) {
+		return nil
+	}
+
+	values := make([]string, 0, len(n.strings))
+	for v := range n.strings {
+		values = append(values, v)
+	}
+
+	sort.Strings(values)
+
+	return values
+}
+
+func sortedNodeKeys(m map[string]*inferNode) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+// escapePathKey escapes the characters which have a special meaning in a path
+func escapePathKey(key string) string {
+	var b strings.Builder
+
+	for _, c := range key {
+		if strings.ContainsRune(`.|#@*?!\[]{}()`, c) {
+			b.WriteRune('\\')
+		}
+
+		b.WriteRune(c)
+	}
+
+	return b.String()
+}
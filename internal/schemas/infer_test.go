@@ -0,0 +1,81 @@
+package schemas
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+
+	"github.com/streamdal/streamdal/libs/protos/build/go/protos/steps"
+)
+
+func TestInfer(t *testing.T) {
+	docs := [][]byte{
+		[]byte(`{"id": 1, "email": "a@example.com", "status": "active", "created_at": "2024-01-02T03:04:05Z", "tags": ["x"], "score": 1}`),
+		[]byte(`{"id": 2, "email": "b@example.com", "status": "disabled", "created_at": "2024-01-03T03:04:05Z", "tags": [], "score": 1.5, "user.name": null}`),
+		[]byte(`{"id": 3, "email": "c@example.com", "status": "active", "created_at": "2024-01-04T03:04:05Z", "tags": ["y", "z"]}`),
+	}
+
+	doc, candidates, err := Infer(docs, InferOptions{
+		Draft:         steps.JSONSchemaDraft_JSONSCHEMA_DRAFT_07,
+		MaxEnumValues: 3,
+		IncludeEnums:  true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "type": "object",
+  "required": ["created_at", "email", "id", "status", "tags"],
+  "properties": {
+    "id": { "type": "integer" },
+    "email": { "type": "string", "format": "email" },
+    "status": { "type": "string", "enum": ["active", "disabled"] },
+    "created_at": { "type": "string", "format": "date-time" },
+    "tags": { "type": "array", "items": { "type": "string" } },
+    "score": { "type": "number" },
+    "user.name": { "type": "null" }
+  }
+}`
+
+	if !Equal(doc, []byte(expected)) {
+		t.Fatalf("unexpected schema: %s", doc)
+	}
+
+	expectedCandidates := []EnumCandidate{{Path: "status", Values: []string{"active", "disabled"}}}
+	if !reflect.DeepEqual(candidates, expectedCandidates) {
+		t.Fatalf("unexpected enum candidates: %v", candidates)
+	}
+
+	if err := Validate(doc, steps.JSONSchemaDraft_JSONSCHEMA_DRAFT_07); err != nil {
+		t.Fatalf("expected inferred schema to be valid, got: %s", err)
+	}
+
+	// Every sample must satisfy the inferred schema
+	compiled, err := jsonschema.CompileString("inferred.json", string(doc))
+	if err != nil {
+		t.Fatalf("unable to compile inferred schema: %s", err)
+	}
+
+	for i, sample := range docs {
+		var v interface{}
+		if err := json.Unmarshal(sample, &v); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		if err := compiled.Validate(v); err != nil {
+			t.Fatalf("sample %d does not satisfy the inferred schema: %s", i, err)
+		}
+	}
+
+	if _, _, err := Infer([][]byte{[]byte(`{`)}, InferOptions{Draft: steps.JSONSchemaDraft_JSONSCHEMA_DRAFT_07}); err == nil {
+		t.Fatal("expected an error for invalid JSON")
+	}
+
+	if _, _, err := Infer(nil, InferOptions{Draft: steps.JSONSchemaDraft_JSONSCHEMA_DRAFT_07}); err == nil {
+		t.Fatal("expected an error without documents")
+	}
+}
@@ -0,0 +1,152 @@
+// Package schemas contains helpers for working with the JSON Schema documents used
+// by schema_validation pipeline steps.
+package schemas
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+
+	"github.com/streamdal/streamdal/libs/protos/build/go/protos/steps"
+)
+
+// rootURL is the URL the schema document is registered under when compiling it.
+// It uses a scheme that can't be loaded so that the compiler never tries to
+// resolve references from the local filesystem or network.
+const rootURL = "streamdal:///schema.json"
+
+// drafts maps the drafts supported by the Streamdal Wasm modules to the
+// metaschema used to validate a schema document
+var drafts = map[steps.JSONSchemaDraft]*jsonschema.Draft{
+	steps.JSONSchemaDraft_JSONSCHEMA_DRAFT_04: jsonschema.Draft4,
+	steps.JSONSchemaDraft_JSONSCHEMA_DRAFT_06: jsonschema.Draft6,
+	steps.JSONSchemaDraft_JSONSCHEMA_DRAFT_07: jsonschema.Draft7,
+}
+
+// draftURLs contains the $schema values that identify each draft
+var draftURLs = map[steps.JSONSchemaDraft]string{
+	steps.JSONSchemaDraft_JSONSCHEMA_DRAFT_04: "json-schema.org/draft-04/schema",
+	steps.JSONSchemaDraft_JSONSCHEMA_DRAFT_06: "json-schema.org/draft-06/schema",
+	steps.JSONSchemaDraft_JSONSCHEMA_DRAFT_07: "json-schema.org/draft-07/schema",
+}
+
+// Validate checks that doc is a valid JSON Schema document for the given draft.
+// References to external documents are not resolved and result in an error, since
+// the SDKs are not able to resolve them either.
+func Validate(doc []byte, draft steps.JSONSchemaDraft) error {
+	d, ok := drafts[draft]
+	if !ok {
+		return fmt.Errorf("unsupported JSON schema draft '%s'", draft)
+	}
+
+	var parsed interface{}
+	if err := unmarshal(doc, &parsed); err != nil {
+		return fmt.Errorf("schema is not valid JSON: %s", err)
+	}
+
+	if obj, ok := parsed.(map[string]interface{}); ok {
+		if declared, ok := obj["$schema"].(string); ok && !strings.Contains(declared, draftURLs[draft]) {
+			return fmt.Errorf("schema declares '$schema: %s' which does not match the selected draft '%s'", declared, draft)
+		}
+	}
+
+	c := jsonschema.NewCompiler()
+	c.Draft = d
+	c.LoadURL = func(url string) (io.ReadCloser, error) {
+		return nil, fmt.Errorf("external reference '%s' can not be resolved, schemas must be self-contained", url)
+	}
+
+	if err := c.AddResource(rootURL, bytes.NewReader(doc)); err != nil {
+		return fmt.Errorf("unable to load schema: %s", err)
+	}
+
+	if _, err := c.Compile(rootURL); err != nil {
+		return fmt.Errorf("invalid %s schema: %s", draft, strings.Replace(err.Error(), rootURL, "schema", -1))
+	}
+
+	return nil
+}
+
+// DetectDraft returns the draft declared by the $schema keyword of doc. Documents
+// which don't declare a supported draft are assumed to be draft 7.
+func DetectDraft(doc []byte) steps.JSONSchemaDraft {
+	var parsed interface{}
+	if err := unmarshal(doc, &parsed); err != nil {
+		return steps.JSONSchemaDraft_JSONSCHEMA_DRAFT_07
+	}
+
+	obj, _ := parsed.(map[string]interface{})
+	declared, _ := obj["$schema"].(string)
+
+	for draft, url := range draftURLs {
+		if declared != "" && strings.Contains(declared, url) {
+			return draft
+		}
+	}
+
+	return steps.JSONSchemaDraft_JSONSCHEMA_DRAFT_07
+}
+
+// Normalize returns a canonical encoding of a JSON document: no insignificant
+// whitespace and object keys in sorted order. Numbers are preserved as-is.
+func Normalize(doc []byte) ([]byte, error) {
+	var parsed interface{}
+	if err := unmarshal(doc, &parsed); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(parsed)
+}
+
+// Equal determines if two JSON documents are semantically equal, ignoring
+// formatting and the order of object keys
+func Equal(a, b []byte) bool {
+	var parsedA, parsedB interface{}
+
+	if err := unmarshal(a, &parsedA); err != nil {
+		return false
+	}
+
+	if err := unmarshal(b, &parsedB); err != nil {
+		return false
+	}
+
+	return reflect.DeepEqual(parsedA, parsedB)
+}
+
+// Hash returns a hash of the canonical encoding of doc, used to detect changes
+// to schemas which are generated from local files
+func Hash(doc []byte) (string, error) {
+	normalized, err := Normalize(doc)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(normalized)
+
+	return "sha256:" + hex.EncodeToString(sum[:]), nil
+}
+
+// unmarshal decodes doc into v, keeping numbers as json.Number so that large
+// integers are not mangled by a round trip through float64
+func unmarshal(doc []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(doc))
+	dec.UseNumber()
+
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+
+	if dec.More() {
+		return fmt.Errorf("unexpected data after JSON document")
+	}
+
+	return nil
+}
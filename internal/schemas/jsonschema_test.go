@@ -0,0 +1,120 @@
+package schemas
+
+import (
+	"testing"
+
+	"github.com/streamdal/streamdal/libs/protos/build/go/protos/steps"
+)
+
+func TestValidate(t *testing.T) {
+	cases := map[string]struct {
+		doc   string
+		draft steps.JSONSchemaDraft
+		valid bool
+	}{
+		"valid draft 7": {
+			doc:   `{"type":"object","properties":{"email":{"type":"string"}},"required":["email"]}`,
+			draft: steps.JSONSchemaDraft_JSONSCHEMA_DRAFT_07,
+			valid: true,
+		},
+		"matching $schema": {
+			doc:   `{"$schema":"http://json-schema.org/draft-04/schema#","type":"object"}`,
+			draft: steps.JSONSchemaDraft_JSONSCHEMA_DRAFT_04,
+			valid: true,
+		},
+		"mismatched $schema": {
+			doc:   `{"$schema":"http://json-schema.org/draft-04/schema#","type":"object"}`,
+			draft: steps.JSONSchemaDraft_JSONSCHEMA_DRAFT_07,
+		},
+		"invalid type": {
+			doc:   `{"type":"strng"}`,
+			draft: steps.JSONSchemaDraft_JSONSCHEMA_DRAFT_07,
+		},
+		"required must be an array": {
+			doc:   `{"type":"object","required":"email"}`,
+			draft: steps.JSONSchemaDraft_JSONSCHEMA_DRAFT_06,
+		},
+		"draft 4 boolean exclusiveMinimum": {
+			doc:   `{"type":"number","minimum":1,"exclusiveMinimum":true}`,
+			draft: steps.JSONSchemaDraft_JSONSCHEMA_DRAFT_04,
+			valid: true,
+		},
+		"draft 7 boolean exclusiveMinimum": {
+			doc:   `{"type":"number","minimum":1,"exclusiveMinimum":true}`,
+			draft: steps.JSONSchemaDraft_JSONSCHEMA_DRAFT_07,
+		},
+		"external reference": {
+			doc:   `{"$ref":"./common/address.json"}`,
+			draft: steps.JSONSchemaDraft_JSONSCHEMA_DRAFT_07,
+		},
+		"internal reference": {
+			doc:   `{"definitions":{"id":{"type":"string"}},"properties":{"id":{"$ref":"#/definitions/id"}}}`,
+			draft: steps.JSONSchemaDraft_JSONSCHEMA_DRAFT_07,
+			valid: true,
+		},
+		"not json": {
+			doc:   `{"type":`,
+			draft: steps.JSONSchemaDraft_JSONSCHEMA_DRAFT_07,
+		},
+		"unknown draft": {
+			doc:   `{}`,
+			draft: steps.JSONSchemaDraft_JSONSCHEMA_DRAFT_UNKNOWN,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := Validate([]byte(tc.doc), tc.draft)
+			if tc.valid && err != nil {
+				t.Fatalf("expected schema to be valid, got: %s", err)
+			}
+
+			if !tc.valid && err == nil {
+				t.Fatal("expected schema to be invalid")
+			}
+		})
+	}
+}
+
+func TestEqual(t *testing.T) {
+	heredoc := `{
+  "type": "object",
+  "required": ["id"],
+  "properties": {
+    "id": { "type": "integer", "maximum": 9007199254740993 }
+  }
+}`
+	jsonencoded := `{"properties":{"id":{"maximum":9007199254740993,"type":"integer"}},"required":["id"],"type":"object"}`
+
+	if !Equal([]byte(heredoc), []byte(jsonencoded)) {
+		t.Fatal("expected documents to be equal")
+	}
+
+	if Equal([]byte(heredoc), []byte(`{"type":"object","required":["id","name"]}`)) {
+		t.Fatal("expected documents to differ")
+	}
+
+	normalized, err := Normalize([]byte(heredoc))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if string(normalized) != jsonencoded {
+		t.Fatalf("unexpected normalized document: %s", normalized)
+	}
+}
+
+func TestDetectDraft(t *testing.T) {
+	cases := map[string]steps.JSONSchemaDraft{
+		`{"$schema":"http://json-schema.org/draft-04/schema#"}`:      steps.JSONSchemaDraft_JSONSCHEMA_DRAFT_04,
+		`{"$schema":"http://json-schema.org/draft-06/schema#"}`:      steps.JSONSchemaDraft_JSONSCHEMA_DRAFT_06,
+		`{"$schema":"https://json-schema.org/draft/2020-12/schema"}`: steps.JSONSchemaDraft_JSONSCHEMA_DRAFT_07,
+		`{"type":"object"}`: steps.JSONSchemaDraft_JSONSCHEMA_DRAFT_07,
+	}
+
+	for doc, expected := range cases {
+		if got := DetectDraft([]byte(doc)); got != expected {
+			t.Errorf("%s: expected %s, got %s", doc, expected, got)
+		}
+	}
+}
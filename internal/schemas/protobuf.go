@@ -0,0 +1,265 @@
+package schemas
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// FromProtobuf converts a message from a protobuf descriptor set, as generated by
+// `protoc --include_imports --descriptor_set_out`, into a JSON schema describing the
+// message's canonical JSON encoding. Field names use their JSON name (lowerCamelCase)
+// unless useProtoNames is set, matching protojson's UseProtoNames option.
+func FromProtobuf(descriptorSetPath, message string, useProtoNames bool) ([]byte, error) {
+	data, err := os.ReadFile(descriptorSetPath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read descriptor set: %s", err)
+	}
+
+	fds := &descriptorpb.FileDescriptorSet{}
+	if err := proto.Unmarshal(data, fds); err != nil {
+		return nil, fmt.Errorf("'%s' is not a protobuf descriptor set: %s", descriptorSetPath, err)
+	}
+
+	files, err := protodesc.NewFiles(fds)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load descriptor set, make sure it was generated with --include_imports: %s", err)
+	}
+
+	desc, err := files.FindDescriptorByName(protoreflect.FullName(strings.TrimPrefix(message, ".")))
+	if err != nil {
+		return nil, fmt.Errorf("message '%s' not found in descriptor set", message)
+	}
+
+	md, ok := desc.(protoreflect.MessageDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("'%s' is not a message", message)
+	}
+
+	c := &protoConverter{
+		root:          md.FullName(),
+		useProtoNames: useProtoNames,
+		defs:          make(map[string]interface{}),
+	}
+
+	root, ok := wellKnownSchema(md)
+	if !ok {
+		root = c.object(md)
+	}
+
+	if len(c.defs) > 0 {
+		root[definitionsKey] = c.defs
+	}
+
+	return json.Marshal(root)
+}
+
+type protoConverter struct {
+	// root is the message the schema is generated for, references to it point
+	// to the root of the document
+	root protoreflect.FullName
+
+	useProtoNames bool
+
+	// defs contains the schemas of all other messages, keyed by full name
+	defs map[string]interface{}
+}
+
+// object returns the schema for the fields of a message
+func (c *protoConverter) object(md protoreflect.MessageDescriptor) map[string]interface{} {
+	properties := map[string]interface{}{}
+	required := make([]interface{}, 0)
+
+	fields := md.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+
+		name := fd.JSONName()
+		if c.useProtoNames {
+			name = string(fd.Name())
+		}
+
+		properties[name] = c.field(fd)
+
+		if fd.Cardinality() == protoreflect.Required {
+			required = append(required, name)
+		}
+	}
+
+	schema := map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+
+	// At most one field of a oneof may be set
+	exclusive := make([]interface{}, 0)
+
+	oneofs := md.Oneofs()
+	for i := 0; i < oneofs.Len(); i++ {
+		od := oneofs.Get(i)
+		if od.IsSynthetic() {
+			continue
+		}
+
+		for a := 0; a < od.Fields().Len(); a++ {
+			for b := a + 1; b < od.Fields().Len(); b++ {
+				exclusive = append(exclusive, map[string]interface{}{
+					"required": []interface{}{c.fieldName(od.Fields().Get(a)), c.fieldName(od.Fields().Get(b))},
+				})
+			}
+		}
+	}
+
+	if len(exclusive) > 0 {
+		schema["not"] = map[string]interface{}{"anyOf": exclusive}
+	}
+
+	return schema
+}
+
+func (c *protoConverter) fieldName(fd protoreflect.FieldDescriptor) string {
+	if c.useProtoNames {
+		return string(fd.Name())
+	}
+
+	return fd.JSONName()
+}
+
+func (c *protoConverter) field(fd protoreflect.FieldDescriptor) map[string]interface{} {
+	if fd.IsMap() {
+		return map[string]interface{}{
+			"type":                 "object",
+			"additionalProperties": c.singular(fd.MapValue()),
+		}
+	}
+
+	if fd.IsList() {
+		return map[string]interface{}{
+			"type":  "array",
+			"items": c.singular(fd),
+		}
+	}
+
+	return c.singular(fd)
+}
+
+// singular returns the schema for a single value of a field
+func (c *protoConverter) singular(fd protoreflect.FieldDescriptor) map[string]interface{} {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		return map[string]interface{}{"type": "boolean"}
+	case protoreflect.StringKind, protoreflect.BytesKind:
+		return map[string]interface{}{"type": "string"}
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return map[string]interface{}{"type": "integer", "minimum": math.MinInt32, "maximum": math.MaxInt32}
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return map[string]interface{}{"type": "integer", "minimum": 0, "maximum": uint32(math.MaxUint32)}
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind,
+		protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		// 64 bit integers are encoded as strings to avoid losing precision
+		return map[string]interface{}{"type": []interface{}{"integer", "string"}, "pattern": "^-?[0-9]+$"}
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		return map[string]interface{}{
+			"anyOf": []interface{}{
+				map[string]interface{}{"type": "number"},
+				map[string]interface{}{"enum": []interface{}{"NaN", "Infinity", "-Infinity"}},
+			},
+		}
+	case protoreflect.EnumKind:
+		return enumSchema(fd.Enum())
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return c.message(fd.Message())
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+// message returns a reference to the schema of a message, adding it to the
+// definitions if it hasn't been converted yet
+func (c *protoConverter) message(md protoreflect.MessageDescriptor) map[string]interface{} {
+	if schema, ok := wellKnownSchema(md); ok {
+		return schema
+	}
+
+	if md.FullName() == c.root {
+		return map[string]interface{}{"$ref": "#"}
+	}
+
+	name := string(md.FullName())
+	ref := map[string]interface{}{"$ref": "#/" + definitionsKey + "/" + name}
+
+	if _, ok := c.defs[name]; ok {
+		return ref
+	}
+
+	// Reserve the name first so that recursive messages refer to it
+	c.defs[name] = map[string]interface{}{}
+	c.defs[name] = c.object(md)
+
+	return ref
+}
+
+func enumSchema(ed protoreflect.EnumDescriptor) map[string]interface{} {
+	if ed.FullName() == "google.protobuf.NullValue" {
+		return map[string]interface{}{"type": "null"}
+	}
+
+	// Enums are encoded by name, but their numbers are accepted as well
+	names := make([]interface{}, 0)
+	numbers := make([]interface{}, 0)
+
+	values := ed.Values()
+	for i := 0; i < values.Len(); i++ {
+		names = append(names, string(values.Get(i).Name()))
+		numbers = append(numbers, int32(values.Get(i).Number()))
+	}
+
+	return map[string]interface{}{"enum": append(names, numbers...)}
+}
+
+// wellKnownSchema returns the schema for the well known types which have a
+// special JSON encoding
+func wellKnownSchema(md protoreflect.MessageDescriptor) (map[string]interface{}, bool) {
+	switch md.FullName() {
+	case "google.protobuf.Timestamp":
+		return map[string]interface{}{"type": "string", "format": "date-time"}, true
+	case "google.protobuf.Duration":
+		return map[string]interface{}{"type": "string", "pattern": `^-?[0-9]+(\.[0-9]{1,9})?s$`}, true
+	case "google.protobuf.FieldMask":
+		return map[string]interface{}{"type": "string"}, true
+	case "google.protobuf.Struct":
+		return map[string]interface{}{"type": "object"}, true
+	case "google.protobuf.ListValue":
+		return map[string]interface{}{"type": "array"}, true
+	case "google.protobuf.Value":
+		return map[string]interface{}{}, true
+	case "google.protobuf.Empty":
+		return map[string]interface{}{"type": "object", "maxProperties": 0}, true
+	case "google.protobuf.Any":
+		return map[string]interface{}{
+			"type":       "object",
+			"required":   []interface{}{"@type"},
+			"properties": map[string]interface{}{"@type": map[string]interface{}{"type": "string"}},
+		}, true
+	case "google.protobuf.DoubleValue", "google.protobuf.FloatValue",
+		"google.protobuf.Int64Value", "google.protobuf.UInt64Value",
+		"google.protobuf.Int32Value", "google.protobuf.UInt32Value",
+		"google.protobuf.BoolValue", "google.protobuf.StringValue", "google.protobuf.BytesValue":
+		// Wrappers are encoded as the value they wrap
+		c := &protoConverter{}
+		return c.singular(md.Fields().ByName("value")), true
+	}
+
+	return nil, false
+}
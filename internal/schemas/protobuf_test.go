@@ -0,0 +1,164 @@
+package schemas
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/streamdal/streamdal/libs/protos/build/go/protos/steps"
+)
+
+func writeDescriptorSet(t *testing.T) string {
+	field := func(name string, number int32, typ descriptorpb.FieldDescriptorProto_Type, typeName string) *descriptorpb.FieldDescriptorProto {
+		f := &descriptorpb.FieldDescriptorProto{
+			Name:   proto.String(name),
+			Number: proto.Int32(number),
+			Type:   typ.Enum(),
+			Label:  descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		}
+
+		if typeName != "" {
+			f.TypeName = proto.String(typeName)
+		}
+
+		return f
+	}
+
+	tags := field("tags", 5, descriptorpb.FieldDescriptorProto_TYPE_STRING, "")
+	tags.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+
+	email := field("email", 6, descriptorpb.FieldDescriptorProto_TYPE_STRING, "")
+	email.OneofIndex = proto.Int32(0)
+
+	phone := field("phone", 7, descriptorpb.FieldDescriptorProto_TYPE_STRING, "")
+	phone.OneofIndex = proto.Int32(0)
+
+	file := &descriptorpb.FileDescriptorProto{
+		Name:       proto.String("events/user.proto"),
+		Package:    proto.String("events"),
+		Syntax:     proto.String("proto3"),
+		Dependency: []string{"google/protobuf/timestamp.proto"},
+		EnumType: []*descriptorpb.EnumDescriptorProto{
+			{
+				Name: proto.String("Status"),
+				Value: []*descriptorpb.EnumValueDescriptorProto{
+					{Name: proto.String("STATUS_UNSPECIFIED"), Number: proto.Int32(0)},
+					{Name: proto.String("STATUS_ACTIVE"), Number: proto.Int32(1)},
+				},
+			},
+		},
+		MessageType: []*descriptorpb.DescriptorProto{
+			{
+				Name: proto.String("User"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("user_id", 1, descriptorpb.FieldDescriptorProto_TYPE_INT64, ""),
+					field("status", 2, descriptorpb.FieldDescriptorProto_TYPE_ENUM, ".events.Status"),
+					field("created_at", 3, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, ".google.protobuf.Timestamp"),
+					field("manager", 4, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, ".events.User"),
+					tags,
+					email,
+					phone,
+					field("address", 8, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, ".events.Address"),
+				},
+				OneofDecl: []*descriptorpb.OneofDescriptorProto{
+					{Name: proto.String("contact")},
+				},
+			},
+			{
+				Name: proto.String("Address"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("street", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING, ""),
+				},
+			},
+		},
+	}
+
+	fds := &descriptorpb.FileDescriptorSet{
+		File: []*descriptorpb.FileDescriptorProto{
+			protodesc.ToFileDescriptorProto(timestamppb.File_google_protobuf_timestamp_proto),
+			file,
+		},
+	}
+
+	data, err := proto.Marshal(fds)
+	if err != nil {
+		t.Fatalf("unable to marshal descriptor set: %s", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "events.pb")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatalf("unable to write descriptor set: %s", err)
+	}
+
+	return path
+}
+
+func TestFromProtobuf(t *testing.T) {
+	path := writeDescriptorSet(t)
+
+	doc, err := FromProtobuf(path, "events.User", false)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := `{
+  "type": "object",
+  "properties": {
+    "userId": { "type": ["integer", "string"], "pattern": "^-?[0-9]+$" },
+    "status": { "enum": ["STATUS_UNSPECIFIED", "STATUS_ACTIVE", 0, 1] },
+    "createdAt": { "type": "string", "format": "date-time" },
+    "manager": { "$ref": "#" },
+    "tags": { "type": "array", "items": { "type": "string" } },
+    "email": { "type": "string" },
+    "phone": { "type": "string" },
+    "address": { "$ref": "#/definitions/events.Address" }
+  },
+  "not": { "anyOf": [{ "required": ["email", "phone"] }] },
+  "definitions": {
+    "events.Address": {
+      "type": "object",
+      "properties": { "street": { "type": "string" } }
+    }
+  }
+}`
+
+	if !Equal(doc, []byte(expected)) {
+		t.Fatalf("unexpected schema: %s", doc)
+	}
+
+	for _, draft := range []steps.JSONSchemaDraft{
+		steps.JSONSchemaDraft_JSONSCHEMA_DRAFT_04,
+		steps.JSONSchemaDraft_JSONSCHEMA_DRAFT_07,
+	} {
+		if err := Validate(doc, draft); err != nil {
+			t.Fatalf("expected generated schema to be valid for %s, got: %s", draft, err)
+		}
+	}
+
+	doc, err = FromProtobuf(path, "events.User", true)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	var parsed map[string]interface{}
+	if err := unmarshal(doc, &parsed); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if _, ok := parsed["properties"].(map[string]interface{})["user_id"]; !ok {
+		t.Fatalf("expected proto field names, got: %s", doc)
+	}
+
+	if _, err := FromProtobuf(path, "events.Missing", false); err == nil {
+		t.Fatal("expected an error for an unknown message")
+	}
+
+	if _, err := FromProtobuf(path, "events.Status", false); err == nil {
+		t.Fatal("expected an error for a non-message type")
+	}
+}
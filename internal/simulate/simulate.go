@@ -0,0 +1,419 @@
+// Package simulate runs pipelines against sample payloads using the same Wasm
+// modules that the Streamdal SDKs execute, without sending any data to an SDK.
+package simulate
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/streamdal/streamdal/libs/protos/build/go/protos"
+	"github.com/streamdal/streamdal/libs/protos/build/go/protos/steps"
+)
+
+const (
+	// defaultFunction is the entry point of the Streamdal Wasm modules when
+	// the step does not specify one
+	defaultFunction = "f"
+
+	allocFunction   = "alloc"
+	deallocFunction = "dealloc"
+
+	hostModule = "env"
+
+	// simulationMessage is returned to modules which call out to the network or KV
+	// store, neither of which are available during a simulation
+	simulationMessage = "not available during simulation"
+)
+
+// Step results, matching the WASMExitCode a step finished with
+const (
+	ResultTrue    = "true"
+	ResultFalse   = "false"
+	ResultError   = "error"
+	ResultSkipped = "skipped"
+)
+
+// StepResult is the outcome of running a single step against a payload
+type StepResult struct {
+	Name string
+
+	// Result is one of ResultTrue, ResultFalse, ResultError or ResultSkipped
+	Result string
+
+	// Message is the exit message of the Wasm module
+	Message string
+
+	// Abort, Notify and Metadata are taken from the condition matching Result
+	Abort    protos.AbortCondition
+	Notify   bool
+	Metadata map[string]string
+
+	// Output is the payload after the step ran. Steps which error leave the
+	// payload unchanged.
+	Output []byte
+}
+
+// Result is the outcome of running a pipeline against a payload
+type Result struct {
+	Input  []byte
+	Output []byte
+
+	// Aborted is set when a step condition stopped the pipeline
+	Aborted bool
+
+	Steps []StepResult
+}
+
+// Simulator compiles the Wasm modules of pipeline steps and runs payloads through them
+type Simulator struct {
+	runtime wazero.Runtime
+
+	// modules are the instantiated Wasm modules, keyed by the hash of their bytes
+	modules map[string]api.Module
+}
+
+// New compiles and instantiates the Wasm modules of the given steps. Every step
+// must have XWasmBytes set. Close must be called to release the runtime.
+func New(ctx context.Context, pipelineSteps []*protos.PipelineStep) (*Simulator, error) {
+	s := &Simulator{
+		runtime: wazero.NewRuntime(ctx),
+		modules: make(map[string]api.Module),
+	}
+
+	if err := s.init(ctx, pipelineSteps); err != nil {
+		_ = s.Close(ctx)
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *Simulator) init(ctx context.Context, pipelineSteps []*protos.PipelineStep) error {
+	if _, err := wasi_snapshot_preview1.Instantiate(ctx, s.runtime); err != nil {
+		return fmt.Errorf("unable to instantiate WASI: %s", err)
+	}
+
+	compiled := make(map[string]wazero.CompiledModule)
+	order := make([]string, 0)
+
+	for _, step := range pipelineSteps {
+		if len(step.GetXWasmBytes()) == 0 {
+			return fmt.Errorf("step '%s' has no Wasm module", step.GetName())
+		}
+
+		key := wasmKey(step.GetXWasmBytes())
+		if _, ok := compiled[key]; ok {
+			continue
+		}
+
+		mod, err := s.runtime.CompileModule(ctx, step.GetXWasmBytes())
+		if err != nil {
+			return fmt.Errorf("unable to compile Wasm module for step '%s': %s", step.GetName(), err)
+		}
+
+		compiled[key] = mod
+		order = append(order, key)
+	}
+
+	if err := s.instantiateHostModule(ctx, compiled); err != nil {
+		return err
+	}
+
+	for _, key := range order {
+		// Modules are anonymous so that the same module name may be used by several steps
+		cfg := wazero.NewModuleConfig().WithName("").WithStartFunctions("_initialize")
+
+		mod, err := s.runtime.InstantiateModule(ctx, compiled[key], cfg)
+		if err != nil {
+			return fmt.Errorf("unable to instantiate Wasm module: %s", err)
+		}
+
+		if mod.ExportedFunction(allocFunction) == nil {
+			return fmt.Errorf("module does not export an '%s' function", allocFunction)
+		}
+
+		s.modules[key] = mod
+	}
+
+	return nil
+}
+
+// instantiateHostModule provides the host functions imported by the modules. HTTP
+// requests and KV lookups get a failure response, any other import fails the step
+// that calls it.
+func (s *Simulator) instantiateHostModule(ctx context.Context, compiled map[string]wazero.CompiledModule) error {
+	builder := s.runtime.NewHostModuleBuilder(hostModule)
+	exported := make(map[string]bool)
+
+	for _, mod := range compiled {
+		for _, fn := range mod.ImportedFunctions() {
+			moduleName, name, _ := fn.Import()
+			if moduleName != hostModule || exported[name] {
+				continue
+			}
+
+			exported[name] = true
+
+			builder.NewFunctionBuilder().
+				WithGoModuleFunction(hostFunction(name, fn), fn.ParamTypes(), fn.ResultTypes()).
+				Export(name)
+		}
+	}
+
+	if _, err := builder.Instantiate(ctx); err != nil {
+		return fmt.Errorf("unable to instantiate host functions: %s", err)
+	}
+
+	return nil
+}
+
+func hostFunction(name string, fn api.FunctionDefinition) api.GoModuleFunc {
+	// Host functions exchange protobuf messages by passing a pointer and length
+	// and returning them packed into an i64
+	packed := len(fn.ParamTypes()) == 2 && len(fn.ResultTypes()) == 1 &&
+		fn.ParamTypes()[0] == api.ValueTypeI32 && fn.ParamTypes()[1] == api.ValueTypeI32 &&
+		fn.ResultTypes()[0] == api.ValueTypeI64
+
+	var response proto.Message
+
+	switch name {
+	case "httpRequest":
+		response = &steps.HttpResponse{
+			Code: 0,
+			Body: []byte("HTTP requests are " + simulationMessage),
+		}
+	case "kvExists":
+		response = &steps.KVStepResponse{
+			Status:  steps.KVStatus_KV_STATUS_ERROR,
+			Message: "KV lookups are " + simulationMessage,
+		}
+	}
+
+	return func(ctx context.Context, mod api.Module, stack []uint64) {
+		if response == nil || !packed {
+			panic(fmt.Sprintf("host function '%s' is %s", name, simulationMessage))
+		}
+
+		data, err := proto.Marshal(response)
+		if err != nil {
+			panic(err)
+		}
+
+		ptr, err := writeGuest(ctx, mod, data)
+		if err != nil {
+			panic(err)
+		}
+
+		stack[0] = pack(ptr, uint32(len(data)))
+	}
+}
+
+// Close releases the Wasm runtime and all modules
+func (s *Simulator) Close(ctx context.Context) error {
+	return s.runtime.Close(ctx)
+}
+
+// Run executes the steps against the payload in order, following the abort
+// conditions of each step like an SDK would
+func (s *Simulator) Run(ctx context.Context, pipelineSteps []*protos.PipelineStep, payload []byte) *Result {
+	result := &Result{
+		Input: payload,
+		Steps: make([]StepResult, 0, len(pipelineSteps)),
+	}
+
+	var interStep *protos.InterStepResult
+
+	for _, step := range pipelineSteps {
+		if result.Aborted {
+			result.Steps = append(result.Steps, StepResult{
+				Name:   step.GetName(),
+				Result: ResultSkipped,
+				Output: payload,
+			})
+
+			continue
+		}
+
+		resp, err := s.runStep(ctx, step, payload, interStep)
+		if err != nil {
+			resp = &protos.WASMResponse{
+				ExitCode: protos.WASMExitCode_WASM_EXIT_CODE_ERROR,
+				ExitMsg:  err.Error(),
+			}
+		}
+
+		sr := StepResult{
+			Name:    step.GetName(),
+			Message: resp.GetExitMsg(),
+			Output:  payload,
+		}
+
+		var cond *protos.PipelineStepConditions
+
+		switch resp.GetExitCode() {
+		case protos.WASMExitCode_WASM_EXIT_CODE_TRUE:
+			sr.Result = ResultTrue
+			cond = step.GetOnTrue()
+		case protos.WASMExitCode_WASM_EXIT_CODE_FALSE:
+			sr.Result = ResultFalse
+			cond = step.GetOnFalse()
+		default:
+			sr.Result = ResultError
+			cond = step.GetOnError()
+		}
+
+		if sr.Result != ResultError {
+			payload = resp.GetOutputPayload()
+			sr.Output = payload
+			interStep = resp.GetInterStepResult()
+		}
+
+		sr.Abort = cond.GetAbort()
+		sr.Notify = cond.GetNotification() != nil || cond.GetNotify()
+		sr.Metadata = cond.GetMetadata()
+
+		if sr.Abort == protos.AbortCondition_ABORT_CONDITION_ABORT_CURRENT ||
+			sr.Abort == protos.AbortCondition_ABORT_CONDITION_ABORT_ALL {
+			result.Aborted = true
+		}
+
+		result.Steps = append(result.Steps, sr)
+	}
+
+	result.Output = payload
+
+	return result
+}
+
+// runStep calls the step's Wasm module with a WASMRequest and decodes the WASMResponse
+func (s *Simulator) runStep(ctx context.Context, step *protos.PipelineStep, payload []byte, interStep *protos.InterStepResult) (*protos.WASMResponse, error) {
+	mod, ok := s.modules[wasmKey(step.GetXWasmBytes())]
+	if !ok {
+		return nil, fmt.Errorf("no Wasm module loaded for step '%s'", step.GetName())
+	}
+
+	name := step.GetXWasmFunction()
+	if name == "" {
+		name = defaultFunction
+	}
+
+	fn := mod.ExportedFunction(name)
+	if fn == nil {
+		return nil, fmt.Errorf("module does not export function '%s'", name)
+	}
+
+	// The module only needs the step configuration, not the module itself
+	stepCfg := proto.Clone(step).(*protos.PipelineStep)
+	stepCfg.XWasmBytes = nil
+
+	req, err := proto.Marshal(&protos.WASMRequest{
+		Step:            stepCfg,
+		InputPayload:    payload,
+		InterStepResult: interStep,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to encode Wasm request: %s", err)
+	}
+
+	ptr, err := writeGuest(ctx, mod, req)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := fn.Call(ctx, uint64(ptr), uint64(len(req)))
+	if err != nil {
+		return nil, fmt.Errorf("function '%s' failed: %s", name, err)
+	}
+
+	if len(results) != 1 {
+		return nil, fmt.Errorf("function '%s' returned %d values, expected 1", name, len(results))
+	}
+
+	respPtr, respLen := unpack(results[0])
+
+	data, ok := mod.Memory().Read(respPtr, respLen)
+	if !ok {
+		return nil, fmt.Errorf("function '%s' returned an out of bounds response", name)
+	}
+
+	resp := &protos.WASMResponse{}
+	if err := proto.Unmarshal(data, resp); err != nil {
+		return nil, fmt.Errorf("unable to decode Wasm response: %s", err)
+	}
+
+	if dealloc := mod.ExportedFunction(deallocFunction); dealloc != nil {
+		_, _ = dealloc.Call(ctx, uint64(ptr), uint64(len(req)))
+		_, _ = dealloc.Call(ctx, uint64(respPtr), uint64(respLen))
+	}
+
+	return resp, nil
+}
+
+// writeGuest allocates memory in the module using its alloc function and copies data into it
+func writeGuest(ctx context.Context, mod api.Module, data []byte) (uint32, error) {
+	alloc := mod.ExportedFunction(allocFunction)
+	if alloc == nil {
+		return 0, fmt.Errorf("module does not export an '%s' function", allocFunction)
+	}
+
+	results, err := alloc.Call(ctx, uint64(len(data)))
+	if err != nil {
+		return 0, fmt.Errorf("unable to allocate Wasm memory: %s", err)
+	}
+
+	ptr := uint32(results[0])
+
+	if !mod.Memory().Write(ptr, data) {
+		return 0, fmt.Errorf("unable to write %d bytes to Wasm memory at %d", len(data), ptr)
+	}
+
+	return ptr, nil
+}
+
+func pack(ptr, length uint32) uint64 {
+	return uint64(ptr)<<32 | uint64(length)
+}
+
+func unpack(v uint64) (uint32, uint32) {
+	return uint32(v >> 32), uint32(v)
+}
+
+func wasmKey(wasm []byte) string {
+	sum := sha256.Sum256(wasm)
+	return hex.EncodeToString(sum[:])
+}
+
+// StepType returns the name of the step's type, ie. "detective"
+func StepType(step *protos.PipelineStep) string {
+	switch step.GetStep().(type) {
+	case *protos.PipelineStep_Detective:
+		return "detective"
+	case *protos.PipelineStep_Transform:
+		return "transform"
+	case *protos.PipelineStep_Encode:
+		return "encode"
+	case *protos.PipelineStep_Decode:
+		return "decode"
+	case *protos.PipelineStep_Custom:
+		return "custom"
+	case *protos.PipelineStep_HttpRequest:
+		return "http_request"
+	case *protos.PipelineStep_Kv:
+		return "kv"
+	case *protos.PipelineStep_InferSchema:
+		return "infer_schema"
+	case *protos.PipelineStep_ValidJson:
+		return "valid_json"
+	case *protos.PipelineStep_SchemaValidation:
+		return "schema_validation"
+	}
+
+	return ""
+}
@@ -0,0 +1,206 @@
+package simulate
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/streamdal/streamdal/libs/protos/build/go/protos"
+)
+
+const responseOffset = 2048
+
+// testModule builds a minimal Wasm module which exports memory, an alloc function
+// and an "f" function that always returns the given response
+func testModule(t *testing.T, resp *protos.WASMResponse) []byte {
+	t.Helper()
+
+	data, err := proto.Marshal(resp)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	section := func(id byte, contents ...byte) []byte {
+		return append(append([]byte{id}, uleb(uint64(len(contents)))...), contents...)
+	}
+
+	body := func(instrs ...byte) []byte {
+		b := append([]byte{0x00}, instrs...) // no locals
+		b = append(b, 0x0b)
+		return append(uleb(uint64(len(b))), b...)
+	}
+
+	wasm := []byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}
+
+	// Types: (i32) -> i32 and (i32, i32) -> i64
+	wasm = append(wasm, section(0x01, 0x02, 0x60, 0x01, 0x7f, 0x01, 0x7f, 0x60, 0x02, 0x7f, 0x7f, 0x01, 0x7e)...)
+	wasm = append(wasm, section(0x03, 0x02, 0x00, 0x01)...)
+	wasm = append(wasm, section(0x05, 0x01, 0x00, 0x01)...)
+
+	exports := []byte{0x03}
+	exports = append(exports, append([]byte{0x06}, []byte("memory")...)...)
+	exports = append(exports, 0x02, 0x00)
+	exports = append(exports, append([]byte{0x05}, []byte("alloc")...)...)
+	exports = append(exports, 0x00, 0x00)
+	exports = append(exports, append([]byte{0x01}, []byte("f")...)...)
+	exports = append(exports, 0x00, 0x01)
+	wasm = append(wasm, section(0x07, exports...)...)
+
+	code := []byte{0x02}
+	code = append(code, body(append([]byte{0x41}, sleb(1024)...)...)...)
+	code = append(code, body(append([]byte{0x42}, sleb(int64(pack(responseOffset, uint32(len(data)))))...)...)...)
+	wasm = append(wasm, section(0x0a, code...)...)
+
+	segment := []byte{0x01, 0x00, 0x41}
+	segment = append(segment, sleb(responseOffset)...)
+	segment = append(segment, 0x0b)
+	segment = append(segment, uleb(uint64(len(data)))...)
+	segment = append(segment, data...)
+	wasm = append(wasm, section(0x0b, segment...)...)
+
+	return wasm
+}
+
+func uleb(v uint64) []byte {
+	var out []byte
+
+	for {
+		b := byte(v & 0x7f)
+		v >>= 7
+
+		if v != 0 {
+			b |= 0x80
+		}
+
+		out = append(out, b)
+
+		if v == 0 {
+			return out
+		}
+	}
+}
+
+func sleb(v int64) []byte {
+	var out []byte
+
+	for {
+		b := byte(v & 0x7f)
+		v >>= 7
+
+		if (v == 0 && b&0x40 == 0) || (v == -1 && b&0x40 != 0) {
+			return append(out, b)
+		}
+
+		out = append(out, b|0x80)
+	}
+}
+
+func TestSimulator_Run(t *testing.T) {
+	ctx := context.Background()
+
+	transform := testModule(t, &protos.WASMResponse{
+		OutputPayload: []byte(`{"email":"***"}`),
+		ExitCode:      protos.WASMExitCode_WASM_EXIT_CODE_TRUE,
+		ExitMsg:       "transformed",
+	})
+
+	// Detectives pass the payload through unchanged
+	detective := testModule(t, &protos.WASMResponse{
+		OutputPayload: []byte(`{"email":"***"}`),
+		ExitCode:      protos.WASMExitCode_WASM_EXIT_CODE_FALSE,
+		ExitMsg:       "no match",
+	})
+
+	pipelineSteps := []*protos.PipelineStep{
+		{
+			Name:       "Mask email",
+			XWasmBytes: transform,
+			OnTrue:     &protos.PipelineStepConditions{Metadata: map[string]string{"masked": "true"}},
+		},
+		{
+			Name:       "Find PII",
+			XWasmBytes: detective,
+			OnFalse:    &protos.PipelineStepConditions{Abort: protos.AbortCondition_ABORT_CONDITION_ABORT_CURRENT},
+		},
+		{
+			Name:       "Mask again",
+			XWasmBytes: transform,
+		},
+	}
+
+	sim, err := New(ctx, pipelineSteps)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer sim.Close(ctx)
+
+	res := sim.Run(ctx, pipelineSteps, []byte(`{"email":"user@example.com"}`))
+
+	if !res.Aborted {
+		t.Fatal("expected pipeline to be aborted")
+	}
+
+	if string(res.Output) != `{"email":"***"}` {
+		t.Fatalf("unexpected output: %s", res.Output)
+	}
+
+	expected := []struct {
+		result  string
+		message string
+		abort   protos.AbortCondition
+	}{
+		{ResultTrue, "transformed", protos.AbortCondition_ABORT_CONDITION_UNSET},
+		{ResultFalse, "no match", protos.AbortCondition_ABORT_CONDITION_ABORT_CURRENT},
+		{ResultSkipped, "", protos.AbortCondition_ABORT_CONDITION_UNSET},
+	}
+
+	if len(res.Steps) != len(expected) {
+		t.Fatalf("expected %d step results, got %d", len(expected), len(res.Steps))
+	}
+
+	for i, e := range expected {
+		sr := res.Steps[i]
+		if sr.Result != e.result || sr.Message != e.message || sr.Abort != e.abort {
+			t.Errorf("step %d: unexpected result %+v", i, sr)
+		}
+	}
+
+	if res.Steps[0].Metadata["masked"] != "true" {
+		t.Errorf("expected metadata of on_true condition, got %v", res.Steps[0].Metadata)
+	}
+}
+
+func TestNew_MissingModule(t *testing.T) {
+	if _, err := New(context.Background(), []*protos.PipelineStep{{Name: "No module"}}); err == nil {
+		t.Fatal("expected an error for a step without a Wasm module")
+	}
+}
+
+func TestRun_MissingFunction(t *testing.T) {
+	ctx := context.Background()
+
+	fn := "missing"
+	pipelineSteps := []*protos.PipelineStep{{
+		Name:          "Missing function",
+		XWasmBytes:    testModule(t, &protos.WASMResponse{ExitCode: protos.WASMExitCode_WASM_EXIT_CODE_TRUE}),
+		XWasmFunction: &fn,
+		OnError:       &protos.PipelineStepConditions{Abort: protos.AbortCondition_ABORT_CONDITION_ABORT_ALL},
+	}}
+
+	sim, err := New(ctx, pipelineSteps)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer sim.Close(ctx)
+
+	res := sim.Run(ctx, pipelineSteps, []byte(`{}`))
+
+	if res.Steps[0].Result != ResultError || !res.Aborted {
+		t.Fatalf("expected step to error and abort, got %+v", res.Steps[0])
+	}
+
+	if string(res.Output) != `{}` {
+		t.Fatalf("expected payload to be unchanged, got %s", res.Output)
+	}
+}
@@ -2,9 +2,12 @@ package main
 
 import (
 	"flag"
+	"os"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/plugin"
 
+	"github.com/streamdal/terraform-provider-streamdal/internal/export"
+	"github.com/streamdal/terraform-provider-streamdal/internal/lint"
 	"github.com/streamdal/terraform-provider-streamdal/internal/provider"
 )
 
@@ -28,6 +31,16 @@ var (
 )
 
 func main() {
+	// Subcommands are run directly, without serving the plugin
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "lint":
+			os.Exit(lint.Run(version, os.Args[2:], os.Stdout, os.Stderr))
+		case "export":
+			os.Exit(export.Run(os.Args[2:], os.Stdout, os.Stderr))
+		}
+	}
+
 	var debugMode bool
 
 	flag.BoolVar(&debugMode, "debug", false, "set to true to run the provider with support for debuggers like delve")
@@ -9,6 +9,7 @@ import (
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/backoff"
 	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/metadata"
 
@@ -28,17 +29,19 @@ type Config struct {
 	Timeout int
 }
 
+// New returns a client for the server at cfg.Address. The connection is made on
+// the first request rather than here, so that data sources and plans which don't
+// need the server work without one. cfg.Timeout limits each connection attempt.
 func New(cfg *Config) (*Streamdal, error) {
 	opts := []grpc.DialOption{
-		grpc.WithBlock(),
 		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithConnectParams(grpc.ConnectParams{
+			Backoff:           backoff.DefaultConfig,
+			MinConnectTimeout: time.Duration(cfg.Timeout) * time.Second,
+		}),
 	}
 
-	timeout := time.Duration(cfg.Timeout) * time.Second
-	dialContext, dialCancel := context.WithTimeout(context.Background(), timeout)
-	defer dialCancel()
-
-	conn, err := grpc.DialContext(dialContext, cfg.Address, opts...)
+	conn, err := grpc.DialContext(context.Background(), cfg.Address, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("unable to connect to grpc address '%s': %s", cfg.Address, err)
 	}
@@ -152,6 +155,17 @@ func (s *Streamdal) DeleteNotification(ctx context.Context, req *protos.DeleteNo
 	return s.Client.DeleteNotification(ctx, req)
 }
 
+// DetachNotification detaches a notification config attached to a whole pipeline
+func (s *Streamdal) DetachNotification(ctx context.Context, notificationID, pipelineID string) (*protos.StandardResponse, error) {
+	md := metadata.New(map[string]string{"auth-token": s.Token})
+	ctx = metadata.NewOutgoingContext(ctx, md)
+
+	return s.Client.DetachNotification(ctx, &protos.DetachNotificationRequest{
+		NotificationId: notificationID,
+		PipelineId:     pipelineID,
+	})
+}
+
 func (s *Streamdal) GetNotification(ctx context.Context, req *protos.GetNotificationRequest) (*protos.GetNotificationResponse, error) {
 	md := metadata.New(map[string]string{"auth-token": s.Token})
 	ctx = metadata.NewOutgoingContext(ctx, md)
@@ -276,25 +290,21 @@ func (s *Streamdal) GetAudience(ctx context.Context, id string) (*protos.Audienc
 	return nil, errors.New("audience not found")
 }
 
-// GetPipelinesForAudience returns a list of pipeline IDs that are associated with the given audience.
-// Used for obtaining pipeline assignments for the audience resource
-func (s *Streamdal) GetPipelinesForAudience(ctx context.Context, aud *protos.Audience) ([]string, error) {
-	pipelineIDs := make([]string, 0)
+// GetAudiencesForPipeline returns the audiences the given pipeline is assigned to
+func (s *Streamdal) GetAudiencesForPipeline(ctx context.Context, pipelineID string) ([]*protos.Audience, error) {
+	md := metadata.New(map[string]string{"auth-token": s.Token})
+	ctx = metadata.NewOutgoingContext(ctx, md)
 
 	resp, err := s.Client.GetAll(ctx, &protos.GetAllRequest{})
 	if err != nil {
 		return nil, err
 	}
 
-	for pipelineID, pipeline := range resp.GetPipelines() {
-		for _, audience := range pipeline.Audiences {
-			if util.AudienceEquals(aud, audience) {
-				pipelineIDs = append(pipelineIDs, pipelineID)
-			}
-		}
+	if info, ok := resp.GetPipelines()[pipelineID]; ok {
+		return info.GetAudiences(), nil
 	}
 
-	return pipelineIDs, nil
+	return []*protos.Audience{}, nil
 }
 
 func (s *Streamdal) SetPipelines(ctx context.Context, aud *protos.Audience, pipelineIDs []string) (*protos.StandardResponse, error) {
@@ -307,6 +317,28 @@ func (s *Streamdal) SetPipelines(ctx context.Context, aud *protos.Audience, pipe
 	})
 }
 
+// PausePipeline pauses a pipeline for a single audience it is assigned to
+func (s *Streamdal) PausePipeline(ctx context.Context, aud *protos.Audience, pipelineID string) (*protos.StandardResponse, error) {
+	md := metadata.New(map[string]string{"auth-token": s.Token})
+	ctx = metadata.NewOutgoingContext(ctx, md)
+
+	return s.Client.PausePipeline(ctx, &protos.PausePipelineRequest{
+		PipelineId: pipelineID,
+		Audience:   aud,
+	})
+}
+
+// ResumePipeline resumes a pipeline paused for a single audience
+func (s *Streamdal) ResumePipeline(ctx context.Context, aud *protos.Audience, pipelineID string) (*protos.StandardResponse, error) {
+	md := metadata.New(map[string]string{"auth-token": s.Token})
+	ctx = metadata.NewOutgoingContext(ctx, md)
+
+	return s.Client.ResumePipeline(ctx, &protos.ResumePipelineRequest{
+		PipelineId: pipelineID,
+		Audience:   aud,
+	})
+}
+
 func (s *Streamdal) CreateAudience(ctx context.Context, req *protos.CreateAudienceRequest) (*protos.StandardResponse, error) {
 	md := metadata.New(map[string]string{"auth-token": s.Token})
 	ctx = metadata.NewOutgoingContext(ctx, md)
@@ -320,3 +352,75 @@ func (s *Streamdal) DeleteAudience(ctx context.Context, req *protos.DeleteAudien
 
 	return s.Client.DeleteAudience(ctx, req)
 }
+
+// GetSchema returns the schema inferred by the server from the audience's traffic
+func (s *Streamdal) GetSchema(ctx context.Context, aud *protos.Audience) (*protos.Schema, error) {
+	md := metadata.New(map[string]string{"auth-token": s.Token})
+	ctx = metadata.NewOutgoingContext(ctx, md)
+
+	resp, err := s.Client.GetSchema(ctx, &protos.GetSchemaRequest{Audience: aud})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.GetSchema(), nil
+}
+
+// GetPipelines returns all pipelines
+func (s *Streamdal) GetPipelines(ctx context.Context) ([]*protos.Pipeline, error) {
+	md := metadata.New(map[string]string{"auth-token": s.Token})
+	ctx = metadata.NewOutgoingContext(ctx, md)
+
+	resp, err := s.Client.GetPipelines(ctx, &protos.GetPipelinesRequest{})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.GetPipelines(), nil
+}
+
+// GetNotifications returns all notification configs, keyed by ID
+func (s *Streamdal) GetNotifications(ctx context.Context) (map[string]*protos.NotificationConfig, error) {
+	md := metadata.New(map[string]string{"auth-token": s.Token})
+	ctx = metadata.NewOutgoingContext(ctx, md)
+
+	resp, err := s.Client.GetNotifications(ctx, &protos.GetNotificationsRequest{})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.GetNotifications(), nil
+}
+
+// GetAll returns the server's pipelines, audiences and live clients
+func (s *Streamdal) GetAll(ctx context.Context) (*protos.GetAllResponse, error) {
+	md := metadata.New(map[string]string{"auth-token": s.Token})
+	ctx = metadata.NewOutgoingContext(ctx, md)
+
+	return s.Client.GetAll(ctx, &protos.GetAllRequest{})
+}
+
+// GetAllStream streams the server's pipelines, audiences and live clients, starting
+// with the current state and followed by every change
+func (s *Streamdal) GetAllStream(ctx context.Context) (protos.External_GetAllStreamClient, error) {
+	md := metadata.New(map[string]string{"auth-token": s.Token})
+	ctx = metadata.NewOutgoingContext(ctx, md)
+
+	return s.Client.GetAllStream(ctx, &protos.GetAllRequest{})
+}
+
+// GetMetrics streams the server's metrics
+func (s *Streamdal) GetMetrics(ctx context.Context) (protos.External_GetMetricsClient, error) {
+	md := metadata.New(map[string]string{"auth-token": s.Token})
+	ctx = metadata.NewOutgoingContext(ctx, md)
+
+	return s.Client.GetMetrics(ctx, &protos.GetMetricsRequest{})
+}
+
+// GetAudienceRates streams the throughput rates of each audience
+func (s *Streamdal) GetAudienceRates(ctx context.Context) (protos.External_GetAudienceRatesClient, error) {
+	md := metadata.New(map[string]string{"auth-token": s.Token})
+	ctx = metadata.NewOutgoingContext(ctx, md)
+
+	return s.Client.GetAudienceRates(ctx, &protos.GetAudienceRatesRequest{})
+}
@@ -0,0 +1,435 @@
+package util
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// queryOperators are the comparison operators supported inside a #(...) query.
+// Longer operators must come first so that "==" is not matched as "=".
+var queryOperators = []string{"==", "!=", "<=", ">=", "!%", "=", "<", ">", "%"}
+
+// ValidatePath checks that the given string is a syntactically valid JSON path.
+//
+// The Streamdal Wasm modules resolve paths using GJSON syntax
+// (https://github.com/tidwall/gjson/blob/master/SYNTAX.md), so this validator follows
+// the same grammar: dot or pipe separated keys, '\' escapes, '*' and '?' wildcards,
+// '#' array access, '#(...)' queries, '@' modifiers, '[...]'/'{...}' multipaths and
+// '!' literals. An empty path is considered valid; callers decide whether a path is
+// required.
+func ValidatePath(path string) error {
+	if path == "" {
+		return nil
+	}
+
+	p := &pathParser{path: path}
+
+	if err := p.parsePath(len(path)); err != nil {
+		return fmt.Errorf("invalid path '%s': %s", path, err)
+	}
+
+	return nil
+}
+
+type pathParser struct {
+	path string
+	pos  int
+}
+
+// parsePath parses a sequence of components separated by '.' or '|' up to end
+func (p *pathParser) parsePath(end int) error {
+	for {
+		if p.pos >= end {
+			return p.errorf("expected a key after separator")
+		}
+
+		if err := p.parseComponent(end); err != nil {
+			return err
+		}
+
+		if p.pos >= end {
+			return nil
+		}
+
+		switch p.path[p.pos] {
+		case '.', '|':
+			p.pos++
+		default:
+			return p.errorf("unexpected character '%c'", p.path[p.pos])
+		}
+	}
+}
+
+func (p *pathParser) parseComponent(end int) error {
+	switch p.path[p.pos] {
+	case '.', '|':
+		return p.errorf("empty key")
+	case '@':
+		return p.parseModifier(end)
+	case '#':
+		return p.parseArray(end)
+	case '[', '{':
+		return p.parseMultipath(end)
+	case '!':
+		return p.parseLiteral(end)
+	default:
+		return p.parseKey(end)
+	}
+}
+
+// parseKey consumes a plain key, honoring '\' escapes
+func (p *pathParser) parseKey(end int) error {
+	start := p.pos
+
+	for p.pos < end {
+		c := p.path[p.pos]
+
+		if c == '\\' {
+			if p.pos+1 >= end {
+				return p.errorf("dangling escape character")
+			}
+
+			p.pos += 2
+			continue
+		}
+
+		if c == '.' || c == '|' {
+			break
+		}
+
+		p.pos++
+	}
+
+	if p.pos == start {
+		return p.errorf("empty key")
+	}
+
+	return nil
+}
+
+// parseModifier consumes '@name' with an optional ':arg'
+func (p *pathParser) parseModifier(end int) error {
+	p.pos++ // '@'
+	start := p.pos
+
+	for p.pos < end && isIdentChar(p.path[p.pos]) {
+		p.pos++
+	}
+
+	if p.pos == start {
+		return p.errorf("missing modifier name after '@'")
+	}
+
+	if p.pos < end && p.path[p.pos] == ':' {
+		p.pos++
+
+		return p.skipValue(end)
+	}
+
+	return nil
+}
+
+// parseArray consumes '#', '#(query)' or '#(query)#'
+func (p *pathParser) parseArray(end int) error {
+	p.pos++ // '#'
+
+	if p.pos >= end || p.path[p.pos] != '(' {
+		return nil
+	}
+
+	closing, err := p.matching(p.pos, end)
+	if err != nil {
+		return err
+	}
+
+	if err := p.parseQuery(p.pos+1, closing); err != nil {
+		return err
+	}
+
+	p.pos = closing + 1
+
+	if p.pos < end && p.path[p.pos] == '#' {
+		p.pos++
+	}
+
+	return nil
+}
+
+// parseQuery validates the contents of a #(...) query, ie. `name.first=="Dale"`
+func (p *pathParser) parseQuery(start, end int) error {
+	if start == end {
+		return p.errorfAt(start, "empty query")
+	}
+
+	opStart, op := p.findOperator(start, end)
+	if op == "" {
+		// Existence check, ie. #(nets)
+		return p.subPath(start, end)
+	}
+
+	if opStart > start {
+		if err := p.subPath(start, opStart); err != nil {
+			return err
+		}
+	}
+
+	valueStart := opStart + len(op)
+	value := strings.TrimSpace(p.path[valueStart:end])
+	if value == "" {
+		return p.errorfAt(valueStart, "missing value after operator '%s'", op)
+	}
+
+	if !isQueryValue(value) {
+		return p.errorfAt(valueStart, "invalid query value '%s'", value)
+	}
+
+	return nil
+}
+
+// findOperator returns the position of the first comparison operator at the
+// top level of a query, skipping over nested queries and quoted strings
+func (p *pathParser) findOperator(start, end int) (int, string) {
+	depth := 0
+
+	for i := start; i < end; i++ {
+		c := p.path[i]
+
+		switch {
+		case c == '\\':
+			i++
+		case c == '"':
+			i = p.skipString(i, end)
+		case c == '(' || c == '[' || c == '{':
+			depth++
+		case c == ')' || c == ']' || c == '}':
+			depth--
+		case depth == 0:
+			for _, op := range queryOperators {
+				if strings.HasPrefix(p.path[i:end], op) {
+					return i, op
+				}
+			}
+		}
+	}
+
+	return -1, ""
+}
+
+// parseMultipath consumes '[path,path]' or '{path,"name":path}'
+func (p *pathParser) parseMultipath(end int) error {
+	open := p.path[p.pos]
+
+	closing, err := p.matching(p.pos, end)
+	if err != nil {
+		return err
+	}
+
+	elemStart := p.pos + 1
+	depth := 0
+
+	for i := elemStart; i <= closing; i++ {
+		c := p.path[i]
+
+		switch {
+		case c == '\\':
+			i++
+			continue
+		case c == '"':
+			i = p.skipString(i, closing)
+			continue
+		case c == '(' || c == '[' || c == '{':
+			depth++
+			continue
+		case (c == ')' || c == ']' || c == '}') && i != closing:
+			depth--
+			continue
+		case depth > 0 || (c != ',' && i != closing):
+			continue
+		}
+
+		if err := p.multipathElem(open, elemStart, i); err != nil {
+			return err
+		}
+
+		elemStart = i + 1
+	}
+
+	p.pos = closing + 1
+
+	return nil
+}
+
+func (p *pathParser) multipathElem(open byte, start, end int) error {
+	elem := p.path[start:end]
+	if strings.TrimSpace(elem) == "" {
+		return p.errorfAt(start, "empty path in multipath")
+	}
+
+	// Objects may name their members, ie. {"first":name.first}
+	if open == '{' && elem[0] == '"' {
+		closing := p.skipString(start, end)
+		if closing >= end || p.path[closing] != '"' {
+			return p.errorfAt(start, "unterminated string")
+		}
+
+		if closing+1 >= end || p.path[closing+1] != ':' {
+			return p.errorfAt(closing+1, "expected ':' after member name")
+		}
+
+		start = closing + 2
+	}
+
+	return p.subPath(start, end)
+}
+
+// parseLiteral consumes '!' followed by a JSON value
+func (p *pathParser) parseLiteral(end int) error {
+	p.pos++ // '!'
+	start := p.pos
+
+	if err := p.skipValue(end); err != nil {
+		return err
+	}
+
+	if p.pos == start {
+		return p.errorf("missing literal value after '!'")
+	}
+
+	return nil
+}
+
+// skipValue consumes a JSON value used as a literal or modifier argument
+func (p *pathParser) skipValue(end int) error {
+	if p.pos >= end {
+		return nil
+	}
+
+	switch p.path[p.pos] {
+	case '"':
+		closing := p.skipString(p.pos, end)
+		if closing >= end {
+			return p.errorf("unterminated string")
+		}
+
+		p.pos = closing + 1
+	case '[', '{':
+		closing, err := p.matching(p.pos, end)
+		if err != nil {
+			return err
+		}
+
+		p.pos = closing + 1
+	default:
+		for p.pos < end && p.path[p.pos] != '.' && p.path[p.pos] != '|' {
+			p.pos++
+		}
+	}
+
+	return nil
+}
+
+// subPath validates path[start:end] as a standalone path
+func (p *pathParser) subPath(start, end int) error {
+	for start < end && p.path[start] == ' ' {
+		start++
+	}
+
+	for end > start && p.path[end-1] == ' ' {
+		end--
+	}
+
+	sub := &pathParser{path: p.path, pos: start}
+
+	return sub.parsePath(end)
+}
+
+// matching returns the position of the bracket that closes the one at pos
+func (p *pathParser) matching(pos, end int) (int, error) {
+	stack := []byte{closerOf(p.path[pos])}
+
+	for i := pos + 1; i < end; i++ {
+		c := p.path[i]
+
+		switch c {
+		case '\\':
+			i++
+		case '"':
+			i = p.skipString(i, end)
+			if i >= end {
+				return 0, p.errorfAt(pos, "unterminated string")
+			}
+		case '(', '[', '{':
+			stack = append(stack, closerOf(c))
+		case ')', ']', '}':
+			if c != stack[len(stack)-1] {
+				return 0, p.errorfAt(i, "unexpected '%c'", c)
+			}
+
+			stack = stack[:len(stack)-1]
+			if len(stack) == 0 {
+				return i, nil
+			}
+		}
+	}
+
+	return 0, p.errorfAt(pos, "unclosed '%c'", p.path[pos])
+}
+
+// skipString returns the position of the quote that terminates the string
+// starting at pos, or end if the string is not terminated
+func (p *pathParser) skipString(pos, end int) int {
+	for i := pos + 1; i < end; i++ {
+		switch p.path[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+
+	return end
+}
+
+func (p *pathParser) errorf(format string, args ...interface{}) error {
+	return p.errorfAt(p.pos, format, args...)
+}
+
+func (p *pathParser) errorfAt(pos int, format string, args ...interface{}) error {
+	return fmt.Errorf("%s at position %d", fmt.Sprintf(format, args...), pos)
+}
+
+func closerOf(c byte) byte {
+	switch c {
+	case '(':
+		return ')'
+	case '[':
+		return ']'
+	default:
+		return '}'
+	}
+}
+
+func isIdentChar(c byte) bool {
+	return c == '_' || c == '-' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+// isQueryValue determines if s is a value that can be compared against in a query.
+// A leading '~' converts the value to a boolean before comparing.
+func isQueryValue(s string) bool {
+	s = strings.TrimPrefix(s, "~")
+
+	switch s {
+	case "true", "false", "null", "":
+		return s != ""
+	}
+
+	if s[0] == '"' {
+		closing := (&pathParser{path: s}).skipString(0, len(s))
+		return closing == len(s)-1
+	}
+
+	_, err := strconv.ParseFloat(s, 64)
+
+	return err == nil
+}
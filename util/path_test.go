@@ -0,0 +1,69 @@
+package util
+
+import "testing"
+
+func TestValidatePath(t *testing.T) {
+	valid := []string{
+		"",
+		"object",
+		"object.email",
+		"object.user.emails.0",
+		"fav\\.movie",
+		"child*",
+		"c?ildren.0",
+		"friends.#",
+		"friends.#.first",
+		`friends.#(last=="Murphy").first`,
+		`friends.#(last="Murphy")#.first`,
+		`friends.#(age>45)#.last`,
+		`friends.#(first%"D*").last`,
+		`friends.#(first!%"D*").last`,
+		`friends.#(nets.#(=="fb"))#.first`,
+		`vals.#(b==~true)#.a`,
+		"friends.#(nets)#",
+		"children|@reverse",
+		"children.@reverse.0",
+		`@pretty:{"sortKeys":true}`,
+		"{name.first,age}",
+		`{name.first,"the_murphys":friends.#(last="Murphy")#.first}`,
+		"[name.first,age]",
+		"!true",
+		`{name.first,"answer":!42}`,
+		"friends.0|last",
+	}
+
+	for _, p := range valid {
+		if err := ValidatePath(p); err != nil {
+			t.Errorf("expected path '%s' to be valid, got: %s", p, err)
+		}
+	}
+
+	invalid := []string{
+		"object..email",
+		".object",
+		"object.",
+		"object|",
+		"object|.email",
+		"object\\",
+		"friends.#(last==\"Murphy\"",
+		"friends.#(last==\"Murphy).first",
+		"friends.#().first",
+		"friends.#(last==).first",
+		"friends.#(age>forty)",
+		"friends.#(==)",
+		"@",
+		"children.@",
+		"{name.first,}",
+		"[]",
+		"[name.first",
+		"!",
+		`{"name"name.first}`,
+		"friends.#(nets.#(==\"fb\")#.first",
+	}
+
+	for _, p := range invalid {
+		if err := ValidatePath(p); err == nil {
+			t.Errorf("expected path '%s' to be invalid", p)
+		}
+	}
+}